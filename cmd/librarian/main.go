@@ -18,12 +18,20 @@ import (
 	"context"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/googleapis/librarian/internal/librarian"
 )
 
 func main() {
-	ctx := context.Background()
+	// SIGINT or SIGTERM cancels the context, which kills any running container and stops
+	// the command. A second signal terminates the process immediately.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
 	if err := librarian.Run(ctx, os.Args[1:]...); err != nil {
 		log.Fatal(err)
 	}
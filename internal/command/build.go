@@ -0,0 +1,107 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+
+	"github.com/googleapis/librarian/internal/container"
+	"github.com/googleapis/librarian/internal/gitrepo"
+)
+
+var CmdBuild = &Command{
+	Name:  "build",
+	Short: "Build a library from the code already in a language repo, without generating.",
+	flagFunctions: []func(fs *flag.FlagSet){
+		addFlagImage,
+		addFlagImages,
+		addFlagWorkRoot,
+		addFlagAPIPath,
+		addFlagLanguage,
+		addFlagLibraryID,
+		addFlagRepoRoot,
+		addFlagResetRepo,
+		addFlagSecretProvider,
+		addFlagSecretsDir,
+		addFlagSecretsProject,
+		addFlagVaultMount,
+		addFlagContainerRetries,
+		addFlagContainerRuntime,
+		addFlagRegistryConfig,
+		addFlagContainerLogs,
+		addFlagContainerEnv,
+	},
+	maybeGetLanguageRepo:    openLocalLanguageRepo,
+	maybeLoadStateAndConfig: loadRepoStateAndConfig,
+	execute:                 buildImpl,
+	runsContainers:          true,
+}
+
+// Opens the language repo specified by -repo-root, which is required, for
+// commands which only operate on a local checkout and never clone.
+func openLocalLanguageRepo(ctx context.Context, workRoot string) (*gitrepo.Repo, error) {
+	if err := validateRequiredFlag("repo-root", flagRepoRoot); err != nil {
+		return nil, err
+	}
+	return cloneOrOpenLanguageRepo(ctx, workRoot)
+}
+
+func buildImpl(state *commandState) error {
+	libraryID, err := resolveLibraryID(state)
+	if err != nil {
+		return err
+	}
+	languageRepo := state.languageRepo
+	if err := cleanLibrary(state.ctx, state, languageRepo.Dir, libraryID); err != nil {
+		return err
+	}
+	// Clean removes the generated code as well as anything stale; when generating,
+	// the freshly-generated code is copied in at this point. Here we restore the
+	// checked-in code instead. (The repo is known to have been clean to start with.)
+	if err := gitrepo.RestoreTrackedFiles(languageRepo); err != nil {
+		return err
+	}
+	if err := container.BuildLibrary(state.ctx, state.containerConfig, languageRepo.Dir, libraryID); err != nil {
+		return err
+	}
+	slog.Info(fmt.Sprintf("Built library %s", libraryID))
+	return nil
+}
+
+// Determines the library specified by exactly one of -library-id and -api-path,
+// checking that it's configured in the pipeline state.
+func resolveLibraryID(state *commandState) (string, error) {
+	if (flagLibraryID == "") == (flagAPIPath == "") {
+		return "", errors.New("specify exactly one of library-id and api-path")
+	}
+	if flagLibraryID != "" {
+		if findLibraryByID(state.pipelineState, flagLibraryID) == nil {
+			return "", fmt.Errorf("no such library: %s", flagLibraryID)
+		}
+		return flagLibraryID, nil
+	}
+	libraryID, err := findLibraryIDByApiPath(state.pipelineState, flagAPIPath)
+	if err != nil {
+		return "", err
+	}
+	if libraryID == "" {
+		return "", fmt.Errorf("no library includes API path %s", flagAPIPath)
+	}
+	return libraryID, nil
+}
@@ -0,0 +1,148 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/googleapis/librarian/internal/gitrepo"
+)
+
+var CmdClean = &Command{
+	Name:  "clean",
+	Short: "Remove the generated code for a library from a local language repo.",
+	flagFunctions: []func(fs *flag.FlagSet){
+		addFlagImage,
+		addFlagImages,
+		addFlagWorkRoot,
+		addFlagAPIPath,
+		addFlagLanguage,
+		addFlagLibraryID,
+		addFlagRepoRoot,
+		addFlagSecretProvider,
+		addFlagSecretsDir,
+		addFlagSecretsProject,
+		addFlagVaultMount,
+		addFlagContainerRetries,
+		addFlagContainerRuntime,
+		addFlagRegistryConfig,
+		addFlagContainerLogs,
+		addFlagContainerEnv,
+	},
+	// Local changes are expected when iterating, so the repo needn't be clean.
+	maybeGetLanguageRepo: func(ctx context.Context, workRoot string) (*gitrepo.Repo, error) {
+		if err := validateRequiredFlag("repo-root", flagRepoRoot); err != nil {
+			return nil, err
+		}
+		repoRoot, err := filepath.Abs(flagRepoRoot)
+		if err != nil {
+			return nil, err
+		}
+		return gitrepo.Open(repoRoot)
+	},
+	maybeLoadStateAndConfig: loadRepoStateAndConfig,
+	execute:                 cleanImpl,
+	runsContainers:          true,
+}
+
+func cleanImpl(state *commandState) error {
+	libraryID, err := resolveLibraryID(state)
+	if err != nil {
+		return err
+	}
+	repoDir := state.languageRepo.Dir
+	before, err := listRepoPaths(repoDir)
+	if err != nil {
+		return err
+	}
+	if err := cleanLibrary(state.ctx, state, repoDir, libraryID); err != nil {
+		return err
+	}
+	after, err := listRepoPaths(repoDir)
+	if err != nil {
+		return err
+	}
+	return writeRemovedPaths(os.Stdout, before, after)
+}
+
+// Lists all the files and directories within a repo (other than the .git directory),
+// as slash-separated paths relative to the repo root.
+func listRepoPaths(repoDir string) (map[string]bool, error) {
+	paths := make(map[string]bool)
+	err := filepath.WalkDir(repoDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == repoDir {
+			return nil
+		}
+		if entry.IsDir() && entry.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		relative, err := filepath.Rel(repoDir, path)
+		if err != nil {
+			return err
+		}
+		paths[filepath.ToSlash(relative)] = entry.IsDir()
+		return nil
+	})
+	return paths, err
+}
+
+// Writes the paths which were removed, in order. Where a whole directory was
+// removed, only the directory is listed (with a trailing slash), not its contents.
+func writeRemovedPaths(w io.Writer, before, after map[string]bool) error {
+	var removed []string
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			removed = append(removed, path)
+		}
+	}
+	sort.Strings(removed)
+	if len(removed) == 0 {
+		_, err := fmt.Fprintln(w, "Nothing was removed.")
+		return err
+	}
+	for _, path := range removed {
+		if parentRemoved(path, before, after) {
+			continue
+		}
+		if before[path] {
+			path += "/"
+		}
+		if _, err := fmt.Fprintf(w, "Removed %s\n", path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reports whether any ancestor directory of a (slash-separated) path was removed.
+func parentRemoved(relative string, before, after map[string]bool) bool {
+	for dir := path.Dir(relative); dir != "."; dir = path.Dir(dir) {
+		if _, ok := after[dir]; !ok && before[dir] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/googleapis/librarian/internal/container"
+	"github.com/googleapis/librarian/internal/gitrepo"
+	"github.com/googleapis/librarian/internal/statepb"
+)
+
+var CmdCleanContainers = &Command{
+	Name:  "clean-containers",
+	Short: "Remove containers left behind by Librarian processes which exited abnormally.",
+	flagFunctions: []func(fs *flag.FlagSet){
+		addFlagContainerRuntime,
+		addFlagAllContainers,
+		addFlagDryRun,
+	},
+	maybeGetLanguageRepo: func(ctx context.Context, workRoot string) (*gitrepo.Repo, error) {
+		return nil, nil
+	},
+	maybeLoadStateAndConfig: func(languageRepo *gitrepo.Repo) (*statepb.PipelineState, *statepb.PipelineConfig, error) {
+		return nil, nil, nil
+	},
+	execute: runCleanContainers,
+}
+
+// Removes the containers started by Librarian processes which are no longer running on
+// this host (and in this PID namespace), or with -all-containers, every container started by Librarian. Stale
+// containers are also removed automatically before a command runs its first container;
+// this allows them to be removed on demand, e.g. at the end of a CI job.
+func runCleanContainers(state *commandState) error {
+	containers, err := container.FindContainers(state.ctx, state.containerConfig)
+	if err != nil {
+		return err
+	}
+	names := []string{}
+	for _, c := range containers {
+		if c.Stale || flagAllContainers {
+			names = append(names, c.Name)
+		} else {
+			slog.Info(fmt.Sprintf("Keeping container %s, as its process (%d on %s) may still be running", c.Name, c.Pid, c.Host))
+		}
+	}
+	if len(names) == 0 {
+		slog.Info("No containers to remove")
+		return nil
+	}
+	if flagDryRun {
+		slog.Info(fmt.Sprintf("Dry run: would remove %d containers: %s", len(names), strings.Join(names, ", ")))
+		return nil
+	}
+	if err := container.RemoveContainers(state.ctx, state.containerConfig, names); err != nil {
+		return err
+	}
+	slog.Info(fmt.Sprintf("Removed %d containers: %s", len(names), strings.Join(names, ", ")))
+	return nil
+}
@@ -24,5 +24,5 @@ const googleapisURL = "https://github.com/googleapis/googleapis"
 
 func cloneGoogleapis(workRoot string) (*gitrepo.Repo, error) {
 	repoPath := filepath.Join(workRoot, "googleapis")
-	return gitrepo.CloneOrOpen(repoPath, googleapisURL)
+	return gitrepo.CloneOrOpen(repoPath, googleapisURL, "", 0, gitrepo.Credentials{})
 }
@@ -0,0 +1,138 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/googleapis/librarian/internal/githubrepo"
+	"github.com/googleapis/librarian/internal/gitrepo"
+	"github.com/googleapis/librarian/internal/statepb"
+)
+
+var CmdClosePRs = &Command{
+	Name:  "close-prs",
+	Short: "Close stale pull requests created by Librarian.",
+	flagFunctions: []func(fs *flag.FlagSet){
+		addFlagWorkRoot,
+		addFlagLanguage,
+		addFlagRepoUrl,
+		addFlagBaseBranch,
+		addFlagForkRepo,
+		addFlagBranchPrefix,
+		addFlagExistingPRAuthor,
+		addFlagExistingPRLabel,
+		addFlagOlderThan,
+		addFlagDeleteBranches,
+		addFlagDryRun,
+	},
+	maybeGetLanguageRepo: func(ctx context.Context, workRoot string) (*gitrepo.Repo, error) {
+		return nil, nil
+	},
+	maybeLoadStateAndConfig: func(languageRepo *gitrepo.Repo) (*statepb.PipelineState, *statepb.PipelineConfig, error) {
+		return nil, nil, nil
+	},
+	execute: closePRsImpl,
+}
+
+// Closes the open pull requests in the language repo whose branches start with -branch-prefix
+// (and which match -existing-pr-author and -existing-pr-label, if specified) and which were
+// created longer ago than -older-than. These are typically regeneration PRs which have since
+// been superseded. As when creating PRs, only PRs against -base-branch (by default, the
+// repo's default branch) are closed, with their branches in -fork-repo if it's specified.
+func closePRsImpl(state *commandState) error {
+	if flagOlderThan <= 0 {
+		return errors.New("-older-than must be specified as a positive duration, e.g. 168h")
+	}
+	if githubrepo.GetAccessToken() == "" {
+		return errors.New("no GitHub access token specified")
+	}
+	if err := githubrepo.CheckAccessTokenScopes(state.ctx); err != nil {
+		return err
+	}
+	repoUrl := flagRepoUrl
+	if repoUrl == "" {
+		if err := validateRequiredFlag("language", flagLanguage); err != nil {
+			return err
+		}
+		repoUrl = fmt.Sprintf("%sgoogleapis/google-cloud-%s", githubrepo.BaseUrl(), flagLanguage)
+	}
+	gitHubRepo, err := githubrepo.ParseUrl(repoUrl)
+	if err != nil {
+		return err
+	}
+	baseBranch := flagBaseBranch
+	if baseBranch == "" {
+		if baseBranch, err = githubrepo.GetDefaultBranch(state.ctx, gitHubRepo); err != nil {
+			return err
+		}
+	}
+	// PR branches (and so the branches to delete) are in the fork, if there is one.
+	branchRepo := gitHubRepo
+	var headRepo githubrepo.GitHubRepo
+	if flagForkRepo != "" {
+		if headRepo, err = resolveForkRepo(state.ctx, gitHubRepo); err != nil {
+			return err
+		}
+		branchRepo = headRepo
+	}
+	cutoff := state.startTime.Add(-flagOlderThan)
+	prs, err := githubrepo.FindPullRequests(state.ctx, gitHubRepo, githubrepo.PullRequestFilter{
+		Label:         flagExistingPRLabel,
+		Author:        flagExistingPRAuthor,
+		BranchPrefix:  flagBranchPrefix,
+		CreatedBefore: cutoff,
+		HeadRepo:      headRepo,
+		BaseBranch:    baseBranch,
+	})
+	if err != nil {
+		return err
+	}
+	if len(prs) == 0 {
+		slog.Info(fmt.Sprintf("No open PRs created before %s to close", cutoff.Format(time.RFC3339)))
+		return nil
+	}
+
+	var errs []error
+	for _, pr := range prs {
+		if flagDryRun {
+			slog.Info(fmt.Sprintf("Dry run: would close PR %d (branch %s)", pr.Number, pr.Branch))
+			continue
+		}
+		comment := fmt.Sprintf("Closing this PR as it was created more than %s ago, and has probably been superseded.", flagOlderThan)
+		if err := githubrepo.AddCommentToPullRequest(state.ctx, gitHubRepo, pr.Number, comment); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := githubrepo.ClosePullRequest(state.ctx, *pr); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		slog.Info(fmt.Sprintf("Closed PR %d (branch %s)", pr.Number, pr.Branch))
+		if flagDeleteBranches {
+			if err := githubrepo.DeleteBranch(state.ctx, branchRepo, pr.Branch); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			slog.Info(fmt.Sprintf("Deleted branch %s", pr.Branch))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -21,14 +21,19 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"path"
 	"path/filepath"
 	"slices"
 	"strings"
 	"time"
 
 	"github.com/googleapis/librarian/internal/container"
+	"github.com/googleapis/librarian/internal/githubrepo"
 	"github.com/googleapis/librarian/internal/gitrepo"
+	"github.com/googleapis/librarian/internal/offline"
+	"github.com/googleapis/librarian/internal/secrets"
 	"github.com/googleapis/librarian/internal/statepb"
+	"github.com/googleapis/librarian/internal/tracing"
 	"github.com/googleapis/librarian/internal/utils"
 )
 
@@ -43,8 +48,9 @@ type Command struct {
 	Short string
 
 	// maybeGetLanguageRepo attempts to obtain a language-specific Git
-	// repository, cloning if necessary.  Returns nil if not applicable.
-	maybeGetLanguageRepo func(workRoot string) (*gitrepo.Repo, error)
+	// repository, cloning if necessary.  Returns nil if not applicable. The context
+	// is the one the command runs with.
+	maybeGetLanguageRepo func(ctx context.Context, workRoot string) (*gitrepo.Repo, error)
 
 	// maybeLoadStateAndConfig attempts to load pipeline state and config, even if no
 	// language repo is present.
@@ -53,6 +59,10 @@ type Command struct {
 	// execute runs the command's with the provided context.
 	execute func(*commandState) error
 
+	// runsContainers reports whether the command runs the image, in which case the
+	// digest of the image is recorded (see commandState.imageDigest).
+	runsContainers bool
+
 	// flagFunctions are functions to initialize the command's flag set.
 	flagFunctions []func(fs *flag.FlagSet)
 
@@ -78,6 +88,16 @@ type commandState struct {
 	// applicable.
 	languageRepo *gitrepo.Repo
 
+	// baseCommit is the HEAD commit hash of languageRepo when the command
+	// started, recorded so that generation can be reproduced. This is empty
+	// if there is no language repo.
+	baseCommit string
+
+	// imageDigest is the digest (or comma-separated digests) of the image in
+	// containerConfig, if the command runs it and it's present locally, recorded
+	// along with baseCommit. It's updated if the image is pulled.
+	imageDigest string
+
 	// pipelineConfig holds the pipeline configuration, loaded from the
 	// language repo if present.
 	pipelineConfig *statepb.PipelineConfig
@@ -88,12 +108,57 @@ type commandState struct {
 
 	// containerConfig provides settings for running containerized commands.
 	containerConfig *container.ContainerConfig
+
+	// commitSigner signs the commits created by the command, if -sign-commits
+	// is specified; otherwise it's nil.
+	commitSigner gitrepo.Signer
+
+	// gitUserName and gitUserEmail identify the author of the commits created
+	// by the command. If they're empty, gitrepo.Commit's defaults are used.
+	gitUserName  string
+	gitUserEmail string
+
+	// forkRepo is the fork of languageRepo to push PR branches to, if -fork-repo
+	// is specified (and -push is set); otherwise it's nil.
+	forkRepo *githubrepo.GitHubRepo
+
+	// workRootResults describes the results the command has written to workRoot,
+	// for which it's retained even if the command succeeds.
+	workRootResults []string
+
+	// pullRequestURLs are the URLs of the PRs created or updated by the command,
+	// recorded in the run history.
+	pullRequestURLs []string
 }
 
 // Parse parses the provided command-line arguments using the command's flag
-// set.
+// set. Flags which aren't specified on the command line are then set from
+// environment variables, and then from the config file, if any.
 func (c *Command) Parse(args []string) error {
-	return c.flags.Parse(args)
+	if err := c.flags.Parse(args); err != nil {
+		return err
+	}
+	if err := applyEnvironmentVariables(c.flags); err != nil {
+		return err
+	}
+	if err := applyConfigFile(c.flags); err != nil {
+		return err
+	}
+	if err := normalizeLanguageFlag(); err != nil {
+		return err
+	}
+	if err := validateGeneratorInputDir(); err != nil {
+		return err
+	}
+	if err := normalizeAPIPathFlag(); err != nil {
+		return err
+	}
+	// Offline mode is enabled as soon as possible, so that it applies to
+	// everything after parsing, including setting up tracing.
+	if flagOffline {
+		offline.Enable()
+	}
+	return nil
 }
 
 // Lookup finds a command by its name, and returns an error if the command is
@@ -111,7 +176,15 @@ func Lookup(name string) (*Command, error) {
 	return cmd, nil
 }
 
-func cloneOrOpenLanguageRepo(workRoot string) (*gitrepo.Repo, error) {
+// Returns the credentials to use for git operations with remotes.
+func gitCredentials() gitrepo.Credentials {
+	return gitrepo.Credentials{
+		AccessToken: githubrepo.GetAccessToken(),
+		SSHKeyFile:  flagSSHKey,
+	}
+}
+
+func cloneOrOpenLanguageRepo(ctx context.Context, workRoot string) (*gitrepo.Repo, error) {
 	var languageRepo *gitrepo.Repo
 	if flagRepoRoot != "" && flagRepoUrl != "" {
 		return nil, errors.New("do not specify both repo-root and repo-url")
@@ -122,12 +195,22 @@ func cloneOrOpenLanguageRepo(workRoot string) (*gitrepo.Repo, error) {
 		bits := strings.Split(flagRepoUrl, "/")
 		repoName := bits[len(bits)-1]
 		repoPath := filepath.Join(workRoot, repoName)
-		return gitrepo.CloneOrOpen(repoPath, flagRepoUrl)
+		languageRepo, err := cloneOrOpenCleanRepo(repoPath, flagRepoUrl)
+		if err != nil {
+			return nil, err
+		}
+		// Check out the same ref as the pipeline state was loaded from (see -repo-ref).
+		if flagRepoRef != "" && flagRepoRef != "HEAD" {
+			if err := gitrepo.CheckoutRef(languageRepo, flagRepoRef, gitCredentials()); err != nil {
+				return nil, err
+			}
+		}
+		return languageRepo, nil
 	}
 	if flagRepoRoot == "" {
 		languageRepoURL := fmt.Sprintf("https://github.com/googleapis/google-cloud-%s", flagLanguage)
 		repoPath := filepath.Join(workRoot, fmt.Sprintf("google-cloud-%s", flagLanguage))
-		return gitrepo.CloneOrOpen(repoPath, languageRepoURL)
+		return cloneOrOpenCleanRepo(repoPath, languageRepoURL)
 	}
 	repoRoot, err := filepath.Abs(flagRepoRoot)
 	if err != nil {
@@ -137,68 +220,289 @@ func cloneOrOpenLanguageRepo(workRoot string) (*gitrepo.Repo, error) {
 	if err != nil {
 		return nil, err
 	}
-	clean, err := gitrepo.IsClean(languageRepo)
+	if err := ensureLanguageRepoClean(languageRepo); err != nil {
+		return nil, err
+	}
+	return languageRepo, nil
+}
+
+// Clones the language repo into repoPath or, if it already exists (e.g. from a previous run
+// in the same -work-root), opens it and checks that it's clean. Either way, the base branch
+// (see -base-branch) is checked out if specified, so that any commits are made on top of it.
+func cloneOrOpenCleanRepo(repoPath, repoURL string) (*gitrepo.Repo, error) {
+	_, statErr := os.Stat(repoPath)
+	languageRepo, err := gitrepo.CloneOrOpen(repoPath, repoURL, flagBaseBranch, flagCloneDepth, gitCredentials())
 	if err != nil {
 		return nil, err
 	}
-	if !clean {
-		return nil, errors.New("language repo must be clean")
+	if statErr == nil {
+		if err := ensureLanguageRepoClean(languageRepo); err != nil {
+			return nil, err
+		}
+		if flagBaseBranch != "" {
+			if err := gitrepo.CheckoutRef(languageRepo, flagBaseBranch, gitCredentials()); err != nil {
+				return nil, err
+			}
+		}
 	}
 	return languageRepo, nil
 }
 
+// The maximum number of dirty files listed when refusing to use a dirty language repo.
+const maxDirtyFilesListed = 10
+
+// Checks that the language repo has no uncommitted changes and no in-progress operation
+// (such as a rebase), e.g. left by an interrupted run, as these would otherwise cause
+// confusing failures later. With -reset-repo, the repo is reset to a clean state instead.
+// With -allow-dirty, uncommitted changes are only logged.
+func ensureLanguageRepoClean(languageRepo *gitrepo.Repo) error {
+	if flagAllowDirty && flagResetRepo {
+		return errors.New("-allow-dirty and -reset-repo cannot both be specified")
+	}
+	operation := gitrepo.InProgressOperation(languageRepo)
+	dirtyFiles, err := gitrepo.DirtyFiles(languageRepo)
+	if err != nil {
+		return err
+	}
+	if operation == "" && len(dirtyFiles) == 0 {
+		return nil
+	}
+	if flagResetRepo {
+		slog.Warn(fmt.Sprintf("Language repo %s is not clean; discarding %d changed files (-reset-repo)", languageRepo.Dir, len(dirtyFiles)))
+		return gitrepo.ResetHard(languageRepo)
+	}
+	if flagAllowDirty && operation == "" {
+		slog.Warn(fmt.Sprintf("Using language repo %s even though %s (-allow-dirty)", languageRepo.Dir, describeDirtyFiles(dirtyFiles)))
+		return nil
+	}
+	problems := []string{}
+	if operation != "" {
+		problems = append(problems, fmt.Sprintf("a %s is in progress", operation))
+	}
+	if len(dirtyFiles) > 0 {
+		problems = append(problems, describeDirtyFiles(dirtyFiles))
+	}
+	return fmt.Errorf("language repo %s must be clean, but %s; commit or discard the changes, or specify -reset-repo to discard them", languageRepo.Dir, strings.Join(problems, ", and "))
+}
+
+// Describes the files with uncommitted changes, listing the first few.
+func describeDirtyFiles(dirtyFiles []string) string {
+	listed := []string{}
+	for _, file := range dirtyFiles[:min(len(dirtyFiles), maxDirtyFilesListed)] {
+		listed = append(listed, strings.TrimSpace(file))
+	}
+	description := fmt.Sprintf("%d files have uncommitted changes: %s", len(dirtyFiles), strings.Join(listed, ", "))
+	if len(dirtyFiles) > len(listed) {
+		description += fmt.Sprintf(" (and %d more)", len(dirtyFiles)-len(listed))
+	}
+	return description
+}
+
 // RunCommand executes a given command, setting up its context including work
 // directory, language repository, pipeline state, and container configuration.
-func RunCommand(c *Command, ctx context.Context) error {
+func RunCommand(c *Command, ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "librarian "+c.Name, tracing.AttributeCommand.String(c.Name))
+	defer func() { tracing.End(span, err) }()
+
+	githubrepo.SetMaxRetries(flagGitHubRetries)
+	gitrepo.SetMaxCloneRetries(flagCloneRetries)
+	secretProvider, err := secrets.NewProvider(ctx, flagSecretProvider, secrets.ProviderOptions{
+		Project:    flagSecretsProject,
+		Dir:        flagSecretsDir,
+		VaultMount: flagVaultMount,
+	})
+	if err != nil {
+		return err
+	}
+	if secretProvider != nil {
+		githubrepo.UseSecretProvider(secretProvider)
+	}
+	if flagGitHubTokenFile != "" {
+		if err := githubrepo.LoadAccessTokenFile(flagGitHubTokenFile); err != nil {
+			return err
+		}
+	}
+	if flagGitHubAppID != 0 {
+		if flagGitHubAppInstallationID == 0 {
+			return errors.New("required flag -github-app-installation-id not specified")
+		}
+		if err := validateRequiredFlag("github-app-private-key", flagGitHubAppPrivateKey); err != nil {
+			return err
+		}
+		if err := githubrepo.UseGitHubApp(ctx, flagGitHubAppID, flagGitHubAppInstallationID, flagGitHubAppPrivateKey); err != nil {
+			return err
+		}
+	}
+
+	// Load the signing key before doing anything else, so that a missing or
+	// invalid key is reported before any (potentially long-running) work.
+	var commitSigner gitrepo.Signer
+	if flagSignCommits {
+		if err := validateRequiredFlag("signing-key", flagSigningKey); err != nil {
+			return err
+		}
+		if commitSigner, err = gitrepo.LoadSigner(flagSigningKey); err != nil {
+			return err
+		}
+	}
+
 	startTime := time.Now()
 	workRoot, err := createWorkRoot(startTime)
 	if err != nil {
 		return err
 	}
-	languageRepo, err := c.maybeGetLanguageRepo(workRoot)
+	var cmdContext *commandState
+	defer func() { cleanUpWorkRoot(ctx, workRoot, cmdContext, err) }()
+	cloneCtx, clonePhase := tracing.StartPhase(ctx, "clone")
+	languageRepo, err := c.maybeGetLanguageRepo(cloneCtx, workRoot)
+	clonePhase.End(err)
 	if err != nil {
 		return err
 	}
 
+	baseCommit := ""
+	if languageRepo != nil {
+		baseCommit, err = gitrepo.HeadHash(languageRepo)
+		if err != nil {
+			return err
+		}
+		slog.Info(fmt.Sprintf("Language repo base commit: %s", baseCommit))
+	}
+
+	_, loadPhase := tracing.StartPhase(ctx, "load-state")
 	state, config, err := c.maybeLoadStateAndConfig(languageRepo)
+	loadPhase.End(err)
 	if err != nil {
 		return err
 	}
 
-	image := deriveImage(state)
-	containerConfig, err := container.NewContainerConfig(ctx, workRoot, image, flagSecretsProject, config)
+	image, err := deriveImage(state)
 	if err != nil {
 		return err
 	}
+	containerConfig, err := container.NewContainerConfig(ctx, workRoot, image, secretProvider, config)
+	if err != nil {
+		return err
+	}
+	containerConfig.ValidationImage = flagValidateImage
+	containerConfig.Retries = flagContainerRetries
+	containerConfig.LocalGenerator = flagLocalGenerator
+	containerConfig.Environment = flagContainerEnv
+	if flagContainerLogs {
+		containerConfig.LogDir = filepath.Join(workRoot, "logs")
+	}
+	if containerConfig.Runtime, err = container.NewRuntime(flagContainerRuntime); err != nil {
+		return err
+	}
+	if flagRegistryConfig != "" {
+		if containerConfig.RegistryConfig, err = filepath.Abs(flagRegistryConfig); err != nil {
+			return err
+		}
+		if _, err := os.Stat(containerConfig.RegistryConfig); err != nil {
+			return fmt.Errorf("invalid -registry-config: %w", err)
+		}
+	}
 
-	cmdContext := &commandState{
+	cmdContext = &commandState{
 		ctx:             ctx,
 		startTime:       startTime,
 		workRoot:        workRoot,
 		languageRepo:    languageRepo,
+		baseCommit:      baseCommit,
 		pipelineConfig:  config,
 		pipelineState:   state,
 		containerConfig: containerConfig,
+		commitSigner:    commitSigner,
+		gitUserName:     flagGitUserName,
+		gitUserEmail:    flagGitUserEmail,
 	}
+	if flagContainerLogs {
+		retainWorkRoot(cmdContext, "container logs")
+	}
+	if c.runsContainers && containerConfig.LocalGenerator == "" {
+		if digests, err := container.ImageDigests(ctx, containerConfig); err == nil {
+			cmdContext.imageDigest = strings.Join(digests, ",")
+		}
+	}
+	logResolvedConfiguration(c, cmdContext)
+	defer func() { maybeAppendRunHistory(c, cmdContext, err) }()
 	return c.execute(cmdContext)
 }
 
+// Records that the command has written results to the work root, so that it's not
+// removed when the command succeeds.
+func retainWorkRoot(state *commandState, results string) {
+	state.workRootResults = append(state.workRootResults, results)
+}
+
+// Removes the work root created for a successful run, unless -keep-work-root is specified
+// or the command has written results to it. A work root specified with -work-root is never
+// removed. After a failed run, the work root is kept so that it can be inspected, unless
+// the command was cancelled (e.g. by SIGINT), in which case it's removed as after success.
+func cleanUpWorkRoot(ctx context.Context, workRoot string, state *commandState, err error) {
+	cancelled := ctx.Err() != nil
+	switch {
+	case err != nil && !cancelled:
+		slog.Warn(fmt.Sprintf("Command failed; work root retained for inspection: %s", workRoot))
+	case flagWorkRoot != "":
+		return
+	case flagKeepWorkRoot:
+		slog.Info(fmt.Sprintf("Work root retained: %s", workRoot))
+	case state != nil && len(state.workRootResults) > 0 && !cancelled:
+		slog.Info(fmt.Sprintf("Work root retained, as it contains %s: %s", strings.Join(state.workRootResults, ", "), workRoot))
+	default:
+		// RemoveAll doesn't follow symlinks, so nothing outside the work root (such as a
+		// language repo specified with -repo-root) is removed.
+		if err := os.RemoveAll(workRoot); err != nil {
+			slog.Warn(fmt.Sprintf("Failed to remove work root %s: %s", workRoot, err))
+			return
+		}
+		slog.Info(fmt.Sprintf("Removed work root %s", workRoot))
+	}
+}
+
 func appendResultEnvironmentVariable(state *commandState, name, value string) error {
 	envFile := flagEnvFile
 	if envFile == "" {
 		envFile = filepath.Join(state.workRoot, "env-vars.txt")
+		if !slices.Contains(state.workRootResults, "result environment variables") {
+			retainWorkRoot(state, "result environment variables")
+		}
 	}
 
 	return utils.AppendToFile(envFile, fmt.Sprintf("%s=%s\n", name, value))
 }
 
-func deriveImage(state *statepb.PipelineState) string {
-	if flagImage != "" {
-		return flagImage
+// Returns the image specified by -image or, failing that, the image for -language in the
+// -images mapping (if specified), or the language's default generator image.
+func deriveImage(state *statepb.PipelineState) (string, error) {
+	image := flagImage
+	if image == "" && len(flagImages) > 0 {
+		mapped, languages := "", []string{}
+		for _, entry := range flagImages {
+			language, languageImage, _ := strings.Cut(entry, "=")
+			if language == flagLanguage {
+				mapped = languageImage
+			}
+			languages = append(languages, language)
+		}
+		if mapped == "" {
+			return "", fmt.Errorf("-images has no image for language %q (only for %s), and -image isn't specified", flagLanguage, strings.Join(languages, ", "))
+		}
+		image = mapped
+	}
+	return imageFor(image, flagLanguage, state), nil
+}
+
+// Returns the image if specified; otherwise, the language's generator image, with the
+// pipeline state's image tag.
+func imageFor(image, language string, state *statepb.PipelineState) string {
+	if image != "" {
+		return image
 	}
 
 	defaultRepository := os.Getenv(defaultRepositoryEnvironmentVariable)
-	relativeImage := fmt.Sprintf("google-cloud-%s-generator", flagLanguage)
+	relativeImage := fmt.Sprintf("google-cloud-%s-generator", language)
 
 	var tag string
 	if state == nil {
@@ -213,16 +517,61 @@ func deriveImage(state *statepb.PipelineState) string {
 	}
 }
 
+// Returns the absolute paths of the API include roots (the -api-root values after the
+// first), checking that each is a directory.
+func resolveAPIIncludeRoots(includeRoots []string) ([]string, error) {
+	resolved := []string{}
+	for _, includeRoot := range includeRoots {
+		absoluteRoot, err := filepath.Abs(includeRoot)
+		if err != nil {
+			return nil, err
+		}
+		if info, err := os.Stat(absoluteRoot); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("api include root %s is not a directory", absoluteRoot)
+		}
+		resolved = append(resolved, absoluteRoot)
+	}
+	return resolved, nil
+}
+
+// Normalizes an API path so that equivalent paths compare equal: backslashes (as used on
+// Windows) are converted to forward slashes, leading and trailing slashes are removed, and
+// the result is cleaned as by path.Clean (collapsing repeated slashes and resolving "." and
+// ".." elements). For example, "/google/foo/", `google\foo` and "google/./bar/../foo" are all
+// normalized to "google/foo". A path which is empty after cleaning is normalized to "".
+func normalizeAPIPath(apiPath string) string {
+	apiPath = strings.Trim(strings.ReplaceAll(strings.TrimSpace(apiPath), `\`, "/"), "/")
+	if apiPath == "" {
+		return ""
+	}
+	if apiPath = path.Clean(apiPath); apiPath == "." {
+		return ""
+	}
+	return apiPath
+}
+
 // Finds a library which includes code generated from the given API path.
 // If there are no such libraries, an empty string is returned.
-// If there are multiple such libraries, the first match is returned.
-func findLibraryIDByApiPath(state *statepb.PipelineState, apiPath string) string {
+// If there are multiple such libraries, an error naming them is returned,
+// as there's no way of telling which one is intended. API paths are normalized
+// (see normalizeAPIPath) before comparison, both in the state and as specified.
+func findLibraryIDByApiPath(state *statepb.PipelineState, apiPath string) (string, error) {
+	apiPath = normalizeAPIPath(apiPath)
+	var libraryIDs []string
 	for _, library := range state.Libraries {
-		if slices.Contains(library.ApiPaths, apiPath) {
-			return library.Id
+		if slices.ContainsFunc(library.ApiPaths, func(libraryApiPath string) bool { return normalizeAPIPath(libraryApiPath) == apiPath }) {
+			libraryIDs = append(libraryIDs, library.Id)
 		}
 	}
-	return ""
+	switch len(libraryIDs) {
+	case 0:
+		return "", nil
+	case 1:
+		return libraryIDs[0], nil
+	default:
+		return "", fmt.Errorf("API path %s is claimed by multiple libraries (%s); fix the pipeline state so that only one library includes it",
+			apiPath, strings.Join(libraryIDs, ", "))
+	}
 }
 
 func findLibraryByID(state *statepb.PipelineState, libraryID string) *statepb.LibraryState {
@@ -234,9 +583,24 @@ func findLibraryByID(state *statepb.PipelineState, libraryID string) *statepb.Li
 	return nil
 }
 
+// Formats a timestamp for use in identifiers such as branch names, release IDs and work
+// root directories. This is always in UTC, and includes milliseconds so that runs started
+// within the same second don't collide.
 func formatTimestamp(t time.Time) string {
-	const yyyyMMddHHmmss = "20060102T150405Z" // Expected format by time library
-	return t.Format(yyyyMMddHHmmss)
+	const yyyyMMddHHmmssSSS = "20060102T150405.000Z" // Expected format by time library
+	return t.UTC().Format(yyyyMMddHHmmssSSS)
+}
+
+// Formats a timestamp for PR titles, in the timezone specified with -title-timezone.
+// The offset is included unless the timezone is UTC ("Z").
+func formatTitleTimestamp(t time.Time) string {
+	const yyyyMMddHHmmssZ = "20060102T150405Z0700" // Expected format by time library
+	location, err := time.LoadLocation(flagTitleTimezone)
+	if err != nil {
+		// This has already been validated by validateTitleTimezone.
+		location = time.Local
+	}
+	return t.In(location).Format(yyyyMMddHHmmssZ)
 }
 
 func createWorkRoot(t time.Time) (string, error) {
@@ -264,7 +628,10 @@ func createWorkRoot(t time.Time) (string, error) {
 }
 
 // No commit is made if there are no file modifications.
-func commitAll(repo *gitrepo.Repo, msg string) error {
+func commitAll(state *commandState, repo *gitrepo.Repo, msg string) (err error) {
+	_, span := tracing.Start(state.ctx, "commit")
+	defer func() { tracing.End(span, err) }()
+
 	status, err := gitrepo.AddAll(repo)
 	if err != nil {
 		return err
@@ -274,27 +641,60 @@ func commitAll(repo *gitrepo.Repo, msg string) error {
 		return nil
 	}
 
-	return gitrepo.Commit(repo, msg, flagGitUserName, flagGitUserEmail)
+	return gitrepo.Commit(repo, msg, state.gitUserName, state.gitUserEmail, state.commitSigner)
+}
+
+// Returns an error if the command has been cancelled (e.g. by SIGINT), so that a loop over
+// libraries or APIs stops rather than failing for each remaining one.
+func cancelledError(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("command cancelled: %w", err)
+	}
+	return nil
 }
 
 // Log details of an error which prevents a single API or library from being configured/released, but without
 // halting the overall process. Return a brief description to the errors to include in the PR.
-// We don't include detailed errors in the PR, as this could reveal sensitive information.
+// We don't include detailed errors in the PR by default, as this could reveal sensitive information,
+// but they can be included with -verbose-pr-errors.
 // The action should describe what failed, e.g. "configuring", "building", "generating".
+// If a container failed, its exit code is always included.
 func logPartialError(id string, err error, action string) string {
 	slog.Warn(fmt.Sprintf("Error while %s %s: %s", action, id, err))
-	return fmt.Sprintf("Error while %s %s", action, id)
+	if flagVerbosePRErrors {
+		return fmt.Sprintf("Error while %s %s: %s", action, id, err)
+	}
+	return fmt.Sprintf("Error while %s %s%s", action, id, exitCodeSuffix(err))
+}
+
+// Returns a description of the exit code of the container which caused err, if any,
+// such as " (exit code 1)", or an empty string.
+func exitCodeSuffix(err error) string {
+	var exitErr *container.ExitError
+	if !errors.As(err, &exitErr) {
+		return ""
+	}
+	return fmt.Sprintf(" (exit code %d)", exitErr.ExitCode)
 }
 
 var Commands = []*Command{
 	CmdConfigure,
 	CmdGenerate,
+	CmdRegenerateAll,
 	CmdUpdateApis,
 	CmdCreateReleasePR,
 	CmdUpdateImageTag,
 	CmdMergeReleasePR,
 	CmdCreateReleaseArtifacts,
 	CmdPublishReleaseArtifacts,
+	CmdListLibraries,
+	CmdSchema,
+	CmdStatus,
+	CmdBuild,
+	CmdClean,
+	CmdClosePRs,
+	CmdDoctor,
+	CmdCleanContainers,
 }
 
 func init() {
@@ -304,6 +704,26 @@ func init() {
 		for _, fn := range c.flagFunctions {
 			fn(c.flags)
 		}
+		// Every command supports a config file, with profiles of flag values.
+		addFlagConfig(c.flags)
+		addFlagConfigProfile(c.flags)
+		// Every command can be run without network access (as far as it's able to).
+		addFlagOffline(c.flags)
+		// Every command uses a work root, which is removed after a successful run by default.
+		addFlagKeepWorkRoot(c.flags)
+		// Every command which uses a language repo reads its generator input.
+		addFlagGeneratorInputDir(c.flags)
+		// Every command logs.
+		addFlagLogFormat(c.flags)
+		addFlagLogLevel(c.flags)
+		// Every command may make GitHub API requests.
+		addFlagGitHubAppID(c.flags)
+		addFlagGitHubAppInstallationID(c.flags)
+		addFlagGitHubAppPrivateKey(c.flags)
+		addFlagGitHubRetries(c.flags)
+		addFlagGitHubTokenFile(c.flags)
+		// Every command may clone repos.
+		addFlagCloneRetries(c.flags)
 	}
 }
 
@@ -313,7 +733,9 @@ func constructUsage(fs *flag.FlagSet, name string) func() {
 	return func() {
 		fmt.Fprint(fs.Output(), output)
 		fs.PrintDefaults()
-		fmt.Fprintf(fs.Output(), "\n\n")
+		fmt.Fprintf(fs.Output(), "\nEach flag may also be set with an environment variable named after the flag: "+
+			"uppercase, with dashes replaced by underscores and a %s prefix (for example, %s for -api-root). "+
+			"Flags specified on the command line take precedence.\n\n", flagEnvironmentVariablePrefix, flagEnvironmentVariable("api-root"))
 	}
 }
 
@@ -0,0 +1,180 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"testing"
+
+	"github.com/googleapis/librarian/internal/statepb"
+)
+
+func TestNormalizeAPIPath(t *testing.T) {
+	tests := []struct {
+		apiPath string
+		want    string
+	}{
+		{
+			apiPath: "google/cloud/functions/v2",
+			want:    "google/cloud/functions/v2",
+		},
+		{
+			apiPath: "google/cloud/functions/v2/",
+			want:    "google/cloud/functions/v2",
+		},
+		{
+			apiPath: "/google/cloud/functions/v2",
+			want:    "google/cloud/functions/v2",
+		},
+		{
+			apiPath: `google\cloud\functions\v2`,
+			want:    "google/cloud/functions/v2",
+		},
+		{
+			apiPath: ` \google//cloud\functions/v2\ `,
+			want:    "google/cloud/functions/v2",
+		},
+		{
+			apiPath: "google/./cloud/functions/v2",
+			want:    "google/cloud/functions/v2",
+		},
+		{
+			apiPath: "./google/cloud/functions/v2",
+			want:    "google/cloud/functions/v2",
+		},
+		{
+			apiPath: "google/cloud/other/../functions/v2",
+			want:    "google/cloud/functions/v2",
+		},
+		{
+			apiPath: "google/..",
+			want:    "",
+		},
+		{
+			apiPath: "/",
+			want:    "",
+		},
+	}
+	for _, test := range tests {
+		got := normalizeAPIPath(test.apiPath)
+		if test.want != got {
+			t.Errorf("normalizeAPIPath(%q) expected %q, got %q", test.apiPath, test.want, got)
+		}
+	}
+}
+
+func TestFindLibraryIDByApiPath(t *testing.T) {
+	state := &statepb.PipelineState{
+		Libraries: []*statepb.LibraryState{
+			{
+				Id:       "functions",
+				ApiPaths: []string{"google/cloud/functions/v2"},
+			},
+			{
+				// Paths in the state are normalized too.
+				Id:       "storage",
+				ApiPaths: []string{"google/storage/v2/"},
+			},
+		},
+	}
+	tests := []struct {
+		apiPath string
+		want    string
+	}{
+		{
+			apiPath: "google/cloud/functions/v2",
+			want:    "functions",
+		},
+		{
+			apiPath: "google/cloud/functions/v2/",
+			want:    "functions",
+		},
+		{
+			apiPath: `google\cloud\functions\v2`,
+			want:    "functions",
+		},
+		{
+			apiPath: "google/storage/v2",
+			want:    "storage",
+		},
+		{
+			apiPath: "google/./cloud/functions/v2",
+			want:    "functions",
+		},
+		{
+			apiPath: "google/cloud/functions",
+			want:    "",
+		},
+	}
+	for _, test := range tests {
+		got, err := findLibraryIDByApiPath(state, test.apiPath)
+		if err != nil {
+			t.Errorf("findLibraryIDByApiPath(%q); got error %v", test.apiPath, err)
+			continue
+		}
+		if test.want != got {
+			t.Errorf("findLibraryIDByApiPath(%q) expected %q, got %q", test.apiPath, test.want, got)
+		}
+	}
+}
+
+func TestNormalizeAPIPathFlag(t *testing.T) {
+	defer func(original string) { flagAPIPath = original }(flagAPIPath)
+	tests := []struct {
+		value string
+		want  string // Empty for an error
+	}{
+		{
+			value: "google/foo/v1/, /google/bar/v1",
+			want:  "google/foo/v1,google/bar/v1",
+		},
+		{
+			value: `google\foo\v1`,
+			want:  "google/foo/v1",
+		},
+		{
+			value: "google/../../etc",
+			want:  "",
+		},
+		{
+			value: `C:\googleapis\google\foo`,
+			want:  "",
+		},
+		{
+			value: "/",
+			want:  "",
+		},
+		{
+			value: "google/..",
+			want:  "",
+		},
+	}
+	for _, test := range tests {
+		flagAPIPath = test.value
+		err := normalizeAPIPathFlag()
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("normalizeAPIPathFlag() with -api-path=%q; error expected", test.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("normalizeAPIPathFlag() with -api-path=%q; got error %v", test.value, err)
+			continue
+		}
+		if test.want != flagAPIPath {
+			t.Errorf("normalizeAPIPathFlag() with -api-path=%q expected %q, got %q", test.value, test.want, flagAPIPath)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFile is the YAML file specified with -config. Top-level keys are flag names
+// (without the leading "-"), giving values which apply to every command invocation using
+// the file; flags which aren't valid for a command are ignored, so a single file can be
+// shared between commands. Each profile is a named set of flag values, selected with
+// -config-profile, which take precedence over the top-level values. Repeatable flags
+// (such as -pr-label) may be given a list of values. For example:
+//
+//	language: dotnet
+//	repo-root: /src/google-cloud-dotnet
+//	pr-label: [automerge, generated]
+//	profiles:
+//	  nightly:
+//	    push: true
+//	    build: true
+//	  hotfix:
+//	    skip-integration-tests: b/12345
+type ConfigFile struct {
+	Profiles map[string]map[string]interface{} `yaml:"profiles"`
+	Flags    map[string]interface{}            `yaml:",inline"`
+}
+
+// Applies the flag values from the file specified with -config (if any) to the given
+// flag set. Flags specified explicitly on the command line take precedence over the
+// profile selected with -config-profile, which takes precedence over the top-level values
+// in the file, which in turn take precedence over the flag defaults.
+func applyConfigFile(fs *flag.FlagSet) error {
+	if flagConfig == "" {
+		if flagConfigProfile != "" {
+			return errors.New("-config-profile requires -config to be specified")
+		}
+		return nil
+	}
+	config, err := loadConfigFile(flagConfig)
+	if err != nil {
+		return err
+	}
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	if flagConfigProfile != "" {
+		profile, ok := config.Profiles[flagConfigProfile]
+		if !ok {
+			return fmt.Errorf("profile %q not found in config file %s", flagConfigProfile, flagConfig)
+		}
+		source := fmt.Sprintf("profile %q", flagConfigProfile)
+		if err := applyConfigValues(fs, profile, explicit, source, false); err != nil {
+			return err
+		}
+		slog.Info(fmt.Sprintf("Applied config profile %s from %s", flagConfigProfile, flagConfig))
+	}
+	// The flags set by the profile are now treated as explicit.
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	return applyConfigValues(fs, config.Flags, explicit, "config file "+flagConfig, true)
+}
+
+// Sets flags from a map of values, skipping any which are already explicitly set. If
+// ignoreUnknown is true, values for flags which aren't in the flag set are ignored; otherwise
+// they're reported as an error. The source is used in error messages.
+func applyConfigValues(fs *flag.FlagSet, values map[string]interface{}, explicit map[string]bool, source string, ignoreUnknown bool) error {
+	// Apply the values in a consistent order, for predictable error reporting.
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "config" || name == "config-profile" {
+			return fmt.Errorf("%s cannot specify -%s", source, name)
+		}
+		if fs.Lookup(name) == nil {
+			if ignoreUnknown {
+				continue
+			}
+			return fmt.Errorf("%s specifies flag -%s, which is not valid for %s", source, name, fs.Name())
+		}
+		if explicit[name] {
+			continue
+		}
+		// A list provides multiple values for a repeatable flag.
+		items, ok := values[name].([]interface{})
+		if !ok {
+			items = []interface{}{values[name]}
+		}
+		for _, value := range items {
+			switch value.(type) {
+			case string, bool, int, float64:
+			default:
+				return fmt.Errorf("%s specifies a non-scalar value for -%s", source, name)
+			}
+			if err := fs.Set(name, fmt.Sprint(value)); err != nil {
+				return fmt.Errorf("%s specifies an invalid value for -%s: %w", source, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// The prefix of the environment variables which may be used to set flags.
+const flagEnvironmentVariablePrefix = "LIBRARIAN_"
+
+// Returns the name of the environment variable which may be used to set the flag with the
+// given name, e.g. LIBRARIAN_API_ROOT for api-root.
+func flagEnvironmentVariable(name string) string {
+	return flagEnvironmentVariablePrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// Sets each flag not specified on the command line from its environment variable, if that's
+// set and non-empty. Flags set this way take precedence over the config file.
+func applyEnvironmentVariables(fs *flag.FlagSet) error {
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || explicit[f.Name] {
+			return
+		}
+		name := flagEnvironmentVariable(f.Name)
+		value := os.Getenv(name)
+		if value == "" {
+			return
+		}
+		if setErr := fs.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("invalid value for -%s from environment variable %s: %w", f.Name, name, setErr)
+		}
+	})
+	return err
+}
+
+func loadConfigFile(path string) (*ConfigFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	config := &ConfigFile{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return config, nil
+}
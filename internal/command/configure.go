@@ -36,24 +36,72 @@ var CmdConfigure = &Command{
 	Short: "Set up a new API for a language.",
 	flagFunctions: []func(fs *flag.FlagSet){
 		addFlagImage,
+		addFlagImages,
 		addFlagWorkRoot,
 		addFlagAPIPath,
 		addFlagAPIRoot,
 		addFlagGitUserEmail,
 		addFlagGitUserName,
+		addFlagSignCommits,
+		addFlagSigningKey,
 		addFlagLanguage,
 		addFlagPush,
+		addFlagForkRepo,
 		addFlagRepoRoot,
+		addFlagResetRepo,
 		addFlagRepoUrl,
+		addFlagSSHKey,
+		addFlagCloneDepth,
+		addFlagSecretProvider,
+		addFlagSecretsDir,
 		addFlagSecretsProject,
+		addFlagVaultMount,
+		addFlagLineEndings,
+		addFlagPostGenerateHook,
+		addFlagPRAutoMerge,
+		addFlagPRAutoMergeMethod,
+		addFlagDraft,
+		addFlagPRLabel,
+		addFlagPRReviewer,
+		addFlagPRAssignee,
+		addFlagBranchPrefix,
+		addFlagBaseBranch,
+		addFlagHistoryFile,
+		addFlagBranchTemplate,
+		addFlagTitleTimezone,
+		addFlagKeepBranchOnFailure,
+		addFlagWaitForChecks,
+		addFlagChecksTimeout,
+		addFlagVerbosePRErrors,
+		addFlagFailFast,
+		addFlagUpdateExisting,
+		addFlagExistingPRAuthor,
+		addFlagExistingPRLabel,
+		addFlagContainerRetries,
+		addFlagContainerRuntime,
+		addFlagLocalGenerator,
+		addFlagRegistryConfig,
+		addFlagContainerLogs,
+		addFlagContainerEnv,
 	},
 	maybeGetLanguageRepo:    cloneOrOpenLanguageRepo,
 	maybeLoadStateAndConfig: loadRepoStateAndConfig,
 	execute:                 runConfigure,
+	runsContainers:          true,
 }
 
 func runConfigure(state *commandState) error {
-	if err := validatePush(); err != nil {
+	if err := validatePush(state); err != nil {
+		return err
+	}
+	if err := validateLineEndings(flagLineEndings); err != nil {
+		return err
+	}
+	prOpts := pullRequestOptionsFromFlags()
+	if err := validatePRAutoMerge(prOpts); err != nil {
+		return err
+	}
+	if err := validateTitleTimezone(); err != nil {
 		return err
 	}
 
@@ -63,6 +111,11 @@ func runConfigure(state *commandState) error {
 	}
 	slog.Info(fmt.Sprintf("Code will be generated in %s", outputRoot))
 
+	includeRoots, err := resolveAPIIncludeRoots(flagAPIIncludeRoots)
+	if err != nil {
+		return err
+	}
+
 	var apiRoot string
 	if flagAPIRoot == "" {
 		repo, err := cloneGoogleapis(state.workRoot)
@@ -86,13 +139,19 @@ func runConfigure(state *commandState) error {
 
 	prContent := PullRequestContent{}
 	for _, apiPath := range apiPaths {
-		err = configureApi(state, outputRoot, apiRoot, apiPath, &prContent)
+		err = configureApi(state, outputRoot, apiRoot, includeRoots, apiPath, &prContent)
 		if err != nil {
 			return err
 		}
+		if err := failFastError(&prContent, prOpts.failFast); err != nil {
+			return err
+		}
+		if err := cancelledError(state.ctx); err != nil {
+			return err
+		}
 	}
 
-	_, err = createPullRequest(state, &prContent, "feat: API configuration", "", "config")
+	_, err = createPullRequest(state, prOpts, &prContent, "feat: API configuration", "", "config")
 	return err
 }
 
@@ -119,7 +178,11 @@ func findApisToConfigure(apiRoot string, state *statepb.PipelineState, language
 				return err
 			}
 			// If we already generate this library, skip the rest of this directory.
-			if findLibraryIDByApiPath(state, apiPath) != "" || slices.Contains(state.IgnoredApiPaths, apiPath) {
+			libraryID, err := findLibraryIDByApiPath(state, apiPath)
+			if err != nil {
+				return err
+			}
+			if libraryID != "" || slices.Contains(state.IgnoredApiPaths, apiPath) {
 				return filepath.SkipDir
 			}
 
@@ -217,13 +280,13 @@ func shouldBeGenerated(serviceYamlPath, languageSettingsName string) (bool, erro
 //
 // This function only returns an error in the case of non-container failures, which are expected to be fatal.
 // If the function returns a non-error, the repo will be clean when the function returns (so can be used for the next step)
-func configureApi(state *commandState, outputRoot, apiRoot, apiPath string, prContent *PullRequestContent) error {
+func configureApi(state *commandState, outputRoot, apiRoot string, includeRoots []string, apiPath string, prContent *PullRequestContent) error {
 	containerConfig := state.containerConfig
 	languageRepo := state.languageRepo
 
 	slog.Info(fmt.Sprintf("Configuring %s", apiPath))
 
-	generatorInput := filepath.Join(languageRepo.Dir, "generator-input")
+	generatorInput := generatorInputDir(languageRepo.Dir)
 	if err := container.Configure(containerConfig, apiRoot, apiPath, generatorInput); err != nil {
 		addErrorToPullRequest(prContent, apiPath, err, "configuring")
 		if err := gitrepo.CleanWorkingTree(languageRepo); err != nil {
@@ -244,15 +307,22 @@ func configureApi(state *commandState, outputRoot, apiRoot, apiPath string, prCo
 	}
 
 	// We should now have a library for the given API path, or it should be ignored.
-	libraryID := findLibraryIDByApiPath(ps, apiPath)
+	libraryID, err := findLibraryIDByApiPath(ps, apiPath)
+	if err != nil {
+		addErrorToPullRequest(prContent, apiPath, err, "finding new library for")
+		if err := gitrepo.CleanWorkingTree(languageRepo); err != nil {
+			return err
+		}
+		return nil
+	}
 	if libraryID == "" {
 		// If it's newly-ignored, just commit the state change. This is still a "success" case.
 		if slices.Contains(ps.IgnoredApiPaths, apiPath) {
 			msg := fmt.Sprintf("feat: Added ignore entry for API %s", apiPath)
-			if err := commitAll(languageRepo, msg); err != nil {
+			if err := commitAll(state, languageRepo, msg); err != nil {
 				return err
 			}
-			addSuccessToPullRequest(prContent, fmt.Sprintf("Ignored API %s", apiPath))
+			addSuccessToPullRequest(prContent, apiPath, "ignoring", fmt.Sprintf("Ignored API %s", apiPath))
 			return nil
 		}
 		addErrorToPullRequest(prContent, apiPath, err, "finding new library for")
@@ -263,7 +333,7 @@ func configureApi(state *commandState, outputRoot, apiRoot, apiPath string, prCo
 	}
 
 	msg := fmt.Sprintf("feat: Configured library %s for API %s", libraryID, apiPath)
-	if err := commitAll(languageRepo, msg); err != nil {
+	if err := commitAll(state, languageRepo, msg); err != nil {
 		return err
 	}
 
@@ -274,26 +344,40 @@ func configureApi(state *commandState, outputRoot, apiRoot, apiPath string, prCo
 		return err
 	}
 
-	if err := container.GenerateLibrary(containerConfig, apiRoot, outputDir, generatorInput, libraryID); err != nil {
-		prContent.Errors = append(prContent.Errors, logPartialError(libraryID, err, "generating"))
+	if err := container.GenerateLibrary(state.ctx, containerConfig, apiRoot, includeRoots, outputDir, generatorInput, libraryID); err != nil {
+		addErrorToPullRequest(prContent, libraryID, err, "generating")
+		if err := gitrepo.CleanAndRevertHeadCommit(languageRepo); err != nil {
+			return err
+		}
+		return nil
+	}
+	if err := checkGeneratorOutput(outputDir, libraryID); err != nil {
+		addErrorToPullRequest(prContent, libraryID, err, "generating")
+		if err := gitrepo.CleanAndRevertHeadCommit(languageRepo); err != nil {
+			return err
+		}
+		return nil
+	}
+	if err := maybeRunPostGenerateHook(state.ctx, flagPostGenerateHook, outputDir, libraryID); err != nil {
+		addErrorToPullRequest(prContent, libraryID, err, "generating")
 		if err := gitrepo.CleanAndRevertHeadCommit(languageRepo); err != nil {
 			return err
 		}
 		return nil
 	}
-	if err := container.Clean(containerConfig, languageRepo.Dir, libraryID); err != nil {
-		prContent.Errors = append(prContent.Errors, logPartialError(libraryID, err, "cleaning"))
+	if err := cleanLibrary(state.ctx, state, languageRepo.Dir, libraryID); err != nil {
+		addErrorToPullRequest(prContent, libraryID, err, "cleaning")
 		if err := gitrepo.CleanAndRevertHeadCommit(languageRepo); err != nil {
 			return err
 		}
 		return nil
 	}
 	// If the copy operation fails, it's fine to just fail hard.
-	if err := os.CopyFS(languageRepo.Dir, os.DirFS(outputDir)); err != nil {
+	if err := copyGeneratedCode(languageRepo.Dir, outputDir, findLibraryByID(ps, libraryID), flagLineEndings); err != nil {
 		return err
 	}
-	if err := container.BuildLibrary(containerConfig, languageRepo.Dir, libraryID); err != nil {
-		prContent.Errors = append(prContent.Errors, logPartialError(libraryID, err, "building"))
+	if err := container.BuildLibrary(state.ctx, containerConfig, languageRepo.Dir, libraryID); err != nil {
+		addErrorToPullRequest(prContent, libraryID, err, "building")
 		if err := gitrepo.CleanAndRevertHeadCommit(languageRepo); err != nil {
 			return err
 		}
@@ -304,6 +388,6 @@ func configureApi(state *commandState, outputRoot, apiRoot, apiPath string, prCo
 	if err := gitrepo.CleanWorkingTree(languageRepo); err != nil {
 		return err
 	}
-	addSuccessToPullRequest(prContent, fmt.Sprintf("Configured library %s for API %s", libraryID, apiPath))
+	addSuccessToPullRequest(prContent, libraryID, "configuring", fmt.Sprintf("Configured library %s for API %s", libraryID, apiPath))
 	return nil
 }
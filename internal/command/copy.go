@@ -0,0 +1,361 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/googleapis/librarian/internal/statepb"
+)
+
+// The values accepted by -line-endings.
+const (
+	lineEndingsPreserve = "preserve"
+	lineEndingsLF       = "lf"
+	lineEndingsCRLF     = "crlf"
+)
+
+// The number of bytes at the start of a file which are checked for a NUL byte
+// when determining whether the file is binary. (This is the same heuristic as Git uses.)
+const binaryDetectionLength = 8000
+
+const mebibyte = 1024 * 1024
+
+// Files at least this large have progress logged while they're copied, every
+// progressInterval bytes.
+const largeFileThreshold = 64 * mebibyte
+const progressInterval = 64 * mebibyte
+
+// The size of the read and write buffers used when copying. This must be larger
+// than binaryDetectionLength.
+const copyBufferSize = 64 * 1024
+
+// Copies a library's generated code from outputDir into destDir (typically the language
+// repo), normalizing line endings in text files as specified by lineEndings. Only the
+// library's source paths are copied, each to the same location in destDir, so that other
+// files the generator emits (e.g. other libraries, or top-level files) don't end up at the
+// root of the repo. If the library has no source paths (or is nil), all of outputDir is
+// copied. As with os.CopyFS, existing files are never overwritten: an error satisfying
+// errors.Is(err, fs.ErrExist) is returned instead. Files protected by the .librarianignore
+// file in destDir aren't copied.
+func copyGeneratedCode(destDir, outputDir string, library *statepb.LibraryState, lineEndings string) error {
+	ignore, err := loadLibrarianIgnore(destDir)
+	if err != nil {
+		return err
+	}
+	var sourcePaths []string
+	if library != nil {
+		for _, sourcePath := range library.SourcePaths {
+			sourcePaths = append(sourcePaths, filepath.ToSlash(filepath.Clean(sourcePath)))
+		}
+	}
+	return copyDirFiltered(destDir, outputDir, false, lineEndings, ignore, sourcePaths)
+}
+
+// Reports whether the path (relative to the output directory, with forward slashes) is
+// within one of the source paths. Directories which contain a source path are also included,
+// so that they're walked. Everything is within an empty list of source paths.
+func withinSourcePaths(relative string, isDir bool, sourcePaths []string) bool {
+	if len(sourcePaths) == 0 || relative == "." {
+		return true
+	}
+	for _, sourcePath := range sourcePaths {
+		if sourcePath == "." || relative == sourcePath || strings.HasPrefix(relative, sourcePath+"/") {
+			return true
+		}
+		if isDir && strings.HasPrefix(sourcePath, relative+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Removes files from the library's generated tree in destDir which the generator didn't
+// emit into outputDir, after the generated code has been copied (for -prune).
+// The generated tree consists of those of the library's source paths which are directories
+// in outputDir; files outside those directories (including handwritten code elsewhere in
+// the library) and files protected by .librarianignore are never removed. A source path
+// which is the repo root (or outside it) is rejected, as pruning it could remove anything.
+func pruneGeneratedCode(destDir, outputDir string, library *statepb.LibraryState) error {
+	for _, sourcePath := range library.SourcePaths {
+		if cleaned := filepath.Clean(sourcePath); cleaned == "." || !filepath.IsLocal(cleaned) {
+			return fmt.Errorf("cannot prune library %s: source path %q must be a subdirectory of the repo", library.Id, sourcePath)
+		}
+	}
+	ignore, err := loadLibrarianIgnore(destDir)
+	if err != nil {
+		return err
+	}
+	pruned := 0
+	for _, sourcePath := range library.SourcePaths {
+		if info, err := os.Stat(filepath.Join(outputDir, sourcePath)); err != nil || !info.IsDir() {
+			continue
+		}
+		count, err := pruneDir(filepath.Join(destDir, sourcePath), filepath.Join(outputDir, sourcePath), ignore)
+		if err != nil {
+			return fmt.Errorf("failed to prune %s: %w", sourcePath, err)
+		}
+		pruned += count
+	}
+	slog.Info(fmt.Sprintf("Pruned %d stale generated files for library %s", pruned, library.Id))
+	return nil
+}
+
+// Removes files (and then empty directories) from destDir which don't exist in
+// sourceDir and aren't protected, returning the number of files removed. Git metadata
+// is never removed.
+func pruneDir(destDir, sourceDir string, ignore *librarianIgnore) (int, error) {
+	pruned := 0
+	var dirs []string
+	err := filepath.WalkDir(destDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(destDir, path)
+		if err != nil {
+			return err
+		}
+		if d.Name() == ".git" {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			dirs = append(dirs, path)
+			return nil
+		}
+		if _, err := os.Lstat(filepath.Join(sourceDir, relative)); err == nil {
+			return nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if ignore.protects(path, false) {
+			return nil
+		}
+		slog.Info(fmt.Sprintf("Pruning %s", path))
+		pruned++
+		return os.Remove(path)
+	})
+	if err != nil {
+		return pruned, err
+	}
+	// Directories are removed deepest first, so that directories containing only
+	// empty directories are also removed; destDir itself is kept. Removing a non-empty
+	// directory fails, and is ignored.
+	for i := len(dirs) - 1; i > 0; i-- {
+		os.Remove(dirs[i])
+	}
+	return pruned, nil
+}
+
+// Copies all files from sourceDir into destDir, creating directories as required.
+// Line endings in text files are normalized as specified by lineEndings (see -line-endings).
+// If overwrite is false, an error is returned for any file which already exists in destDir.
+func copyDir(destDir, sourceDir string, overwrite bool, lineEndings string) error {
+	return copyDirIgnoring(destDir, sourceDir, overwrite, lineEndings, nil)
+}
+
+// As copyDir, but skipping any files (or directories) which are protected in destDir.
+func copyDirIgnoring(destDir, sourceDir string, overwrite bool, lineEndings string, ignore *librarianIgnore) error {
+	return copyDirFiltered(destDir, sourceDir, overwrite, lineEndings, ignore, nil)
+}
+
+// As copyDirIgnoring, but only copying files within the given source paths (relative to
+// sourceDir, with forward slashes), if any are specified.
+func copyDirFiltered(destDir, sourceDir string, overwrite bool, lineEndings string, ignore *librarianIgnore, sourcePaths []string) error {
+	return filepath.WalkDir(sourceDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return err
+		}
+		// Never modify the destination's Git metadata.
+		if relative == ".git" {
+			return filepath.SkipDir
+		}
+		if !withinSourcePaths(filepath.ToSlash(relative), d.IsDir(), sourcePaths) {
+			slog.Info(fmt.Sprintf("Not copying %s: outside the library's source paths", relative))
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if relative != "." && ignore.protects(relative, d.IsDir()) {
+			slog.Info(fmt.Sprintf("Not copying %s: protected by %s", relative, librarianIgnoreFile))
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		destPath := filepath.Join(destDir, relative)
+		switch d.Type() {
+		case fs.ModeDir:
+			return os.MkdirAll(destPath, 0777)
+		case fs.ModeSymlink:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			if overwrite {
+				if err := os.RemoveAll(destPath); err != nil {
+					return err
+				}
+			}
+			return os.Symlink(target, destPath)
+		case 0:
+			if err := copyGeneratedFile(destPath, path, overwrite, lineEndings); err != nil {
+				return fmt.Errorf("failed to copy %s: %w", relative, err)
+			}
+			return nil
+		default:
+			return &fs.PathError{Op: "copy", Path: path, Err: fs.ErrInvalid}
+		}
+	})
+}
+
+// Copies a single file, retaining its permissions and normalizing its line
+// endings if it's a text file. The file is streamed to a temporary file in the
+// destination directory which is then renamed, so an interrupted copy never leaves
+// a partially-written file in place.
+func copyGeneratedFile(destPath, sourcePath string, overwrite bool, lineEndings string) error {
+	if !overwrite {
+		if _, err := os.Lstat(destPath); err == nil {
+			return &fs.PathError{Op: "copy", Path: destPath, Err: fs.ErrExist}
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	source, err := os.Open(sourcePath)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	info, err := source.Stat()
+	if err != nil {
+		return err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(destPath), "."+filepath.Base(destPath)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	renamed := false
+	defer func() {
+		if !renamed {
+			temp.Close()
+			os.Remove(temp.Name())
+		}
+	}()
+
+	var w io.Writer = temp
+	if info.Size() >= largeFileThreshold {
+		slog.Info(fmt.Sprintf("Copying large file %s (%d MiB)", sourcePath, info.Size()/mebibyte))
+		w = &progressWriter{w: temp, name: sourcePath, total: info.Size()}
+	}
+	buffered := bufio.NewWriterSize(w, copyBufferSize)
+	if err := copyNormalizingLineEndings(buffered, bufio.NewReaderSize(source, copyBufferSize), lineEndings); err != nil {
+		return err
+	}
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+	if err := temp.Chmod(info.Mode().Perm()); err != nil {
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(temp.Name(), destPath); err != nil {
+		return err
+	}
+	renamed = true
+	return nil
+}
+
+// Copies from r to w, normalizing line endings (as described by lineEndings) unless
+// the content appears to be binary, in which case it is copied unchanged.
+func copyNormalizingLineEndings(w io.Writer, r *bufio.Reader, lineEndings string) error {
+	if lineEndings == "" || lineEndings == lineEndingsPreserve {
+		_, err := io.Copy(w, r)
+		return err
+	}
+	// Peek returns an error if fewer bytes are available, but still returns what there is.
+	start, err := r.Peek(binaryDetectionLength)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return err
+	}
+	if isBinary(start) {
+		_, err := io.Copy(w, r)
+		return err
+	}
+	ending := []byte("\n")
+	if lineEndings == lineEndingsCRLF {
+		ending = []byte("\r\n")
+	}
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			if bytes.HasSuffix(line, []byte("\n")) {
+				line = bytes.TrimSuffix(bytes.TrimSuffix(line, []byte("\n")), []byte("\r"))
+				line = append(line, ending...)
+			}
+			if _, err := w.Write(line); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func isBinary(data []byte) bool {
+	return bytes.IndexByte(data[:min(len(data), binaryDetectionLength)], 0) >= 0
+}
+
+// A progressWriter logs progress while copying a large file.
+type progressWriter struct {
+	w       io.Writer
+	name    string
+	total   int64
+	written int64
+	// The value of written when progress was last logged.
+	reported int64
+}
+
+func (p *progressWriter) Write(data []byte) (int, error) {
+	n, err := p.w.Write(data)
+	p.written += int64(n)
+	if p.written-p.reported >= progressInterval {
+		p.reported = p.written
+		slog.Info(fmt.Sprintf("Copied %d of %d MiB of %s", p.written/mebibyte, p.total/mebibyte, p.name))
+	}
+	return n, err
+}
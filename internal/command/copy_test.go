@@ -0,0 +1,178 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/googleapis/librarian/internal/statepb"
+)
+
+func TestCopyGeneratedCodeOnlyCopiesSourcePaths(t *testing.T) {
+	outputDir := t.TempDir()
+	repoDir := t.TempDir()
+	files := []string{
+		"packages/foo/src/foo.go",
+		"packages/foo/README.md",
+		"packages/bar/src/bar.go",
+		"README.md",
+		"generator-metadata.json",
+	}
+	for _, file := range files {
+		path := filepath.Join(outputDir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	library := &statepb.LibraryState{
+		Id:          "foo",
+		SourcePaths: []string{"packages/foo/"},
+	}
+
+	if err := copyGeneratedCode(repoDir, outputDir, library, lineEndingsPreserve); err != nil {
+		t.Fatalf("copyGeneratedCode() returned error %v", err)
+	}
+
+	for _, file := range []string{"packages/foo/src/foo.go", "packages/foo/README.md"} {
+		data, err := os.ReadFile(filepath.Join(repoDir, file))
+		if err != nil {
+			t.Errorf("%s wasn't copied: %v", file, err)
+			continue
+		}
+		if string(data) != file {
+			t.Errorf("%s has content %q, want %q", file, data, file)
+		}
+	}
+	for _, file := range []string{"packages/bar", "README.md", "generator-metadata.json"} {
+		if _, err := os.Lstat(filepath.Join(repoDir, file)); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%s was copied, but is outside the library's source paths", file)
+		}
+	}
+}
+
+func TestCopyGeneratedCodeWithoutSourcePathsCopiesEverything(t *testing.T) {
+	outputDir := t.TempDir()
+	repoDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outputDir, "README.md"), []byte("readme"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := copyGeneratedCode(repoDir, outputDir, &statepb.LibraryState{Id: "foo"}, lineEndingsPreserve); err != nil {
+		t.Fatalf("copyGeneratedCode() returned error %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(repoDir, "README.md")); err != nil {
+		t.Errorf("README.md wasn't copied: %v", err)
+	}
+}
+
+// Creates each of the files (relative to dir), with the file's path as its content.
+func writeTestFiles(t *testing.T, dir string, files []string) {
+	t.Helper()
+	for _, file := range files {
+		path := filepath.Join(dir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPruneDir(t *testing.T) {
+	tests := []struct {
+		name   string
+		dest   []string
+		source []string
+		want   []string // The files remaining in the destination.
+	}{
+		{
+			name:   "stale file",
+			dest:   []string{"a.go", "b.go"},
+			source: []string{"a.go"},
+			want:   []string{"a.go"},
+		},
+		{
+			name:   "stale directory",
+			dest:   []string{"a.go", "old/b.go", "old/nested/c.go"},
+			source: []string{"a.go"},
+			want:   []string{"a.go"},
+		},
+		{
+			name:   "nothing stale",
+			dest:   []string{"a.go", "sub/b.go"},
+			source: []string{"a.go", "sub/b.go"},
+			want:   []string{"a.go", "sub/b.go"},
+		},
+		{
+			name:   "git metadata",
+			dest:   []string{".git/HEAD", ".git/objects/ab/cdef", "sub/.git", "a.go"},
+			source: []string{"a.go"},
+			want:   []string{".git/HEAD", ".git/objects/ab/cdef", "a.go", "sub/.git"},
+		},
+	}
+	for _, test := range tests {
+		destDir := t.TempDir()
+		sourceDir := t.TempDir()
+		writeTestFiles(t, destDir, test.dest)
+		writeTestFiles(t, sourceDir, test.source)
+
+		if _, err := pruneDir(destDir, sourceDir, nil); err != nil {
+			t.Errorf("%s: pruneDir() returned error %v", test.name, err)
+			continue
+		}
+
+		got := []string{}
+		err := filepath.WalkDir(destDir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			relative, err := filepath.Rel(destDir, path)
+			got = append(got, filepath.ToSlash(relative))
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("%s: pruneDir() left %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestPruneGeneratedCodeRejectsRepoRoot(t *testing.T) {
+	for _, sourcePath := range []string{".", "", "./", "..", "packages/.."} {
+		repoDir := t.TempDir()
+		outputDir := t.TempDir()
+		writeTestFiles(t, repoDir, []string{".git/HEAD", "stale.go"})
+		library := &statepb.LibraryState{Id: "foo", SourcePaths: []string{sourcePath}}
+
+		if err := pruneGeneratedCode(repoDir, outputDir, library); err == nil {
+			t.Errorf("pruneGeneratedCode() with source path %q; error expected", sourcePath)
+		}
+		if _, err := os.Stat(filepath.Join(repoDir, ".git/HEAD")); err != nil {
+			t.Errorf("pruneGeneratedCode() with source path %q removed git metadata", sourcePath)
+		}
+	}
+}
@@ -41,17 +41,29 @@ var CmdCreateReleaseArtifacts = &Command{
 	Short: "Create release artifacts from a merged release PR.",
 	flagFunctions: []func(fs *flag.FlagSet){
 		addFlagImage,
+		addFlagImages,
 		addFlagWorkRoot,
 		addFlagLanguage,
 		addFlagRepoRoot,
+		addFlagResetRepo,
 		addFlagRepoUrl,
+		addFlagSSHKey,
 		addFlagReleaseID,
+		addFlagSecretProvider,
+		addFlagSecretsDir,
 		addFlagSecretsProject,
+		addFlagVaultMount,
 		addFlagSkipIntegrationTests,
+		addFlagContainerRetries,
+		addFlagContainerRuntime,
+		addFlagRegistryConfig,
+		addFlagContainerLogs,
+		addFlagContainerEnv,
 	},
 	maybeGetLanguageRepo:    cloneOrOpenLanguageRepo,
 	maybeLoadStateAndConfig: loadRepoStateAndConfig,
 	execute:                 createReleaseArtifactsImpl,
+	runsContainers:          true,
 }
 
 func createReleaseArtifactsImpl(state *commandState) error {
@@ -67,6 +79,7 @@ func createReleaseArtifactsImpl(state *commandState) error {
 		return err
 	}
 	slog.Info(fmt.Sprintf("Packages will be created in %s", outputRoot))
+	retainWorkRoot(state, "release artifacts")
 
 	releases, err := parseCommitsForReleases(state.languageRepo, flagReleaseID)
 	if err != nil {
@@ -107,13 +120,14 @@ func copyMetadataFiles(state *commandState, outputRoot string, releases []Librar
 	if err := gitrepo.Checkout(languageRepo, finalRelease.CommitHash); err != nil {
 		return err
 	}
-	sourceStateFile := filepath.Join(languageRepo.Dir, "generator-input", pipelineStateFile)
-	destStateFile := filepath.Join(outputRoot, pipelineStateFile)
+	// The state file keeps its name, so that its format is still known.
+	sourceStateFile := findPipelineStateFile(generatorInputDir(languageRepo.Dir))
+	destStateFile := filepath.Join(outputRoot, filepath.Base(sourceStateFile))
 	if err := utils.CopyFile(sourceStateFile, destStateFile); err != nil {
 		return err
 	}
 
-	sourceConfigFile := filepath.Join(languageRepo.Dir, "generator-input", pipelineConfigFile)
+	sourceConfigFile := filepath.Join(generatorInputDir(languageRepo.Dir), pipelineConfigFile)
 	destConfigFile := filepath.Join(outputRoot, pipelineConfigFile)
 	if err := utils.CopyFile(sourceConfigFile, destConfigFile); err != nil {
 		return err
@@ -128,7 +142,7 @@ func buildTestPackageRelease(state *commandState, outputRoot string, release Lib
 	if err := gitrepo.Checkout(languageRepo, release.CommitHash); err != nil {
 		return err
 	}
-	if err := container.BuildLibrary(containerConfig, languageRepo.Dir, release.LibraryID); err != nil {
+	if err := container.BuildLibrary(state.ctx, containerConfig, languageRepo.Dir, release.LibraryID); err != nil {
 		return err
 	}
 	if flagSkipIntegrationTests != "" {
@@ -153,15 +167,51 @@ func parseCommitsForReleases(repo *gitrepo.Repo, releaseID string) ([]LibraryRel
 	}
 	releases := []LibraryRelease{}
 	for _, commit := range commits {
-		release, err := parseCommitMessageForRelease(commit.Message, commit.Hash.String())
-		if err != nil {
-			return nil, err
+		for _, message := range splitSquashedReleaseMessage(commit.Message) {
+			release, err := parseCommitMessageForRelease(message, commit.Hash.String())
+			if err != nil {
+				return nil, err
+			}
+			releases = append(releases, *release)
 		}
-		releases = append(releases, *release)
 	}
 	return releases, nil
 }
 
+// Splits the message of a squashed release commit (as created by merge-release-pr -squash)
+// into the original messages of the per-library release commits, each of which starts with
+// a "chore: Release library" line. Any summary preceding the first such line is discarded.
+// A message for a single library release is returned as-is.
+func splitSquashedReleaseMessage(message string) []string {
+	lines := strings.Split(message, "\n")
+	libraryCount := 0
+	for _, line := range lines {
+		if strings.HasPrefix(line, "Librarian-Release-Library: ") {
+			libraryCount++
+		}
+	}
+	if libraryCount <= 1 {
+		return []string{message}
+	}
+	messages := []string{}
+	var current []string
+	for _, line := range lines {
+		if strings.HasPrefix(line, "chore: Release library ") {
+			if current != nil {
+				messages = append(messages, strings.Join(current, "\n"))
+			}
+			current = []string{}
+		}
+		if current != nil {
+			current = append(current, line)
+		}
+	}
+	if current != nil {
+		messages = append(messages, strings.Join(current, "\n"))
+	}
+	return messages
+}
+
 func parseCommitMessageForRelease(message, hash string) (*LibraryRelease, error) {
 	messageLines := strings.Split(message, "\n")
 
@@ -34,36 +34,74 @@ import (
 )
 
 const prNumberEnvVarName = "_PR_NUMBER"
+const prUrlEnvVarName = "_PR_URL"
 const baselineCommitEnvVarName = "_BASELINE_COMMIT"
 
+// The label applied to release PRs which include libraries with breaking changes
+// (when those releases are allowed via -allow-breaking).
+const BreakingChangeLabel = "breaking"
+
 var CmdCreateReleasePR = &Command{
 	Name:  "create-release-pr",
 	Short: "Generate a release PR.",
 	flagFunctions: []func(fs *flag.FlagSet){
 		addFlagImage,
+		addFlagImages,
+		addFlagSecretProvider,
+		addFlagSecretsDir,
 		addFlagSecretsProject,
+		addFlagVaultMount,
 		addFlagWorkRoot,
 		addFlagLanguage,
 		addFlagLibraryID,
 		addFlagLibraryVersion,
 		addFlagPush,
+		addFlagForkRepo,
 		addFlagGitUserEmail,
 		addFlagGitUserName,
+		addFlagSignCommits,
+		addFlagSigningKey,
 		addFlagRepoRoot,
+		addFlagResetRepo,
 		addFlagSkipIntegrationTests,
 		addFlagEnvFile,
 		addFlagRepoUrl,
+		addFlagSSHKey,
+		addFlagDetectBreaking,
+		addFlagAllowBreaking,
+		addFlagPRLabel,
+		addFlagPRReviewer,
+		addFlagPRAssignee,
+		addFlagBranchPrefix,
+		addFlagBaseBranch,
+		addFlagHistoryFile,
+		addFlagBranchTemplate,
+		addFlagTitleTimezone,
+		addFlagKeepBranchOnFailure,
+		addFlagWaitForChecks,
+		addFlagChecksTimeout,
+		addFlagVerbosePRErrors,
+		addFlagFailFast,
+		addFlagContainerRetries,
+		addFlagContainerRuntime,
+		addFlagRegistryConfig,
+		addFlagContainerLogs,
+		addFlagContainerEnv,
 	},
 	maybeGetLanguageRepo:    cloneOrOpenLanguageRepo,
 	maybeLoadStateAndConfig: loadRepoStateAndConfig,
 	execute:                 createReleasePR,
+	runsContainers:          true,
 }
 
 func createReleasePR(state *commandState) error {
 	if err := validateSkipIntegrationTests(); err != nil {
 		return err
 	}
-	if err := validatePush(); err != nil {
+	if err := validateTitleTimezone(); err != nil {
+		return err
+	}
+	if err := validatePush(state); err != nil {
 		return err
 	}
 
@@ -96,35 +134,50 @@ func createReleasePR(state *commandState) error {
 		return err
 	}
 
-	prContent, err := generateReleaseCommitForEachLibrary(state, inputDirectory, releaseID)
+	prOpts := pullRequestOptionsFromFlags()
+	prContent, breakingLibraries, err := generateReleaseCommitForEachLibrary(state, prOpts, inputDirectory, releaseID)
 	if err != nil {
 		return err
 	}
 
-	prMetadata, err := createPullRequest(state, prContent, "chore: Library release", fmt.Sprintf("Librarian-Release-ID: %s", releaseID), "release")
+	descriptionSuffix := fmt.Sprintf("Librarian-Release-ID: %s", releaseID)
+	if len(breakingLibraries) > 0 {
+		descriptionSuffix = formatListAsMarkdown("Libraries with breaking changes", breakingLibraries) + descriptionSuffix
+	}
+	prResult, err := createPullRequest(state, prOpts, prContent, "chore: Library release", descriptionSuffix, "release")
 	if err != nil {
 		return err
 	}
 
-	if prMetadata == nil {
+	if prResult.Outcome != PullRequestCreated {
 		// We haven't created a release PR, and there are no errors. This could be because:
 		// - There are no changes to release
 		// - The -push flag wasn't specified.
 		// Either way, complete successfully at this point.
 		return nil
 	}
+	prMetadata := prResult.Metadata
 
 	// Final steps if we've actually created a release PR.
 	// - We always add the do-not-merge label so that Librarian can merge later.
-	// - Add a result environment variable with the PR number, for the next stage of the process.
+	// - Add result environment variables with the PR number and URL, for the next stage of the process.
 	err = githubrepo.AddLabelToPullRequest(state.ctx, *prMetadata, DoNotMergeLabel)
 	if err != nil {
 		slog.Warn(fmt.Sprintf("Received error trying to add label to PR: '%s'", err))
 		return err
 	}
+	if len(breakingLibraries) > 0 {
+		if err := githubrepo.AddLabelToPullRequest(state.ctx, *prMetadata, BreakingChangeLabel); err != nil {
+			slog.Warn(fmt.Sprintf("Received error trying to add label to PR: '%s'", err))
+			return err
+		}
+	}
 	if err := appendResultEnvironmentVariable(state, prNumberEnvVarName, strconv.Itoa(prMetadata.Number)); err != nil {
 		return err
 	}
+	if err := appendResultEnvironmentVariable(state, prUrlEnvVarName, prMetadata.URL); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -133,14 +186,24 @@ func createReleasePR(state *commandState) error {
 //   - Library-level errors do not halt the process, but are reported in the resulting PR (if any).
 //     This can include tags being missing, release preparation failing, or the build failing.
 //   - More fundamental errors (e.g. a failure to commit, or to save pipeline state) abort the whole process immediately.
-func generateReleaseCommitForEachLibrary(state *commandState, inputDirectory string, releaseID string) (*PullRequestContent, error) {
+//
+// As well as the PR content, the IDs of libraries being released with breaking changes are returned.
+func generateReleaseCommitForEachLibrary(state *commandState, prOpts pullRequestOptions, inputDirectory string, releaseID string) (*PullRequestContent, []string, error) {
 	containerConfig := state.containerConfig
 	libraries := state.pipelineState.Libraries
 	languageRepo := state.languageRepo
 
 	pr := new(PullRequestContent)
+	breakingLibraries := []string{}
 
 	for _, library := range libraries {
+		// Failures for the previous library are only checked here, as each is followed by "continue".
+		if err := failFastError(pr, prOpts.failFast); err != nil {
+			return nil, nil, err
+		}
+		if err := cancelledError(state.ctx); err != nil {
+			return nil, nil, err
+		}
 		// If we've specified a single library to release, skip all the others.
 		if flagLibraryID != "" && library.Id != flagLibraryID {
 			continue
@@ -173,14 +236,33 @@ func generateReleaseCommitForEachLibrary(state *commandState, inputDirectory str
 			continue
 		}
 
+		if flagDetectBreaking && previousReleaseTag != "" {
+			breakingChanges, err := detectBreakingChanges(state, library.Id, previousReleaseTag)
+			if err != nil {
+				addErrorToPullRequest(pr, library.Id, err, "detecting breaking changes in")
+				if err := gitrepo.CleanWorkingTree(languageRepo); err != nil {
+					return nil, nil, err
+				}
+				continue
+			}
+			if breakingChanges != "" {
+				if !flagAllowBreaking {
+					addErrorToPullRequest(pr, library.Id, fmt.Errorf("breaking changes detected: %s", breakingChanges), "releasing (use -allow-breaking to override)")
+					continue
+				}
+				slog.Warn(fmt.Sprintf("Releasing %s despite breaking changes: %s", library.Id, breakingChanges))
+				breakingLibraries = append(breakingLibraries, library.Id)
+			}
+		}
+
 		releaseVersion, err := calculateNextVersion(library)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		releaseNotes := formatReleaseNotes(commitMessages)
 		if err = createReleaseNotesFile(inputDirectory, library.Id, releaseVersion, releaseNotes); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		// Update the pipeline state to record what we're releasing and when, and to clear the next version field.
@@ -191,22 +273,22 @@ func generateReleaseCommitForEachLibrary(state *commandState, inputDirectory str
 		library.LastReleasedCommit = library.LastGeneratedCommit
 		library.ReleaseTimestamp = timestamppb.Now()
 		if err = savePipelineState(state); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		if err := container.PrepareLibraryRelease(containerConfig, languageRepo.Dir, inputDirectory, library.Id, releaseVersion); err != nil {
 			addErrorToPullRequest(pr, library.Id, err, "preparing library release")
 			// Clean up any changes before starting the next iteration.
 			if err := gitrepo.CleanWorkingTree(languageRepo); err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			continue
 		}
-		if err := container.BuildLibrary(containerConfig, languageRepo.Dir, library.Id); err != nil {
+		if err := container.BuildLibrary(state.ctx, containerConfig, languageRepo.Dir, library.Id); err != nil {
 			addErrorToPullRequest(pr, library.Id, err, "building/testing library")
 			// Clean up any changes before starting the next iteration.
 			if err := gitrepo.CleanWorkingTree(languageRepo); err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			continue
 		}
@@ -215,22 +297,36 @@ func generateReleaseCommitForEachLibrary(state *commandState, inputDirectory str
 		} else if err := container.IntegrationTestLibrary(containerConfig, languageRepo.Dir, library.Id); err != nil {
 			addErrorToPullRequest(pr, library.Id, err, "integration testing library")
 			if err := gitrepo.CleanWorkingTree(languageRepo); err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			continue
 		}
 
 		releaseDescription := fmt.Sprintf("chore: Release library %s version %s", library.Id, releaseVersion)
-		addSuccessToPullRequest(pr, releaseDescription)
+		addSuccessToPullRequest(pr, library.Id, fmt.Sprintf("releasing version %s", releaseVersion), releaseDescription)
 		// Metadata for easy extraction later.
 		metadata := fmt.Sprintf("Librarian-Release-Library: %s\nLibrarian-Release-Version: %s\nLibrarian-Release-ID: %s", library.Id, releaseVersion, releaseID)
 		// Note that releaseDescription will already end with two line breaks, so we don't need any more before the metadata.
-		err = commitAll(languageRepo, fmt.Sprintf("%s\n\n%s%s", releaseDescription, releaseNotes, metadata))
+		err = commitAll(state, languageRepo, fmt.Sprintf("%s\n\n%s%s", releaseDescription, releaseNotes, metadata))
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
-	return pr, nil
+	if err := failFastError(pr, prOpts.failFast); err != nil {
+		return nil, nil, err
+	}
+	return pr, breakingLibraries, nil
+}
+
+// Runs the language container's breaking change detection for a library, against the
+// given previous release tag. Returns a description of the breaking changes, or an empty
+// string if there are none.
+func detectBreakingChanges(state *commandState, libraryID, previousReleaseTag string) (string, error) {
+	outputDir := filepath.Join(state.workRoot, "breaking-changes", libraryID)
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return "", err
+	}
+	return container.DetectBreakingChanges(state.containerConfig, state.languageRepo.Dir, outputDir, libraryID, previousReleaseTag)
 }
 
 func formatReleaseNotes(commitMessages []*CommitMessage) string {
@@ -0,0 +1,310 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	gitdiff "github.com/go-git/go-git/v5/plumbing/format/diff"
+	linediff "github.com/go-git/go-git/v5/utils/diff"
+	"github.com/googleapis/librarian/internal/statepb"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// The number of unchanged lines shown around each change, as with "git diff".
+const diffContextLines = 3
+
+// Computes the changes which copying the generated code in outputDir into the language
+// repo would make, without modifying the repo. Every generated file is compared with the
+// corresponding file in the repo (after normalizing line endings, as copying would).
+// Files within the library's generated tree (as for -prune) which the generator didn't
+// emit are reported as deleted. Files protected by .librarianignore are never changed, so
+// they're omitted.
+func diffGeneratedCode(repoDir, outputDir string, library *statepb.LibraryState, lineEndings string) ([]*fileDiff, error) {
+	ignore, err := loadLibrarianIgnore(repoDir)
+	if err != nil {
+		return nil, err
+	}
+	diffs := []*fileDiff{}
+	generated := map[string]bool{}
+	err = filepath.WalkDir(outputDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		relative, err := filepath.Rel(outputDir, path)
+		if err != nil || ignore.protects(relative, false) {
+			return err
+		}
+		generated[relative] = true
+		from, err := readDiffFile(repoDir, relative, "")
+		if err != nil {
+			return err
+		}
+		to, err := readDiffFile(outputDir, relative, lineEndings)
+		if err != nil {
+			return err
+		}
+		if diff := newFileDiff(from, to); diff != nil {
+			diffs = append(diffs, diff)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sourcePath := range library.SourcePaths {
+		if info, err := os.Stat(filepath.Join(outputDir, sourcePath)); err != nil || !info.IsDir() {
+			continue
+		}
+		err := filepath.WalkDir(filepath.Join(repoDir, sourcePath), func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			relative, err := filepath.Rel(repoDir, path)
+			if err != nil || generated[relative] || ignore.protects(relative, false) {
+				return err
+			}
+			from, err := readDiffFile(repoDir, relative, "")
+			if err != nil {
+				return err
+			}
+			diffs = append(diffs, newFileDiff(from, nil))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].path() < diffs[j].path()
+	})
+	return diffs, nil
+}
+
+// Returns the diff (or if stat is true, the summary) of the changes which copying the
+// generated code in outputDir into the language repo would make, with the given line endings.
+func formatGenerationDiff(repoDir, outputDir string, library *statepb.LibraryState, lineEndings string, stat bool) (string, error) {
+	diffs, err := diffGeneratedCode(repoDir, outputDir, library, lineEndings)
+	if err != nil {
+		return "", err
+	}
+	var buffer strings.Builder
+	if stat {
+		err = writeDiffStat(&buffer, diffs)
+	} else {
+		err = writeUnifiedDiff(&buffer, diffs)
+	}
+	return buffer.String(), err
+}
+
+// Writes the changes as a unified diff, in the same format as "git diff".
+func writeUnifiedDiff(w io.Writer, diffs []*fileDiff) error {
+	return gitdiff.NewUnifiedEncoder(w, diffContextLines).Encode(diffPatch(diffs))
+}
+
+// Writes a summary of the changes, with the number of lines added and removed in each file.
+func writeDiffStat(w io.Writer, diffs []*fileDiff) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	totalAdded, totalDeleted := 0, 0
+	for _, diff := range diffs {
+		if diff.binary {
+			fmt.Fprintf(tw, " %s\t| binary\n", diff.path())
+			continue
+		}
+		added, deleted := diff.lineCounts()
+		totalAdded += added
+		totalDeleted += deleted
+		fmt.Fprintf(tw, " %s\t| +%d -%d\n", diff.path(), added, deleted)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, " %d files changed, %d insertions(+), %d deletions(-)\n", len(diffs), totalAdded, totalDeleted)
+	return err
+}
+
+// A file on one side of a diff.
+type diffFile struct {
+	relativePath string
+	content      []byte
+	mode         filemode.FileMode
+}
+
+func (f *diffFile) Hash() plumbing.Hash {
+	return plumbing.ComputeHash(plumbing.BlobObject, f.content)
+}
+
+func (f *diffFile) Mode() filemode.FileMode {
+	return f.mode
+}
+
+func (f *diffFile) Path() string {
+	return filepath.ToSlash(f.relativePath)
+}
+
+// Reads a file for diffing, returning nil if it doesn't exist. Line endings are normalized
+// as specified by lineEndings (see -line-endings), unless it's empty.
+func readDiffFile(dir, relative, lineEndings string) (*diffFile, error) {
+	path := filepath.Join(dir, relative)
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if lineEndings != "" {
+		var normalized bytes.Buffer
+		if err := copyNormalizingLineEndings(&normalized, bufio.NewReader(bytes.NewReader(content)), lineEndings); err != nil {
+			return nil, err
+		}
+		content = normalized.Bytes()
+	}
+	mode, err := filemode.NewFromOSFileMode(info.Mode())
+	if err != nil {
+		return nil, err
+	}
+	return &diffFile{relativePath: relative, content: content, mode: mode}, nil
+}
+
+// The changes to a single file. Either from or to (but not both) may be nil, for added
+// and deleted files respectively.
+type fileDiff struct {
+	from, to *diffFile
+	binary   bool
+	chunks   []gitdiff.Chunk
+}
+
+// Returns the changes between two versions of a file, or nil if they're identical.
+func newFileDiff(from, to *diffFile) *fileDiff {
+	if from != nil && to != nil && bytes.Equal(from.content, to.content) && from.mode == to.mode {
+		return nil
+	}
+	diff := &fileDiff{from: from, to: to}
+	fromContent, toContent := "", ""
+	if from != nil {
+		fromContent = string(from.content)
+	}
+	if to != nil {
+		toContent = string(to.content)
+	}
+	if isBinary([]byte(fromContent)) || isBinary([]byte(toContent)) {
+		diff.binary = true
+		return diff
+	}
+	for _, d := range linediff.Do(fromContent, toContent) {
+		chunk := diffChunk{content: d.Text}
+		switch d.Type {
+		case diffmatchpatch.DiffEqual:
+			chunk.operation = gitdiff.Equal
+		case diffmatchpatch.DiffInsert:
+			chunk.operation = gitdiff.Add
+		case diffmatchpatch.DiffDelete:
+			chunk.operation = gitdiff.Delete
+		}
+		diff.chunks = append(diff.chunks, chunk)
+	}
+	return diff
+}
+
+func (d *fileDiff) path() string {
+	if d.to != nil {
+		return d.to.Path()
+	}
+	return d.from.Path()
+}
+
+// Returns the number of lines added and deleted.
+func (d *fileDiff) lineCounts() (int, int) {
+	added, deleted := 0, 0
+	for _, chunk := range d.chunks {
+		lines := strings.Count(chunk.Content(), "\n")
+		if !strings.HasSuffix(chunk.Content(), "\n") {
+			lines++
+		}
+		switch chunk.Type() {
+		case gitdiff.Add:
+			added += lines
+		case gitdiff.Delete:
+			deleted += lines
+		}
+	}
+	return added, deleted
+}
+
+func (d *fileDiff) IsBinary() bool {
+	return d.binary
+}
+
+func (d *fileDiff) Files() (gitdiff.File, gitdiff.File) {
+	// The interface values must be nil (rather than typed nil pointers) for
+	// added and deleted files.
+	var from, to gitdiff.File
+	if d.from != nil {
+		from = d.from
+	}
+	if d.to != nil {
+		to = d.to
+	}
+	return from, to
+}
+
+func (d *fileDiff) Chunks() []gitdiff.Chunk {
+	return d.chunks
+}
+
+type diffChunk struct {
+	content   string
+	operation gitdiff.Operation
+}
+
+func (c diffChunk) Content() string {
+	return c.content
+}
+
+func (c diffChunk) Type() gitdiff.Operation {
+	return c.operation
+}
+
+type diffPatch []*fileDiff
+
+func (p diffPatch) FilePatches() []gitdiff.FilePatch {
+	patches := make([]gitdiff.FilePatch, len(p))
+	for i, diff := range p {
+		patches[i] = diff
+	}
+	return patches
+}
+
+func (p diffPatch) Message() string {
+	return ""
+}
@@ -0,0 +1,164 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/googleapis/librarian/internal/container"
+	"github.com/googleapis/librarian/internal/githubrepo"
+	"github.com/googleapis/librarian/internal/gitrepo"
+	"github.com/googleapis/librarian/internal/offline"
+	"github.com/googleapis/librarian/internal/statepb"
+)
+
+var CmdDoctor = &Command{
+	Name:  "doctor",
+	Short: "Check that the environment is set up correctly for Librarian.",
+	flagFunctions: []func(fs *flag.FlagSet){
+		addFlagLanguage,
+		addFlagImage,
+		addFlagImages,
+		addFlagContainerRuntime,
+		addFlagRegistryConfig,
+		addFlagAPIRoot,
+		addFlagRepoRoot,
+	},
+	maybeGetLanguageRepo: func(ctx context.Context, workRoot string) (*gitrepo.Repo, error) {
+		return nil, nil
+	},
+	maybeLoadStateAndConfig: func(languageRepo *gitrepo.Repo) (*statepb.PipelineState, *statepb.PipelineConfig, error) {
+		// The state is loaded (if possible) as one of the checks, so that a problem
+		// with it is reported alongside everything else.
+		return nil, nil, nil
+	},
+	execute: runDoctor,
+}
+
+// The outcome of a single doctor check. Only failures cause the command to fail;
+// warnings indicate problems which only affect some commands.
+type doctorStatus string
+
+const (
+	doctorPass doctorStatus = "PASS"
+	doctorWarn doctorStatus = "WARN"
+	doctorFail doctorStatus = "FAIL"
+	doctorSkip doctorStatus = "SKIP"
+)
+
+type doctorCheck struct {
+	name   string
+	status doctorStatus
+	detail string
+}
+
+// Checks the prerequisites for running Librarian (the container runtime, the image,
+// the GitHub token and any specified directories), printing a checklist of the
+// results. An error is returned if any check fails.
+func runDoctor(state *commandState) error {
+	checks := []doctorCheck{}
+	add := func(name string, status doctorStatus, detail string) {
+		checks = append(checks, doctorCheck{name: name, status: status, detail: detail})
+	}
+
+	runtimeName := state.containerConfig.Runtime.Binary()
+	runtimeOK := false
+	if err := container.CheckRuntime(state.ctx, state.containerConfig); err != nil {
+		add("Container runtime", doctorFail, err.Error())
+	} else {
+		add("Container runtime", doctorPass, fmt.Sprintf("%s is available", runtimeName))
+		runtimeOK = true
+	}
+
+	var pipelineState *statepb.PipelineState
+	if flagRepoRoot == "" {
+		add("Language repo", doctorSkip, "-repo-root not specified")
+	} else if _, err := gitrepo.Open(flagRepoRoot); err != nil {
+		add("Language repo", doctorFail, fmt.Sprintf("%s is not a git repository: %s", flagRepoRoot, err))
+	} else if pipelineState, err = loadPipelineStateFile(findPipelineStateFile(generatorInputDir(flagRepoRoot))); err != nil {
+		add("Language repo", doctorFail, fmt.Sprintf("unable to load pipeline state: %s", err))
+	} else {
+		add("Language repo", doctorPass, fmt.Sprintf("%s has %d libraries configured", flagRepoRoot, len(pipelineState.Libraries)))
+	}
+
+	image, imageErr := deriveImage(pipelineState)
+	if flagImage == "" && flagLanguage == "" {
+		add("Image", doctorFail, "specify -language or -image to check the image")
+	} else if imageErr != nil {
+		add("Image", doctorFail, imageErr.Error())
+	} else if !runtimeOK {
+		add("Image", doctorSkip, "container runtime unavailable")
+	} else {
+		state.containerConfig.Image = image
+		if id, err := container.ImageID(state.ctx, state.containerConfig); err == nil {
+			add("Image", doctorPass, fmt.Sprintf("%s is present locally (%s)", image, id))
+		} else if offline.Enabled() {
+			add("Image", doctorFail, fmt.Sprintf("%s is not present locally, and can't be pulled offline", image))
+		} else if err := container.CheckImagePullable(state.ctx, state.containerConfig); err != nil {
+			add("Image", doctorFail, err.Error())
+		} else {
+			add("Image", doctorPass, fmt.Sprintf("%s can be pulled", image))
+		}
+	}
+
+	// A token is only required for commands which push or use the GitHub API.
+	if githubrepo.GetAccessToken() == "" {
+		add("GitHub token", doctorWarn, "no token configured; required for pushing and creating pull requests")
+	} else if offline.Enabled() {
+		add("GitHub token", doctorSkip, "can't verify the token offline")
+	} else if err := githubrepo.CheckAccessTokenScopes(state.ctx); err != nil {
+		add("GitHub token", doctorFail, err.Error())
+	} else {
+		add("GitHub token", doctorPass, "token is valid")
+	}
+
+	if flagAPIRoot == "" {
+		add("API root", doctorSkip, "-api-root not specified; googleapis will be cloned when required")
+	} else if info, err := os.Stat(flagAPIRoot); err != nil || !info.IsDir() {
+		add("API root", doctorFail, fmt.Sprintf("%s is not a directory", flagAPIRoot))
+	} else if _, err := os.Stat(filepath.Join(flagAPIRoot, "google")); err != nil {
+		add("API root", doctorWarn, fmt.Sprintf("%s has no google directory; is it a googleapis clone?", flagAPIRoot))
+	} else {
+		add("API root", doctorPass, fmt.Sprintf("%s exists", flagAPIRoot))
+	}
+
+	if err := writeDoctorChecks(os.Stdout, checks); err != nil {
+		return err
+	}
+	failures := 0
+	for _, check := range checks {
+		if check.status == doctorFail {
+			failures++
+		}
+	}
+	if failures > 0 {
+		return fmt.Errorf("%d of %d checks failed", failures, len(checks))
+	}
+	return nil
+}
+
+func writeDoctorChecks(w io.Writer, checks []doctorCheck) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, check := range checks {
+		fmt.Fprintf(tw, "[%s]\t%s\t%s\n", check.status, check.name, check.detail)
+	}
+	return tw.Flush()
+}
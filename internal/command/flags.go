@@ -15,56 +15,201 @@
 package command
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/googleapis/librarian/internal/container"
 	"github.com/googleapis/librarian/internal/githubrepo"
+	"github.com/googleapis/librarian/internal/gitrepo"
+	"github.com/googleapis/librarian/internal/secrets"
 )
 
 // Environment variables are specified here as they're used for the same sort of purpose as flags...
 // ... but see also githubrepo.go
 const defaultRepositoryEnvironmentVariable string = "LIBRARIAN_REPOSITORY"
 
+// Defaults for -git-user-name and -git-user-email.
+const gitUserNameEnvironmentVariable string = "LIBRARIAN_GIT_USER_NAME"
+const gitUserEmailEnvironmentVariable string = "LIBRARIAN_GIT_USER_EMAIL"
+
+const defaultBranchTemplate = "{prefix}-{type}-{timestamp}"
+
+const defaultGeneratorInputDir = "generator-input"
+
+const defaultCommitMessageTemplate = "regen: Regenerate {libraryId} at API commit {apiCommit}"
+
+// The default for -commit-message-template with generate -commit-per-api and regenerate-all.
+const defaultGenerateCommitMessageTemplate = "feat: Regenerate {libraryId}"
+
+// The languages which may be specified with -language: those with a google-cloud-{language}
+// repo and generator image, and (except for rust) settings in the API publishing config.
+var supportedLanguages = []string{"cpp", "dotnet", "go", "java", "node", "php", "python", "ruby", "rust"}
+
+var environmentVariableNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
 var (
-	flagAPIPath              string
-	flagAPIRoot              string
-	flagArtifactRoot         string
-	flagBaselineCommit       string
-	flagBranch               string
-	flagBuild                bool
-	flagEnvFile              string
-	flagGitUserEmail         string
-	flagGitUserName          string
-	flagImage                string
-	flagLanguage             string
-	flagLibraryID            string
-	flagLibraryVersion       string
-	flagPush                 bool
-	flagReleaseID            string
-	flagReleasePRUrl         string
-	flagRepoRoot             string
-	flagRepoUrl              string
-	flagSyncUrlPrefix        string
-	flagSecretsProject       string
-	flagSkipIntegrationTests string
-	flagTag                  string
-	flagTagRepoUrl           string
-	flagWorkRoot             string
+	flagAllContainers           bool
+	flagAllowBreaking           bool
+	flagAllowDirty              bool
+	flagAPIIncludeRoots         []string
+	flagAPIPath                 string
+	flagAPIRoot                 string
+	flagArtifactRoot            string
+	flagBaseBranch              string
+	flagBaselineCommit          string
+	flagBranch                  string
+	flagBranchPrefix            string
+	flagBranchTemplate          string
+	flagBuild                   bool
+	flagChecksTimeout           time.Duration
+	flagCloneDepth              int
+	flagCloneRetries            int
+	flagCommitMessageTemplate   string
+	flagCommitPerApi            bool
+	flagConfig                  string
+	flagConfigProfile           string
+	flagContainerEnv            []string
+	flagContainerLogs           bool
+	flagContainerRetries        int
+	flagContainerRuntime        string
+	flagDeleteBranches          bool
+	flagDetectBreaking          bool
+	flagDiff                    bool
+	flagDiffStat                bool
+	flagDraft                   bool
+	flagDryRun                  bool
+	flagEmitMetadata            bool
+	flagEnvFile                 string
+	flagExclude                 []string
+	flagExistingPRAuthor        string
+	flagExistingPRLabel         string
+	flagFailFast                bool
+	flagForce                   bool
+	flagForkRepo                string
+	flagFormat                  string
+	flagGenerateTimeout         time.Duration
+	flagGeneratorInputDir       string
+	flagGitHubAppID             int64
+	flagGitHubAppInstallationID int64
+	flagGitHubAppPrivateKey     string
+	flagGitHubRetries           int
+	flagHistoryFile             string
+	flagGitHubTokenFile         string
+	flagGitUserEmail            string
+	flagGitUserName             string
+	flagImage                   string
+	flagImageDigest             string
+	flagImages                  []string
+	flagInclude                 []string
+	flagKeepBranchOnFailure     bool
+	flagKeepWorkRoot            bool
+	flagLanguage                string
+	flagLibraryID               string
+	flagLibraryVersion          string
+	flagLineEndings             string
+	flagLocalGenerator          string
+	flagLogFormat               string
+	flagLogLevel                string
+	flagMaxConcurrency          int
+	flagMaxOutputSize           byteSize
+	flagMirrorRepoUrl           string
+	flagNoStateCache            bool
+	flagOffline                 bool
+	flagOlderThan               time.Duration
+	flagOutput                  string
+	flagPRAssignees             []string
+	flagPRAutoMerge             bool
+	flagPRAutoMergeMethod       string
+	flagPRLabels                []string
+	flagPRReviewers             []string
+	flagPostGenerateHook        string
+	flagPrune                   bool
+	flagPull                    bool
+	flagPush                    bool
+	flagRegistryConfig          string
+	flagReleaseID               string
+	flagReleasePRUrl            string
+	flagRepoRef                 string
+	flagRepoRoot                string
+	flagRepoUrl                 string
+	flagRequireRefined          bool
+	flagResetRepo               bool
+	flagStateCacheTTL           time.Duration
+	flagStreamOutput            bool
+	flagSummaryFile             string
+	flagSyncUrlPrefix           string
+	flagSecretProvider          string
+	flagSecretsDir              string
+	flagSecretsProject          string
+	flagSignCommits             bool
+	flagSigningKey              string
+	flagSince                   string
+	flagSkipIntegrationTests    string
+	flagSSHKey                  string
+	flagSquash                  bool
+	flagSquashTemplate          string
+	flagTag                     string
+	flagTagRepoUrl              string
+	flagTitleTimezone           string
+	flagUpdateExisting          bool
+	flagValidateImage           string
+	flagVaultMount              string
+	flagVerbosePRErrors         bool
+	flagWaitForChecks           bool
+	flagWorkRoot                string
 )
 
+func addFlagAllContainers(fs *flag.FlagSet) {
+	fs.BoolVar(&flagAllContainers, "all-containers", false, "whether to remove every container started by Librarian, including those whose processes may still be running")
+}
+
+func addFlagAllowBreaking(fs *flag.FlagSet) {
+	fs.BoolVar(&flagAllowBreaking, "allow-breaking", false, "whether to release libraries even when breaking changes are detected (with -detect-breaking)")
+}
+
+func addFlagAllowDirty(fs *flag.FlagSet) {
+	fs.BoolVar(&flagAllowDirty, "allow-dirty", false, "whether to use the language repo even if it has uncommitted changes, which may then be mixed with the generated code. "+
+		"By default such a repo is rejected")
+}
+
 func addFlagAPIPath(fs *flag.FlagSet) {
-	fs.StringVar(&flagAPIPath, "api-path", "", "(Required) path api-root to the API to be generated (e.g., google/cloud/functions/v2)")
+	fs.StringVar(&flagAPIPath, "api-path", "", "(Required) path api-root to the API to be generated (e.g., google/cloud/functions/v2). "+
+		"For generate, this may be a comma-separated list of API paths, each of which is generated into its own subdirectory of the output")
 }
 
 func addFlagAPIRoot(fs *flag.FlagSet) {
-	fs.StringVar(&flagAPIRoot, "api-root", "", "location of googleapis repository. If undefined, googleapis will be cloned to the work-root")
+	// The first -api-root is the primary root, containing the API paths; any others are
+	// include roots, which protos in the primary root may import from.
+	fs.Func("api-root", "location of googleapis repository. If undefined, googleapis will be cloned to the work-root. "+
+		"May be repeated for APIs which import protos from other repos: the first is the primary root containing the API paths, "+
+		"and the others are passed to generation as include roots", func(value string) error {
+		if flagAPIRoot == "" {
+			flagAPIRoot = value
+		} else {
+			flagAPIIncludeRoots = append(flagAPIIncludeRoots, value)
+		}
+		return nil
+	})
 }
 
 func addFlagArtifactRoot(fs *flag.FlagSet) {
 	fs.StringVar(&flagArtifactRoot, "artifact-root", "", "Path to root of release artifacts to publish (as created by create-release-artifacts)")
 }
+
+func addFlagBaseBranch(fs *flag.FlagSet) {
+	fs.StringVar(&flagBaseBranch, "base-branch", "", "the branch of the language repo to clone and create the PR against (default: the repo's default branch)")
+}
+
 func addFlagBaselineCommit(fs *flag.FlagSet) {
 	fs.StringVar(&flagBaselineCommit, "baseline-commit", "", "the commit hash that was at HEAD for the language repo when create-release-pr was run")
 }
@@ -73,28 +218,280 @@ func addFlagBranch(fs *flag.FlagSet) {
 	fs.StringVar(&flagBranch, "branch", "main", "repository branch")
 }
 
+func addFlagBranchPrefix(fs *flag.FlagSet) {
+	fs.StringVar(&flagBranchPrefix, "branch-prefix", "librarian", "prefix for the names of branches pushed for PRs, used as {prefix} in -branch-template")
+}
+
+func addFlagBranchTemplate(fs *flag.FlagSet) {
+	fs.StringVar(&flagBranchTemplate, "branch-template", defaultBranchTemplate, "template for the names of branches pushed for PRs. "+
+		"Placeholders: {prefix} (the -branch-prefix value), {type} (the kind of PR, e.g. regen or release), {timestamp} and {apiPath} (the -api-path value, if any). "+
+		"Characters which aren't valid in git branch names are replaced with -")
+}
+
 func addFlagBuild(fs *flag.FlagSet) {
 	fs.BoolVar(&flagBuild, "build", false, "whether to build the generated code")
 }
 
+func addFlagChecksTimeout(fs *flag.FlagSet) {
+	fs.DurationVar(&flagChecksTimeout, "checks-timeout", time.Hour, "maximum time to wait for the PR's checks to complete (with -wait-for-checks), e.g. 30m")
+}
+
+func addFlagCloneDepth(fs *flag.FlagSet) {
+	fs.IntVar(&flagCloneDepth, "clone-depth", 0, "if positive, perform a shallow clone of the language repo with this many commits of history. "+
+		"Defaults to cloning the full history")
+}
+
+func addFlagCloneRetries(fs *flag.FlagSet) {
+	fs.IntVar(&flagCloneRetries, "clone-retries", 3, "maximum number of times to retry cloning a repo which fails (e.g. due to a network error), with exponential backoff")
+}
+
+func addFlagCommitMessageTemplate(fs *flag.FlagSet) {
+	fs.StringVar(&flagCommitMessageTemplate, "commit-message-template", "", "template for the first line of each library's regeneration commit message "+
+		"(which is followed by the API commits included). Placeholders: {libraryId}, {apiPath} (the library's API paths), {apiCommit} (the latest API commit) and {timestamp}. "+
+		"Defaults to \""+defaultCommitMessageTemplate+"\" for update-apis and \""+defaultGenerateCommitMessageTemplate+"\" for generate (with -commit-per-api) and regenerate-all, "+
+		"for which the API commit is the HEAD of the API root, if it's a git repo. A library's initial generation always uses \"feat: Initial generation for {libraryId}\"")
+}
+
+func addFlagCommitPerApi(fs *flag.FlagSet) {
+	fs.BoolVar(&flagCommitPerApi, "commit-per-api", false, "with -build, commit each library's generated code to the language repo after copying it, "+
+		"so that each regenerated library is a separate commit. Requires the language repo to be clean")
+}
+
+func addFlagConfig(fs *flag.FlagSet) {
+	fs.StringVar(&flagConfig, "config", "", "path to a YAML config file containing flag values (keyed by flag name), and optionally named profiles of flag values. Explicit flags take precedence over the file")
+}
+
+func addFlagConfigProfile(fs *flag.FlagSet) {
+	fs.StringVar(&flagConfigProfile, "config-profile", "", "name of the profile (within the -config file) whose flag values to apply. Explicit flags take precedence over the profile")
+}
+
+func addFlagContainerEnv(fs *flag.FlagSet) {
+	fs.Func("container-env", "environment variable to pass to generation and build containers, as KEY=VALUE, or KEY to use the value from the current environment. May be repeated", func(value string) error {
+		key, _, _ := strings.Cut(value, "=")
+		if !environmentVariableNameRegex.MatchString(key) {
+			return fmt.Errorf("invalid environment variable %q; must be KEY=VALUE or KEY", value)
+		}
+		flagContainerEnv = append(flagContainerEnv, value)
+		return nil
+	})
+}
+
+func addFlagContainerLogs(fs *flag.FlagSet) {
+	fs.BoolVar(&flagContainerLogs, "container-logs", false, "whether to also write container output to a log file per library, in logs within the work-root")
+}
+
+func addFlagContainerRetries(fs *flag.FlagSet) {
+	fs.IntVar(&flagContainerRetries, "container-retries", 0, "number of times to retry generating or building when docker fails with a transient error (e.g. the daemon being unavailable), with exponential backoff")
+}
+
+func addFlagContainerRuntime(fs *flag.FlagSet) {
+	fs.StringVar(&flagContainerRuntime, "container-runtime", container.RuntimeDocker, "container runtime to use: docker or podman")
+}
+
+func addFlagDeleteBranches(fs *flag.FlagSet) {
+	fs.BoolVar(&flagDeleteBranches, "delete-branches", false, "whether to also delete the branches of the PRs which are closed")
+}
+
+func addFlagDetectBreaking(fs *flag.FlagSet) {
+	fs.BoolVar(&flagDetectBreaking, "detect-breaking", false, "whether to run the language container's breaking change detection for each library being released")
+}
+
+func addFlagDraft(fs *flag.FlagSet) {
+	fs.BoolVar(&flagDraft, "draft", false, "whether to create the PR as a draft")
+}
+
+func addFlagDiff(fs *flag.FlagSet) {
+	fs.BoolVar(&flagDiff, "diff", false, "print a unified diff of the changes generation would make to the library in the language repo, "+
+		"without modifying the repo. Only applies to libraries configured in the language repo")
+}
+
+func addFlagDiffStat(fs *flag.FlagSet) {
+	fs.BoolVar(&flagDiffStat, "diff-stat", false, "as -diff, but print only the files changed and the number of lines added and removed in each")
+}
+
+func addFlagDryRun(fs *flag.FlagSet) {
+	fs.BoolVar(&flagDryRun, "dry-run", false, "whether to only log what would be done, without cloning any repos, creating output or running containers")
+}
+
+func addFlagEmitMetadata(fs *flag.FlagSet) {
+	fs.BoolVar(&flagEmitMetadata, "emit-metadata", false, "whether to write a library-metadata.json file describing each library into its generated output")
+}
+
 func addFlagEnvFile(fs *flag.FlagSet) {
 	fs.StringVar(&flagEnvFile, "env-file", "", "full path to the file where the environment variables are stored. Defaults to env-vars.txt within the work-root")
 }
 
+func addFlagExclude(fs *flag.FlagSet) {
+	fs.Func("exclude", "glob pattern (e.g. google-cloud-*-v1beta*) of library IDs not to regenerate. May be repeated", func(value string) error {
+		if _, err := path.Match(value, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", value, err)
+		}
+		flagExclude = append(flagExclude, value)
+		return nil
+	})
+}
+
+func addFlagExistingPRAuthor(fs *flag.FlagSet) {
+	fs.StringVar(&flagExistingPRAuthor, "existing-pr-author", "", "only update (with -update-existing) or close (with close-prs) PRs created by this GitHub user, e.g. the bot account Librarian runs as")
+}
+
+func addFlagExistingPRLabel(fs *flag.FlagSet) {
+	fs.StringVar(&flagExistingPRLabel, "existing-pr-label", "", "only update (with -update-existing) or close (with close-prs) PRs with this label")
+}
+
+func addFlagFailFast(fs *flag.FlagSet) {
+	fs.BoolVar(&flagFailFast, "fail-fast", false, "stop at the first library or API which fails, returning its error without creating a pull request, "+
+		"rather than continuing and reporting failures in the pull request")
+}
+
+func addFlagForce(fs *flag.FlagSet) {
+	fs.BoolVar(&flagForce, "force", false, "generate each library even if its inputs (API files, image and generator-input) are unchanged since its code was last committed with -commit-per-api. "+
+		"Without -build and -commit-per-api, or with -local-generator, libraries are never skipped")
+}
+
+func addFlagForkRepo(fs *flag.FlagSet) {
+	fs.StringVar(&flagForkRepo, "fork-repo", "", "URL of a fork of the language repo to push PR branches to, "+
+		"for creating cross-repository PRs without write access to the language repo. "+
+		"Use 'auto' for the authenticated user's fork (with the same name as the language repo)")
+}
+
+func addFlagFormat(fs *flag.FlagSet) {
+	fs.StringVar(&flagFormat, "format", formatTable, "output format: table or json")
+}
+
+func addFlagGenerateTimeout(fs *flag.FlagSet) {
+	fs.DurationVar(&flagGenerateTimeout, "generate-timeout", 0, "maximum time to allow for generating (and validating, cleaning and building, if requested) each API, e.g. 30m. The running container is killed if this is exceeded. Defaults to no timeout")
+}
+
+func addFlagGeneratorInputDir(fs *flag.FlagSet) {
+	fs.StringVar(&flagGeneratorInputDir, "generator-input-dir", defaultGeneratorInputDir, "directory within the language repo containing the pipeline state, pipeline configuration and other generator input")
+}
+
+func addFlagGitHubAppID(fs *flag.FlagSet) {
+	fs.Int64Var(&flagGitHubAppID, "github-app-id", 0, "ID of the GitHub App to authenticate as. When specified, -github-app-installation-id "+
+		"and -github-app-private-key are required, and an installation access token is used in preference to any other GitHub token")
+}
+
+func addFlagGitHubAppInstallationID(fs *flag.FlagSet) {
+	fs.Int64Var(&flagGitHubAppInstallationID, "github-app-installation-id", 0, "ID of the installation of the GitHub App (see -github-app-id)")
+}
+
+func addFlagGitHubAppPrivateKey(fs *flag.FlagSet) {
+	fs.StringVar(&flagGitHubAppPrivateKey, "github-app-private-key", "", "PEM file containing the private key of the GitHub App (see -github-app-id)")
+}
+
+func addFlagGitHubRetries(fs *flag.FlagSet) {
+	fs.IntVar(&flagGitHubRetries, "github-retries", 3, "maximum number of times to retry each GitHub API request which fails due to rate limiting or a server error")
+}
+
+func addFlagGitHubTokenFile(fs *flag.FlagSet) {
+	fs.StringVar(&flagGitHubTokenFile, "github-token-file", "", "file containing the GitHub access token, or - to read it from stdin. "+
+		"Takes precedence over the LIBRARIAN_GITHUB_TOKEN environment variable")
+}
+
 func addFlagGitUserEmail(fs *flag.FlagSet) {
-	fs.StringVar(&flagGitUserEmail, "git-user-email", "", "Email address to use in Git commits")
+	fs.StringVar(&flagGitUserEmail, "git-user-email", os.Getenv(gitUserEmailEnvironmentVariable),
+		"Email address to use as the author and committer of Git commits. Defaults to the value of "+gitUserEmailEnvironmentVariable)
 }
 
 func addFlagGitUserName(fs *flag.FlagSet) {
-	fs.StringVar(&flagGitUserName, "git-user-name", "", "Display name to use in Git commits")
+	fs.StringVar(&flagGitUserName, "git-user-name", os.Getenv(gitUserNameEnvironmentVariable),
+		"Display name to use as the author and committer of Git commits. Defaults to the value of "+gitUserNameEnvironmentVariable)
+}
+
+func addFlagHistoryFile(fs *flag.FlagSet) {
+	fs.StringVar(&flagHistoryFile, "history-file", "", "file to which a record of the run is appended as a line of JSON, for reproducibility: "+
+		"its outcome, the language repo base commit, the image and its digest, and any PRs created")
 }
 
 func addFlagImage(fs *flag.FlagSet) {
 	fs.StringVar(&flagImage, "image", "", "language-specific container to run for subcommands. Defaults to google-cloud-{language}-generator")
 }
 
+func addFlagImageDigest(fs *flag.FlagSet) {
+	fs.StringVar(&flagImageDigest, "image-digest", "", "expected sha256 digest of the image. If specified, the image is pulled and generation is aborted if its digest doesn't match; otherwise, the image is run by that digest rather than by tag")
+}
+
+func addFlagImages(fs *flag.FlagSet) {
+	fs.Func("images", "comma-separated mapping from language to image, e.g. go=example.com/go-generator:1.2,python=example.com/python-generator:3.4, "+
+		"from which the image for -language is selected when -image isn't specified. May be repeated", func(value string) error {
+		for _, entry := range strings.Split(value, ",") {
+			language, image, ok := strings.Cut(strings.TrimSpace(entry), "=")
+			if !ok || language == "" || image == "" {
+				return fmt.Errorf("invalid entry %q; must be LANGUAGE=IMAGE", entry)
+			}
+			if !slices.Contains(supportedLanguages, language) {
+				return fmt.Errorf("invalid language %q; must be one of %s", language, strings.Join(supportedLanguages, ", "))
+			}
+			flagImages = append(flagImages, language+"="+image)
+		}
+		return nil
+	})
+}
+
+func addFlagInclude(fs *flag.FlagSet) {
+	fs.Func("include", "glob pattern (e.g. google-cloud-storage*) of library IDs to regenerate. May be repeated. Defaults to all libraries", func(value string) error {
+		if _, err := path.Match(value, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", value, err)
+		}
+		flagInclude = append(flagInclude, value)
+		return nil
+	})
+}
+
+func addFlagKeepBranchOnFailure(fs *flag.FlagSet) {
+	fs.BoolVar(&flagKeepBranchOnFailure, "keep-branch-on-failure", false, "whether to keep the pushed branch if creating the PR fails. By default it's deleted")
+}
+
+func addFlagKeepWorkRoot(fs *flag.FlagSet) {
+	fs.BoolVar(&flagKeepWorkRoot, "keep-work-root", false, "keep the temporary work root after a successful run, for debugging "+
+		"(it's always kept after a failed run, and a work root specified with -work-root is never removed)")
+}
+
 func addFlagLanguage(fs *flag.FlagSet) {
-	fs.StringVar(&flagLanguage, "language", "", "(Required) language to generate code for")
+	fs.StringVar(&flagLanguage, "language", "", "(Required) language to generate code for: "+strings.Join(supportedLanguages, ", "))
+}
+
+// Checks that -generator-input-dir is a relative path within the language repo.
+func validateGeneratorInputDir() error {
+	if !filepath.IsLocal(flagGeneratorInputDir) {
+		return fmt.Errorf("invalid -generator-input-dir value %q; must be a relative path within the language repo", flagGeneratorInputDir)
+	}
+	return nil
+}
+
+// Normalizes each of the (comma-separated) -api-path values (see normalizeAPIPath), and
+// checks that each is a relative path within the API root.
+func normalizeAPIPathFlag() error {
+	if flagAPIPath == "" {
+		return nil
+	}
+	apiPaths := []string{}
+	for _, apiPath := range strings.Split(flagAPIPath, ",") {
+		if strings.TrimSpace(apiPath) == "" {
+			continue
+		}
+		normalized := normalizeAPIPath(apiPath)
+		if normalized == "" || slices.Contains(strings.Split(normalized, "/"), "..") || strings.Contains(normalized, ":") {
+			return fmt.Errorf("invalid -api-path value %q; must be a relative path within the API root, e.g. google/cloud/functions/v2", strings.TrimSpace(apiPath))
+		}
+		apiPaths = append(apiPaths, normalized)
+	}
+	flagAPIPath = strings.Join(apiPaths, ",")
+	return nil
+}
+
+// Normalizes -language (trimming whitespace and converting to lower case), and checks
+// that it's supported, so that a typo isn't reported as a confusing container error.
+func normalizeLanguageFlag() error {
+	if flagLanguage == "" {
+		return nil
+	}
+	flagLanguage = strings.ToLower(strings.TrimSpace(flagLanguage))
+	if !slices.Contains(supportedLanguages, flagLanguage) {
+		return fmt.Errorf("unsupported language %q; must be one of %s", flagLanguage, strings.Join(supportedLanguages, ", "))
+	}
+	return nil
 }
 
 func addFlagLibraryID(fs *flag.FlagSet) {
@@ -105,10 +502,117 @@ func addFlagLibraryVersion(fs *flag.FlagSet) {
 	fs.StringVar(&flagLibraryVersion, "library-version", "", "The version to release (only valid with library-id, only when creating a release PR)")
 }
 
+func addFlagLineEndings(fs *flag.FlagSet) {
+	fs.StringVar(&flagLineEndings, "line-endings", lineEndingsPreserve, "line endings to use for generated text files when copying them into the repo: lf, crlf or preserve. Binary files are never modified")
+}
+
+func addFlagLocalGenerator(fs *flag.FlagSet) {
+	fs.StringVar(&flagLocalGenerator, "local-generator", "", "path to a local executable to run instead of the generator image for generation, for developing the generator. "+
+		"It's invoked with the same command and arguments as the image's entrypoint, with host paths instead of container paths. Builds, post-processing scripts and -validate-image still use images")
+}
+
+func addFlagLogFormat(fs *flag.FlagSet) {
+	fs.StringVar(&flagLogFormat, "log-format", logFormatText, "format of log output: text or json")
+}
+
+func addFlagLogLevel(fs *flag.FlagSet) {
+	fs.StringVar(&flagLogLevel, "log-level", "info", "minimum level of log messages to output: debug, info, warn or error. "+
+		"At debug level, the full command line of each container invocation is logged")
+}
+
+func addFlagMaxConcurrency(fs *flag.FlagSet) {
+	fs.IntVar(&flagMaxConcurrency, "max-concurrency", 1, "maximum number of APIs to generate in parallel, when multiple API paths are specified")
+}
+
+func addFlagMaxOutputSize(fs *flag.FlagSet) {
+	fs.Var(&flagMaxOutputSize, "max-output-size", "maximum total size of the code generated for each API, e.g. 500MB or 2GiB. The generator is killed "+
+		"if its output exceeds this, and the output is checked again before it's copied into the language repo. Defaults to no limit")
+}
+
+func addFlagMirrorRepoUrl(fs *flag.FlagSet) {
+	fs.StringVar(&flagMirrorRepoUrl, "mirror-repo-url", "", "Repository URL of a mirror repo to which generated code is also committed, in a separate PR. "+
+		"For generate, this is the only repo which is pushed to (with -push)")
+}
+
+func addFlagNoStateCache(fs *flag.FlagSet) {
+	fs.BoolVar(&flagNoStateCache, "no-state-cache", false, "always fetch the pipeline state of a remote repo, rather than using a cached copy (see -state-cache-ttl)")
+}
+
+func addFlagOffline(fs *flag.FlagSet) {
+	fs.BoolVar(&flagOffline, "offline", false, "refuse every operation requiring network access (fetching remote state, cloning, pushing, "+
+		"pulling images, GitHub API requests and Secret Manager access). Containers are run without network access, and the image must be present locally")
+}
+
+func addFlagOlderThan(fs *flag.FlagSet) {
+	fs.DurationVar(&flagOlderThan, "older-than", 0, "(Required) only close PRs created longer ago than this, e.g. 168h for a week")
+}
+
+func addFlagOutput(fs *flag.FlagSet) {
+	fs.StringVar(&flagOutput, "output", "", "directory in which to generate code. Defaults to output within the work-root")
+}
+
+func addFlagPRAssignee(fs *flag.FlagSet) {
+	fs.Func("pr-assignee", "GitHub username to assign to the created PR. May be repeated", func(value string) error {
+		if strings.TrimSpace(value) == "" {
+			return errors.New("PR assignee must not be empty")
+		}
+		flagPRAssignees = append(flagPRAssignees, value)
+		return nil
+	})
+}
+
+func addFlagPRAutoMerge(fs *flag.FlagSet) {
+	fs.BoolVar(&flagPRAutoMerge, "pr-auto-merge", false, "whether to enable GitHub auto-merge on the created PR, so it's merged once checks pass")
+}
+
+func addFlagPRAutoMergeMethod(fs *flag.FlagSet) {
+	fs.StringVar(&flagPRAutoMergeMethod, "pr-auto-merge-method", "squash", "merge method to use with -pr-auto-merge: merge, squash or rebase")
+}
+
+func addFlagPRLabel(fs *flag.FlagSet) {
+	fs.Func("pr-label", "label to apply to the created PR. May be repeated", func(value string) error {
+		if strings.TrimSpace(value) == "" {
+			return errors.New("PR label must not be empty")
+		}
+		flagPRLabels = append(flagPRLabels, value)
+		return nil
+	})
+}
+
+func addFlagPRReviewer(fs *flag.FlagSet) {
+	fs.Func("pr-reviewer", "GitHub username, or team as org/team, to request a review from for the created PR. May be repeated", func(value string) error {
+		if strings.TrimSpace(value) == "" {
+			return errors.New("PR reviewer must not be empty")
+		}
+		flagPRReviewers = append(flagPRReviewers, value)
+		return nil
+	})
+}
+
+func addFlagPostGenerateHook(fs *flag.FlagSet) {
+	fs.StringVar(&flagPostGenerateHook, "post-generate-hook", "", "shell command to run after generating each library (or API path, for raw generation), "+
+		"before building; it's passed the output directory and library ID (or API path) as arguments, and as "+
+		postGenerateHookOutputDirEnvironmentVariable+" and "+postGenerateHookLibraryIDEnvironmentVariable+". A non-zero exit is treated as a generation failure")
+}
+
+func addFlagPrune(fs *flag.FlagSet) {
+	fs.BoolVar(&flagPrune, "prune", false, "after copying generated code into the language repo, remove files under the library's "+
+		"generated source paths which the generator didn't emit, so that renamed or removed generated files don't linger")
+}
+
+func addFlagPull(fs *flag.FlagSet) {
+	fs.BoolVar(&flagPull, "pull", false, "whether to pull the image before generating, logging its digest")
+}
+
 func addFlagPush(fs *flag.FlagSet) {
 	fs.BoolVar(&flagPush, "push", false, "push to GitHub if true")
 }
 
+func addFlagRegistryConfig(fs *flag.FlagSet) {
+	fs.StringVar(&flagRegistryConfig, "registry-config", "", "path to a container registry config file (a docker config.json, or a podman auth file) "+
+		"used to authenticate when pulling images, instead of the ambient login state; any credential helpers it specifies must be installed")
+}
+
 func addFlagReleaseID(fs *flag.FlagSet) {
 	fs.StringVar(&flagReleaseID, "release-id", "", "The ID of a release PR")
 }
@@ -117,6 +621,11 @@ func addFlagReleasePRUrl(fs *flag.FlagSet) {
 	fs.StringVar(&flagReleasePRUrl, "release-pr-url", "", "The URL of a release PR")
 }
 
+func addFlagRepoRef(fs *flag.FlagSet) {
+	fs.StringVar(&flagRepoRef, "repo-ref", "HEAD", "branch, tag or commit of the repo specified with -repo-url whose pipeline state is used, "+
+		"and which is checked out when the repo is cloned for refined generation")
+}
+
 func addFlagRepoRoot(fs *flag.FlagSet) {
 	fs.StringVar(&flagRepoRoot, "repo-root", "", "Repository root. When this (and repo-url) are not specified, the language repo will be cloned.")
 }
@@ -125,14 +634,78 @@ func addFlagRepoUrl(fs *flag.FlagSet) {
 	fs.StringVar(&flagRepoUrl, "repo-url", "", "Repository URL to clone. If this and repo-root are not specified, the default language repo will be cloned.")
 }
 
+func addFlagRequireRefined(fs *flag.FlagSet) {
+	fs.BoolVar(&flagRequireRefined, "require-refined", false, "fail (rather than falling back to raw generation) for any API path "+
+		"which isn't configured in a library in the language repo, or if no language repo is specified")
+}
+
+func addFlagResetRepo(fs *flag.FlagSet) {
+	fs.BoolVar(&flagResetRepo, "reset-repo", false, "whether to discard uncommitted changes, untracked files and any in-progress operation (such as a rebase) in the language repo, "+
+		"rather than refusing to use it. Take care with -repo-root, as local work is lost")
+}
+
+func addFlagSecretProvider(fs *flag.FlagSet) {
+	fs.StringVar(&flagSecretProvider, "secret-provider", secrets.ProviderGcp, "source of secrets, such as the GitHub access token and container environment variables: "+
+		"gcp (Secret Manager in -secrets-project, if specified), env (environment variables named after the secrets, e.g. LIBRARIAN_GITHUB_TOKEN for librarian-github-token), "+
+		"file (files named after the secrets in -secrets-dir) or vault (the KV secrets engine at -vault-mount of the server at VAULT_ADDR, using VAULT_TOKEN)")
+}
+
+func addFlagSecretsDir(fs *flag.FlagSet) {
+	fs.StringVar(&flagSecretsDir, "secrets-dir", "", "directory containing a file for each secret, for -secret-provider=file")
+}
+
 func addFlagSecretsProject(fs *flag.FlagSet) {
 	fs.StringVar(&flagSecretsProject, "secrets-project", "", "Project containing Secret Manager secrets.")
 }
 
+func addFlagSignCommits(fs *flag.FlagSet) {
+	fs.BoolVar(&flagSignCommits, "sign-commits", false, "whether to sign commits, using the key specified by -signing-key")
+}
+
+func addFlagSigningKey(fs *flag.FlagSet) {
+	fs.StringVar(&flagSigningKey, "signing-key", "", "path to the private key used with -sign-commits: either an armored GPG private key, or an SSH private key. "+
+		"If the key is encrypted, its passphrase is read from LIBRARIAN_SIGNING_KEY_PASSPHRASE")
+}
+
+func addFlagSince(fs *flag.FlagSet) {
+	fs.StringVar(&flagSince, "since", "", "branch, tag or commit of the API root (which must be a git repo) since which API paths must have changed to be generated. "+
+		"API paths whose directories are unchanged between this ref and HEAD are skipped")
+}
+
 func addFlagSkipIntegrationTests(fs *flag.FlagSet) {
 	fs.StringVar(&flagSkipIntegrationTests, "skip-integration-tests", "", "set to a value of b/{explanatory-bug} to skip integration tests")
 }
 
+func addFlagSquash(fs *flag.FlagSet) {
+	fs.BoolVar(&flagSquash, "squash", false, "whether to squash commits into a single commit: for merge-release-pr, the release PR commits when merging; "+
+		"for update-apis, the commit for each library, before creating the PR")
+}
+
+func addFlagSquashMessageTemplate(fs *flag.FlagSet) {
+	fs.StringVar(&flagSquashTemplate, "squash-message-template", "", "Go template for the commit message when squashing a release PR. "+
+		"The data has fields ReleaseID and Libraries; each library has LibraryID, PreviousVersion, Version, ReleaseNotes and Message (the original commit message). "+
+		"The first line is used as the commit title. Each library's Message must be included for release artifacts to be created from the squashed commit. "+
+		"Defaults to a title summarizing the releases, followed by each original commit message.")
+}
+
+func addFlagStateCacheTTL(fs *flag.FlagSet) {
+	fs.DurationVar(&flagStateCacheTTL, "state-cache-ttl", defaultStateCacheTTL, "how long pipeline state fetched from a remote repo is cached for, "+
+		"for each repo and ref. The cache is kept in the -work-root directory if specified, and in the system temporary directory otherwise. Zero disables caching")
+}
+
+func addFlagStreamOutput(fs *flag.FlagSet) {
+	fs.BoolVar(&flagStreamOutput, "stream-output", false, "whether to write the generated code to stdout as a tar stream, instead of retaining it in the work-root. Incompatible with -build")
+}
+
+func addFlagSummaryFile(fs *flag.FlagSet) {
+	fs.StringVar(&flagSummaryFile, "summary-file", "", "path to a file to which a JSON summary of the run is written")
+}
+
+func addFlagSSHKey(fs *flag.FlagSet) {
+	fs.StringVar(&flagSSHKey, "ssh-key", "", "path to an SSH private key to use for git operations with SSH remotes (e.g. git@github.com:owner/repo.git). "+
+		"When specified without LIBRARIAN_GITHUB_TOKEN, pushes to GitHub HTTPS remotes use SSH instead. Defaults to using the SSH agent for SSH remotes")
+}
+
 func addFlagSyncUrlPrefix(fs *flag.FlagSet) {
 	fs.StringVar(&flagSyncUrlPrefix, "sync-url-prefix", "", "the prefix of the URL to check for commit synchronization; the commit hash will be appended to this")
 }
@@ -145,14 +718,109 @@ func addFlagTagRepoUrl(fs *flag.FlagSet) {
 	fs.StringVar(&flagTagRepoUrl, "tag-repo-url", "", "Repository URL to tag and create releases in. Requires when push is true.")
 }
 
+func addFlagTitleTimezone(fs *flag.FlagSet) {
+	fs.StringVar(&flagTitleTimezone, "title-timezone", "Local", "timezone for the timestamp in PR titles: Local, UTC or an IANA name such as America/New_York")
+}
+
+func addFlagUpdateExisting(fs *flag.FlagSet) {
+	fs.BoolVar(&flagUpdateExisting, "update-existing", false, "whether to update an open PR previously created by Librarian for the same kind of change "+
+		"(identified by its branch matching -branch-template), replacing its branch and description, instead of creating a new PR")
+}
+
+func addFlagValidateImage(fs *flag.FlagSet) {
+	fs.StringVar(&flagValidateImage, "validate-image", "", "container image to run against generated code to validate it (e.g. with organization-specific linters) before building")
+}
+
+func addFlagVaultMount(fs *flag.FlagSet) {
+	fs.StringVar(&flagVaultMount, "vault-mount", "secret", "mount path of the Vault KV (version 2) secrets engine, for -secret-provider=vault. "+
+		"Each secret is read from the \"value\" key at {mount}/{name}")
+}
+
+func addFlagVerbosePRErrors(fs *flag.FlagSet) {
+	fs.BoolVar(&flagVerbosePRErrors, "verbose-pr-errors", false, "whether to include full error details in PR descriptions. "+
+		"WARNING: this may expose internal details (e.g. paths, configuration or secrets in tool output) in the PR, so only use it for trusted, private repos")
+}
+
+func addFlagWaitForChecks(fs *flag.FlagSet) {
+	fs.BoolVar(&flagWaitForChecks, "wait-for-checks", false, "whether to wait, after creating (or updating) a PR, until its checks complete, "+
+		"failing if any check fails or -checks-timeout elapses first")
+}
+
 func addFlagWorkRoot(fs *flag.FlagSet) {
-	fs.StringVar(&flagWorkRoot, "work-root", "", "Working directory root. When this is not specified, a working directory will be created in /tmp.")
+	fs.StringVar(&flagWorkRoot, "work-root", "", "Working directory root. When this is not specified, a working directory will be created in /tmp, and removed after a successful run unless -keep-work-root is specified.")
 }
 
-func validatePush() error {
-	if flagPush && githubrepo.GetAccessToken() == "" {
+// Validates that if we're going to push, we have a GitHub token which will allow
+// us to do so, and resolves the fork to push to if -fork-repo is specified. This is
+// checked before any other work is performed, so that problems are reported early
+// rather than after (potentially lengthy) generation.
+func validatePush(state *commandState) error {
+	if !flagPush {
+		return nil
+	}
+	if githubrepo.GetAccessToken() == "" {
 		return errors.New("no GitHub token supplied for push")
 	}
+	if err := githubrepo.CheckAccessTokenScopes(state.ctx); err != nil {
+		return err
+	}
+	if flagForkRepo == "" || state.languageRepo == nil {
+		return nil
+	}
+	upstream, err := gitrepo.GetGitHubRepoFromRemote(state.languageRepo)
+	if err != nil {
+		return err
+	}
+	forkRepo, err := resolveForkRepo(state.ctx, upstream)
+	if err != nil {
+		return err
+	}
+	slog.Info(fmt.Sprintf("Pushing PR branches to fork %s/%s", forkRepo.Owner, forkRepo.Name))
+	state.forkRepo = &forkRepo
+	return nil
+}
+
+// Returns the fork of the upstream repo specified by -fork-repo, which must be non-empty.
+func resolveForkRepo(ctx context.Context, upstream githubrepo.GitHubRepo) (githubrepo.GitHubRepo, error) {
+	var forkRepo githubrepo.GitHubRepo
+	var err error
+	if flagForkRepo == "auto" {
+		forkRepo, err = githubrepo.FindFork(ctx, upstream)
+	} else {
+		forkRepo, err = githubrepo.ParseUrl(flagForkRepo)
+	}
+	if err != nil {
+		return githubrepo.GitHubRepo{}, fmt.Errorf("invalid -fork-repo: %w", err)
+	}
+	return forkRepo, nil
+}
+
+// Checks a -line-endings value. An empty value is equivalent to "preserve".
+func validateLineEndings(lineEndings string) error {
+	switch lineEndings {
+	case "", lineEndingsPreserve, lineEndingsLF, lineEndingsCRLF:
+		return nil
+	default:
+		return fmt.Errorf("invalid -line-endings value %q; must be lf, crlf or preserve", lineEndings)
+	}
+}
+
+func validatePRAutoMerge(opts pullRequestOptions) error {
+	if !opts.autoMerge {
+		return nil
+	}
+	switch opts.autoMergeMethod {
+	case "merge", "squash", "rebase":
+		return nil
+	default:
+		return fmt.Errorf("invalid -pr-auto-merge-method value %q; must be merge, squash or rebase", opts.autoMergeMethod)
+	}
+}
+
+func validateTitleTimezone() error {
+	if _, err := time.LoadLocation(flagTitleTimezone); err != nil {
+		return fmt.Errorf("invalid -title-timezone value %q: %w", flagTitleTimezone, err)
+	}
 	return nil
 }
 
@@ -15,18 +15,29 @@
 package command
 
 import (
+	"archive/tar"
 	"context"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"io/fs"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/googleapis/librarian/internal/container"
 	"github.com/googleapis/librarian/internal/githubrepo"
 	"github.com/googleapis/librarian/internal/gitrepo"
+	"github.com/googleapis/librarian/internal/secrets"
 	"github.com/googleapis/librarian/internal/statepb"
+	"github.com/googleapis/librarian/internal/tracing"
 )
 
 var CmdGenerate = &Command{
@@ -34,14 +45,77 @@ var CmdGenerate = &Command{
 	Short: "Generate client library code for an API.",
 	flagFunctions: []func(fs *flag.FlagSet){
 		addFlagImage,
+		addFlagImages,
 		addFlagWorkRoot,
 		addFlagAPIPath,
 		addFlagAPIRoot,
+		addFlagSince,
 		addFlagLanguage,
 		addFlagBuild,
+		addFlagCommitPerApi,
+		addFlagCommitMessageTemplate,
 		addFlagRepoRoot,
+		addFlagResetRepo,
+		addFlagAllowDirty,
 		addFlagRepoUrl,
+		addFlagRepoRef,
+		addFlagRequireRefined,
+		addFlagForce,
+		addFlagStateCacheTTL,
+		addFlagNoStateCache,
+		addFlagSSHKey,
+		addFlagCloneDepth,
+		addFlagSecretProvider,
+		addFlagSecretsDir,
 		addFlagSecretsProject,
+		addFlagVaultMount,
+		addFlagMirrorRepoUrl,
+		addFlagEmitMetadata,
+		addFlagValidateImage,
+		addFlagPush,
+		addFlagGitUserEmail,
+		addFlagGitUserName,
+		addFlagSignCommits,
+		addFlagSigningKey,
+		addFlagStreamOutput,
+		addFlagDryRun,
+		addFlagDiff,
+		addFlagDiffStat,
+		addFlagGenerateTimeout,
+		addFlagMaxOutputSize,
+		addFlagMaxConcurrency,
+		addFlagOutput,
+		addFlagSummaryFile,
+		addFlagPull,
+		addFlagImageDigest,
+		addFlagLineEndings,
+		addFlagPostGenerateHook,
+		addFlagPrune,
+		addFlagPRAutoMerge,
+		addFlagPRAutoMergeMethod,
+		addFlagDraft,
+		addFlagPRLabel,
+		addFlagPRReviewer,
+		addFlagPRAssignee,
+		addFlagBranchPrefix,
+		addFlagBaseBranch,
+		addFlagHistoryFile,
+		addFlagBranchTemplate,
+		addFlagTitleTimezone,
+		addFlagKeepBranchOnFailure,
+		addFlagWaitForChecks,
+		addFlagChecksTimeout,
+		addFlagVerbosePRErrors,
+		addFlagFailFast,
+		addFlagUpdateExisting,
+		addFlagExistingPRAuthor,
+		addFlagExistingPRLabel,
+		addFlagContainerRetries,
+		addFlagContainerRuntime,
+		addFlagLocalGenerator,
+		addFlagRegistryConfig,
+		addFlagContainerLogs,
+		addFlagContainerEnv,
 	},
 	// By default don't clone a language repo, we will clone later only if library exists in language repo.
 	maybeGetLanguageRepo: openOrCloneLanguageRepoIfLibraryExists,
@@ -50,6 +124,7 @@ var CmdGenerate = &Command{
 	// with the right image etc.
 	maybeLoadStateAndConfig: loadRepoStateAndConfig,
 	execute:                 runGenerate,
+	runsContainers:          true,
 }
 
 func runGenerate(state *commandState) error {
@@ -59,103 +134,1060 @@ func runGenerate(state *commandState) error {
 	if err := validateRequiredFlag("api-root", flagAPIRoot); err != nil {
 		return err
 	}
+	// The flags are read once, here, and the options passed to everything which needs them.
+	opts := generateOptionsFromFlags()
+	prOpts := pullRequestOptionsFromFlags()
+	// generate never pushes to the language repo: -push and the PR flags only apply to the
+	// mirror repo (see pushToMirrorRepo), and -git-user-name and -git-user-email to commits
+	// made with -commit-per-api or to the mirror repo.
+	if err := validateMirrorPush(prOpts); err != nil {
+		return err
+	}
+	if err := validatePush(state); err != nil {
+		return err
+	}
+	if err := validateLineEndings(opts.LineEndings); err != nil {
+		return err
+	}
+	if err := validatePRAutoMerge(prOpts); err != nil {
+		return err
+	}
+	if err := validateTitleTimezone(); err != nil {
+		return err
+	}
+
+	if flagStreamOutput && opts.Build {
+		return errors.New("-stream-output cannot be used with -build")
+	}
+	if flagStreamOutput && flagOutput != "" {
+		return errors.New("-stream-output cannot be used with -output")
+	}
+	diffing := opts.Diff || opts.DiffStat
+	if diffing && opts.Build {
+		return errors.New("-diff and -diff-stat cannot be used with -build")
+	}
+	if diffing && flagStreamOutput {
+		return errors.New("-diff and -diff-stat cannot be used with -stream-output")
+	}
+	// This is also checked by generate, but checking it here reports a typo before pulling the image.
+	if err := validateAPIPathsExist(opts.ApiRoot, opts.ApiPaths); err != nil {
+		return err
+	}
+
+	if err := maybePullImage(state, &opts); err != nil {
+		return err
+	}
 
 	outputDir := filepath.Join(state.workRoot, "output")
-	if err := os.Mkdir(outputDir, 0755); err != nil {
+	if flagOutput != "" {
+		var err error
+		if outputDir, err = filepath.Abs(flagOutput); err != nil {
+			return err
+		}
+	}
+	if opts.DryRun {
+		// The language repo is never opened in a dry run, but we still want to report
+		// which libraries would be generated.
+		if state.pipelineState == nil {
+			pipelineState, err := loadConfiguredPipelineState(state.ctx, languageRepoOptionsFromFlags())
+			if err != nil {
+				return err
+			}
+			state.pipelineState = pipelineState
+		}
+	} else if flagStreamOutput {
+		// When streaming, there's no need to retain the output: generate into a temporary
+		// directory which is removed after streaming. Container output is redirected to
+		// stderr so that stdout only contains the tar stream.
+		tempDir, err := os.MkdirTemp("", "librarian-output-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tempDir)
+		outputDir = tempDir
+		state.containerConfig.Stdout = os.Stderr
+	} else {
+		if diffing {
+			// Keep stdout for the diff.
+			state.containerConfig.Stdout = os.Stderr
+		}
+		warnIfNotEmpty(outputDir)
+		// The generated code is only copied into the language repo when building.
+		if flagOutput == "" && (state.languageRepo == nil || !opts.Build) {
+			retainWorkRoot(state, "generated code")
+		}
+		// Otherwise it's copied (and perhaps committed) into the language repo which, unless
+		// -repo-root is specified, is a clone within the work root, and never pushed.
+		if opts.Build && state.languageRepo != nil && flagRepoRoot == "" {
+			retainWorkRoot(state, "language repo")
+		}
+	}
+
+	opts.OutputDir = outputDir
+	generated, err := generate(state, opts)
+	if err != nil {
+		return err
+	}
+	results := generated.Apis
+	if err := maybeWriteGenerateSummary(state, results); err != nil {
 		return err
 	}
+	if len(results) == 0 {
+		// Every API path was unchanged since -since.
+		return nil
+	}
+	for _, result := range results {
+		if result.Err == nil {
+			fmt.Print(result.Diff)
+		}
+	}
+	// Repeat any warnings at the end, so that they're not lost in the rest of the output.
+	for _, result := range results {
+		for _, warning := range result.Warnings {
+			slog.Warn(warning)
+		}
+	}
+	if len(results) == 1 && results[0].Err != nil {
+		return results[0].Err
+	}
+	summary := new(PullRequestContent)
+	mirrorDirs := []string{}
+	for _, result := range results {
+		if result.Err != nil {
+			addErrorToPullRequest(summary, result.ApiPath, result.Err, "generating")
+		} else if !opts.DryRun && !result.Skipped {
+			id := result.LibraryID
+			if id == "" {
+				id = result.ApiPath
+			}
+			addSuccessToPullRequest(summary, id, "generating", result.Description)
+			mirrorDirs = append(mirrorDirs, result.OutputDir)
+		}
+	}
+	if opts.DryRun {
+		return nil
+	}
+	if len(results) > 1 {
+		skipped := 0
+		for _, result := range results {
+			if result.Skipped {
+				skipped++
+			}
+		}
+		slog.Info(fmt.Sprintf("Generated %d of %d API paths successfully (%d skipped as unchanged)", len(summary.Successes), len(results), skipped))
+	}
+
+	if err := pushToMirrorRepo(state, prOpts, opts.LineEndings, mirrorDirs, summary.Successes, "feat: API regeneration", "regen"); err != nil {
+		return err
+	}
+	if flagStreamOutput {
+		if err := streamOutput(outputDir, os.Stdout); err != nil {
+			return err
+		}
+	}
+	if len(summary.Errors) > 0 {
+		return fmt.Errorf("failed to generate %d of %d API paths", len(summary.Errors), len(results))
+	}
+	return nil
+}
+
+// GenerateOptions specifies what Generate generates, and how. It corresponds to those flags
+// of the generate command which affect generation itself; Generate never reads the flags.
+type GenerateOptions struct {
+	// The API paths to generate, relative to ApiRoot. At least one is required.
+	ApiPaths []string
+	// The root of the API repo (e.g. a googleapis clone). Required.
+	ApiRoot string
+	// Additional API roots containing protos imported by those in ApiRoot, e.g. from other
+	// repos. They're passed to generation as include roots, in order.
+	ApiIncludeRoots []string
+	// The directory into which code is generated. When multiple API paths are specified,
+	// each is generated into its own subdirectory. Defaults to "output" within WorkRoot.
+	OutputDir string
+	// The work root, used for container-mounted files and other temporary state. Required
+	// by Generate; it's neither created nor removed.
+	WorkRoot string
+	// The local language repo whose pipeline state determines which API paths use refined
+	// generation. If empty, raw generation is used for every API path. The repo must be clean.
+	RepoRoot string
+	// The directory within the language repo containing the pipeline state and other
+	// generator input. Defaults to "generator-input".
+	GeneratorInputDir string
+	// The generator image. If empty, it's derived from Language and the pipeline state's
+	// image tag, as for the -image flag.
+	Image string
+	// The language whose generator image is used, if Image isn't specified.
+	Language string
+	// The container runtime: "docker" (the default) or "podman".
+	ContainerRuntime string
+	// The Google Cloud project from which secrets are fetched for the container, if any.
+	SecretsProject string
+	// The provider from which secrets are fetched for the container, if any. Takes
+	// precedence over SecretsProject.
+	SecretProvider secrets.SecretProvider
+	// A generator executable to run on the host instead of the image, for generation only.
+	LocalGenerator string
+	// The image run to validate generated code, if any.
+	ValidationImage string
+	// The number of times to retry container commands which fail with transient errors.
+	Retries int
+	// Additional environment variables for the container, each KEY=VALUE or KEY.
+	Environment []string
+	// The registry config file used to authenticate when pulling the image, if any.
+	RegistryConfig string
+	// Whether to pull the image before generating. This is implied by ImageDigest.
+	Pull bool
+	// The digest which the image must have, if specified. The image is then run by digest.
+	ImageDigest string
+
+	// Whether to build the generated code, after copying it into the language repo for
+	// refined generation.
+	Build bool
+	// Whether to commit each library's code to the language repo after copying it, when
+	// building. Requires the language repo to be clean.
+	CommitPerApi bool
+	// The template for the message of each library's commit, with CommitPerApi. The
+	// placeholders are as for -commit-message-template. Defaults to "feat: Regenerate {libraryId}".
+	CommitMessageTemplate string
+	// The author of each library's commit, with CommitPerApi. Defaults to the same identity
+	// as -git-user-name and -git-user-email.
+	GitUserName  string
+	GitUserEmail string
+	// The line endings for generated text files copied into the language repo: "lf",
+	// "crlf" or "preserve" (the default).
+	LineEndings string
+	// Whether to remove stale generated files from the language repo after copying.
+	Prune bool
+	// Whether to write a library metadata file into each library's generated code.
+	EmitMetadata bool
+	// The branch of the language repo referenced by library metadata, if not the default.
+	BaseBranch string
+	// A shell command run on the host after generating each API path, as for
+	// -post-generate-hook.
+	PostGenerateHook string
+	// Whether to regenerate libraries whose inputs are unchanged since they were last
+	// committed. Otherwise, such libraries are skipped, but only with Build and CommitPerApi
+	// (which record the hashes of the inputs) and without LocalGenerator.
+	Force bool
+	// Whether to use the language repo even if it has uncommitted changes. Incompatible
+	// with CommitPerApi.
+	AllowDirty bool
+	// Whether to disable input hashing altogether, so that every library is generated and
+	// no hashes are recorded.
+	NoInputHash bool
+	// If specified, only the API paths which have changed in the API root (which must be a
+	// git repo) between this ref and HEAD are generated.
+	Since string
+	// Whether to fail rather than fall back to raw generation for an API path which isn't
+	// configured in the language repo.
+	RequireRefined bool
+	// Whether to compute the changes generation would make to the language repo (as a
+	// unified diff, or a summary with DiffStat) instead of building.
+	Diff     bool
+	DiffStat bool
+	// Whether to only log what would be generated.
+	DryRun bool
+	// The maximum number of API paths to generate in parallel. Defaults to 1.
+	MaxConcurrency int
+	// Whether to stop starting API paths after the first failure, returning its error.
+	FailFast bool
+	// The maximum time to spend generating (and building) each API path, if positive.
+	Timeout time.Duration
+	// The maximum total size in bytes of the code generated for each API path, if positive.
+	// The generator is killed if its output exceeds this while it's running.
+	MaxOutputSize int64
+}
+
+// GenerateResult reports the outcome of Generate.
+type GenerateResult struct {
+	// The results for each API path, ordered by API path.
+	Apis []GenerateApiResult
+}
+
+// GenerateApiResult is the result of generating a single API path.
+type GenerateApiResult struct {
+	ApiPath   string
+	OutputDir string
+	// The ID of the library, if refined generation was used.
+	LibraryID   string
+	Description string
+	// The changes generation would make to the language repo, if Diff or DiffStat is specified.
+	Diff string
+	// Problems which didn't prevent generation, but may mean the output isn't as expected.
+	Warnings []string
+	// Whether generation was skipped, as the inputs were unchanged since the last generation.
+	Skipped  bool
+	Duration time.Duration
+	Err      error
+	// The hash of the generator inputs, recorded when the library's code is committed.
+	inputHash string
+	// Whether the library's regenerated code was committed to the language repo (with
+	// CommitPerApi), i.e. whether it changed.
+	committed bool
+}
+
+// Populates GenerateOptions from the generate command's flags; this is the only place the
+// flags are read for generation. The work root, language repo and image are already part of
+// the command state, so they're not included, and the output directory is left to the
+// caller. The container settings are included for completeness, but generate uses the
+// command state's container config, to which RunCommand has already applied them.
+func generateOptionsFromFlags() GenerateOptions {
+	return GenerateOptions{
+		ApiPaths:              parseAPIPaths(flagAPIPath),
+		ApiRoot:               flagAPIRoot,
+		ApiIncludeRoots:       flagAPIIncludeRoots,
+		GeneratorInputDir:     flagGeneratorInputDir,
+		LocalGenerator:        flagLocalGenerator,
+		ValidationImage:       flagValidateImage,
+		Retries:               flagContainerRetries,
+		Environment:           flagContainerEnv,
+		RegistryConfig:        flagRegistryConfig,
+		Pull:                  flagPull,
+		ImageDigest:           flagImageDigest,
+		Since:                 flagSince,
+		Build:                 flagBuild,
+		CommitPerApi:          flagCommitPerApi,
+		CommitMessageTemplate: flagCommitMessageTemplate,
+		GitUserName:           flagGitUserName,
+		GitUserEmail:          flagGitUserEmail,
+		LineEndings:           flagLineEndings,
+		Prune:                 flagPrune,
+		EmitMetadata:          flagEmitMetadata,
+		BaseBranch:            flagBaseBranch,
+		PostGenerateHook:      flagPostGenerateHook,
+		Force:                 flagForce,
+		AllowDirty:            flagAllowDirty,
+		NoInputHash:           flagStreamOutput,
+		RequireRefined:        flagRequireRefined,
+		Diff:                  flagDiff,
+		DiffStat:              flagDiffStat,
+		DryRun:                flagDryRun,
+		MaxConcurrency:        flagMaxConcurrency,
+		FailFast:              flagFailFast,
+		Timeout:               flagGenerateTimeout,
+		MaxOutputSize:         int64(flagMaxOutputSize),
+	}
+}
+
+// Generate generates code for the API paths specified in the options, without the
+// command-line concerns of the generate command (such as pushing to a mirror repo or
+// writing a summary file). A failure for an individual API path is reported in its
+// result rather than as an error, unless FailFast is specified.
+func Generate(ctx context.Context, opts GenerateOptions) (*GenerateResult, error) {
+	if opts.WorkRoot == "" {
+		return nil, errors.New("a work root is required")
+	}
+	if opts.Image == "" && opts.Language == "" {
+		return nil, errors.New("an image or language is required")
+	}
+	var languageRepo *gitrepo.Repo
+	var pipelineState *statepb.PipelineState
+	var pipelineConfig *statepb.PipelineConfig
+	if opts.RepoRoot != "" {
+		repoRoot, err := filepath.Abs(opts.RepoRoot)
+		if err != nil {
+			return nil, err
+		}
+		if languageRepo, err = gitrepo.Open(repoRoot); err != nil {
+			return nil, err
+		}
+		clean, err := gitrepo.IsClean(languageRepo)
+		if err != nil {
+			return nil, err
+		}
+		if !clean && !opts.AllowDirty {
+			return nil, errors.New("language repo must be clean")
+		}
+		if pipelineState, pipelineConfig, err = loadStateAndConfig(generatorInputDirNamed(languageRepo.Dir, opts.GeneratorInputDir)); err != nil {
+			return nil, err
+		}
+	}
+	secretProvider := opts.SecretProvider
+	if secretProvider == nil {
+		var err error
+		if secretProvider, err = secrets.NewProvider(ctx, secrets.ProviderGcp, secrets.ProviderOptions{Project: opts.SecretsProject}); err != nil {
+			return nil, err
+		}
+	}
+	containerConfig, err := container.NewContainerConfig(ctx, opts.WorkRoot, imageFor(opts.Image, opts.Language, pipelineState), secretProvider, pipelineConfig)
+	if err != nil {
+		return nil, err
+	}
+	runtime := opts.ContainerRuntime
+	if runtime == "" {
+		runtime = container.RuntimeDocker
+	}
+	if containerConfig.Runtime, err = container.NewRuntime(runtime); err != nil {
+		return nil, err
+	}
+	containerConfig.LocalGenerator = opts.LocalGenerator
+	containerConfig.ValidationImage = opts.ValidationImage
+	containerConfig.Retries = opts.Retries
+	containerConfig.Environment = opts.Environment
+	if opts.RegistryConfig != "" {
+		if containerConfig.RegistryConfig, err = filepath.Abs(opts.RegistryConfig); err != nil {
+			return nil, err
+		}
+	}
+	if opts.OutputDir == "" {
+		opts.OutputDir = filepath.Join(opts.WorkRoot, "output")
+	}
+	state := &commandState{
+		ctx:             ctx,
+		startTime:       time.Now(),
+		workRoot:        opts.WorkRoot,
+		languageRepo:    languageRepo,
+		pipelineConfig:  pipelineConfig,
+		pipelineState:   pipelineState,
+		containerConfig: containerConfig,
+		gitUserName:     opts.GitUserName,
+		gitUserEmail:    opts.GitUserEmail,
+	}
+	if err := maybePullImage(state, &opts); err != nil {
+		return nil, err
+	}
+	return generate(state, opts)
+}
+
+// Generates each of the API paths in the options, within the environment described by the
+// command state.
+func generate(state *commandState, opts GenerateOptions) (*GenerateResult, error) {
+	if len(opts.ApiPaths) == 0 {
+		return nil, errors.New("at least one API path is required")
+	}
+	if opts.ApiRoot == "" {
+		return nil, errors.New("an API root is required")
+	}
+	normalizedApiPaths := []string{}
+	for _, apiPath := range opts.ApiPaths {
+		normalizedApiPaths = append(normalizedApiPaths, normalizeAPIPath(apiPath))
+	}
+	opts.ApiPaths = normalizedApiPaths
+	if err := validateLineEndings(opts.LineEndings); err != nil {
+		return nil, err
+	}
+	if opts.CommitPerApi && !opts.Build {
+		return nil, errors.New("-commit-per-api requires -build, as generated code is only copied into the language repo when building")
+	}
+	if opts.CommitPerApi && state.languageRepo != nil {
+		// Otherwise existing changes would be included in the first library's commit.
+		clean, err := gitrepo.IsClean(state.languageRepo)
+		if err != nil {
+			return nil, err
+		}
+		if !clean {
+			return nil, errors.New("-commit-per-api requires the language repo to be clean")
+		}
+	}
+	if err := validateAPIPathsExist(opts.ApiRoot, opts.ApiPaths); err != nil {
+		return nil, err
+	}
+	includeRoots, err := resolveAPIIncludeRoots(opts.ApiIncludeRoots)
+	if err != nil {
+		return nil, err
+	}
+	opts.ApiIncludeRoots = includeRoots
+	if opts.Since != "" {
+		changed, err := filterAPIPathsChangedSince(opts.ApiRoot, opts.Since, opts.ApiPaths)
+		if err != nil {
+			return nil, err
+		}
+		if len(changed) == 0 {
+			slog.Info(fmt.Sprintf("None of the %d API paths have changed since %s; nothing to generate", len(opts.ApiPaths), opts.Since))
+			return &GenerateResult{Apis: []GenerateApiResult{}}, nil
+		}
+		opts.ApiPaths = changed
+	}
+	outputDir := opts.OutputDir
+	if !opts.DryRun {
+		if err := os.MkdirAll(outputDir, 0755); err != nil {
+			return nil, err
+		}
+	}
 	slog.Info(fmt.Sprintf("Code will be generated in %s", outputDir))
 
-	libraryID, err := runGenerateCommand(state, outputDir)
+	// When generating multiple APIs, each is generated into its own subdirectory of outputDir,
+	// and a failure for one API doesn't prevent the others from being generated. Up to
+	// MaxConcurrency APIs are generated in parallel. With FailFast, no more APIs are
+	// started after one fails.
+	apiPaths := opts.ApiPaths
+	results := make([]GenerateApiResult, len(apiPaths))
+	_, generatePhase := tracing.StartPhase(state.ctx, "generate")
+	indexes := make(chan int)
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+	for range min(max(opts.MaxConcurrency, 1), len(apiPaths)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Each worker only writes to the results for the indexes it receives.
+			for i := range indexes {
+				apiPath := apiPaths[i]
+				apiOutputDir := outputDir
+				if len(apiPaths) > 1 {
+					apiOutputDir = filepath.Join(outputDir, strings.ReplaceAll(apiPath, "/", "-"))
+				}
+				results[i] = GenerateApiResult{ApiPath: apiPath, OutputDir: apiOutputDir}
+				if len(apiPaths) > 1 && !opts.DryRun {
+					if err := os.MkdirAll(apiOutputDir, 0755); err != nil {
+						results[i].Err = err
+						continue
+					}
+				}
+				start := time.Now()
+				generateAPIPath(state, &opts, &results[i])
+				results[i].Duration = time.Since(start)
+				if results[i].Err != nil {
+					failed.Store(true)
+				}
+			}
+		}()
+	}
+	started := 0
+	for ; started < len(apiPaths) && !(opts.FailFast && failed.Load()) && state.ctx.Err() == nil; started++ {
+		indexes <- started
+	}
+	close(indexes)
+	wg.Wait()
+	generatePhase.End(nil)
+	// The results for API paths which weren't started are incomplete, so aren't reported.
+	if err := cancelledError(state.ctx); err != nil {
+		return nil, err
+	}
+	if opts.FailFast && failed.Load() {
+		// Report the first failure in the order the API paths were specified.
+		for _, result := range results[:started] {
+			if result.Err != nil {
+				return nil, fmt.Errorf("stopping at first failure (-fail-fast): error while generating %s: %w", result.ApiPath, result.Err)
+			}
+		}
+	}
+
+	// Report results in a consistent order, regardless of the order in which they completed.
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].ApiPath < results[j].ApiPath
+	})
+	return &GenerateResult{Apis: results}, nil
+}
+
+// Guards the language repo while a library is cleaned, copied and built within it, as
+// this can't be done for multiple libraries concurrently.
+var languageRepoMutex sync.Mutex
+
+// Pulls the image (if Pull or ImageDigest is specified) and logs its digest. If ImageDigest
+// is specified, this returns an error unless the image has that digest, and otherwise the
+// image is subsequently run by digest, so that a tag which is moved after verification
+// can't change the image which is run.
+func maybePullImage(state *commandState, opts *GenerateOptions) error {
+	if !opts.Pull && opts.ImageDigest == "" {
+		return nil
+	}
+	if opts.DryRun {
+		slog.Info(fmt.Sprintf("Dry run: would pull image %s", state.containerConfig.Image))
+		return nil
+	}
+	digests, err := container.PullImage(state.ctx, state.containerConfig)
+	if err != nil {
+		return err
+	}
+	slog.Info(fmt.Sprintf("Image %s has digest %s", state.containerConfig.Image, strings.Join(digests, ", ")))
+	state.imageDigest = strings.Join(digests, ",")
+	if opts.ImageDigest == "" {
+		return nil
+	}
+	expected := opts.ImageDigest
+	if !strings.HasPrefix(expected, "sha256:") {
+		expected = "sha256:" + expected
+	}
+	if !slices.Contains(digests, expected) {
+		return fmt.Errorf("image %s has digest %s, but -image-digest specified %s", state.containerConfig.Image, strings.Join(digests, ", "), expected)
+	}
+	state.containerConfig.Image = imageWithDigest(state.containerConfig.Image, expected)
+	state.imageDigest = expected
+	slog.Info(fmt.Sprintf("Using verified image %s", state.containerConfig.Image))
+	return nil
+}
+
+// Returns the reference to the given image by digest, replacing any tag or digest.
+func imageWithDigest(image, digest string) string {
+	repository, _, _ := strings.Cut(image, "@")
+	// A colon after the last slash separates the tag, rather than a registry port.
+	if i := strings.LastIndex(repository, ":"); i > strings.LastIndex(repository, "/") {
+		repository = repository[:i]
+	}
+	return repository + "@" + digest
+}
+
+// Checks that each API path is a directory under the API root, so that a typo is reported
+// clearly rather than as a generator failure. All missing paths are reported together.
+func validateAPIPathsExist(apiRoot string, apiPaths []string) error {
+	absoluteRoot, err := filepath.Abs(apiRoot)
 	if err != nil {
 		return err
 	}
-	if flagBuild {
+	var errs []error
+	for _, apiPath := range apiPaths {
+		info, err := os.Stat(filepath.Join(absoluteRoot, apiPath))
+		if err != nil || !info.IsDir() {
+			errs = append(errs, fmt.Errorf("api path %s not found under api root %s", apiPath, absoluteRoot))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Returns the API paths which have changed in the API root between the given ref and HEAD,
+// logging those which are skipped.
+func filterAPIPathsChangedSince(apiRoot, since string, apiPaths []string) ([]string, error) {
+	apiRepo, err := gitrepo.Open(apiRoot)
+	if err != nil {
+		return nil, fmt.Errorf("-since requires the API root %s to be a git repo: %w", apiRoot, err)
+	}
+	changed, err := gitrepo.GetPathsChangedSinceRef(apiRepo, since, apiPaths)
+	if err != nil {
+		return nil, err
+	}
+	for _, apiPath := range apiPaths {
+		if !slices.Contains(changed, apiPath) {
+			slog.Info(fmt.Sprintf("Skipping %s, as it hasn't changed since %s", apiPath, since))
+		}
+	}
+	return changed, nil
+}
+
+// Logs a warning if the given directory exists and is non-empty, listing the existing
+// entries (which may be overwritten by generation).
+func warnIfNotEmpty(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) == 0 {
+		return
+	}
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	slog.Warn(fmt.Sprintf("Output directory %s is not empty; these entries may be overwritten: %s", dir, strings.Join(names, ", ")))
+}
+
+// Splits the value of the -api-path flag (which may be a comma-separated list) into
+// individual API paths, each normalized (see normalizeAPIPath).
+func parseAPIPaths(value string) []string {
+	apiPaths := []string{}
+	for _, apiPath := range strings.Split(value, ",") {
+		if apiPath = normalizeAPIPath(apiPath); apiPath != "" {
+			apiPaths = append(apiPaths, apiPath)
+		}
+	}
+	return apiPaths
+}
+
+// Generates (and optionally validates and builds) a single API into the result's output
+// directory, populating the result with the library ID (for refined generation), a
+// description of the change suitable for a commit message, and any error. Generation,
+// validation, cleaning and building are all subject to -generate-timeout, if specified.
+func generateAPIPath(state *commandState, opts *GenerateOptions, result *GenerateApiResult) {
+	ctx := state.ctx
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	description, err := generateAndBuildAPIPath(ctx, state, opts, result)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("generating %s timed out after %s: %w", result.ApiPath, opts.Timeout, err)
+	}
+	result.Description = description
+	result.Err = err
+}
+
+func generateAndBuildAPIPath(ctx context.Context, state *commandState, opts *GenerateOptions, result *GenerateApiResult) (string, error) {
+	apiPath, outputDir := result.ApiPath, result.OutputDir
+	preexistingOutput, err := listDirEntryNames(outputDir)
+	if err != nil {
+		return "", err
+	}
+	generateCtx, stopWatchingOutputSize := ctx, func() error { return nil }
+	if opts.MaxOutputSize > 0 {
+		generateCtx, stopWatchingOutputSize = watchOutputSize(ctx, outputDir, apiPath, opts.MaxOutputSize)
+	}
+	libraryID, err := runGenerateCommand(generateCtx, state, opts, result)
+	if sizeErr := stopWatchingOutputSize(); sizeErr != nil {
+		err = sizeErr
+	}
+	result.LibraryID = libraryID
+	if err != nil {
+		// Never clean, copy or build from partial output: discard it, so that it can't be
+		// mistaken for the result of a successful generation.
+		if discardErr := discardPartialOutput(outputDir, preexistingOutput); discardErr != nil {
+			return "", errors.Join(err, discardErr)
+		}
+		return "", err
+	}
+	if opts.DryRun {
+		slog.Info(fmt.Sprintf("Dry run: build would be run: %t", opts.Build))
+		return "", nil
+	}
+	if result.Skipped {
+		return "", nil
+	}
+	generatedID := libraryID
+	if generatedID == "" {
+		generatedID = apiPath
+	}
+	if err := checkGeneratorOutput(outputDir, generatedID); err != nil {
+		return "", err
+	}
+	if err := checkOutputSize(outputDir, generatedID, opts.MaxOutputSize); err != nil {
+		return "", err
+	}
+	// Written after checking the output, so that it can't hide a generator producing nothing.
+	if opts.EmitMetadata && libraryID != "" {
+		if err := emitLibraryMetadata(state, opts.ApiRoot, outputDir, opts.BaseBranch, findLibraryByID(state.pipelineState, libraryID)); err != nil {
+			return "", err
+		}
+	}
+	if err := maybeRunPostGenerateHook(ctx, opts.PostGenerateHook, outputDir, generatedID); err != nil {
+		return "", err
+	}
+	if opts.Diff || opts.DiffStat {
+		if libraryID == "" {
+			return "", fmt.Errorf("cannot diff %s: it isn't configured in a language repo", apiPath)
+		}
+		if result.Diff, err = formatGenerationDiff(state.languageRepo.Dir, outputDir, findLibraryByID(state.pipelineState, libraryID), opts.LineEndings, opts.DiffStat); err != nil {
+			return "", err
+		}
+	}
+	if state.containerConfig.ValidationImage != "" {
+		if err := container.Validate(ctx, state.containerConfig, outputDir, libraryID); err != nil {
+			return "", err
+		}
+	}
+	if opts.Build {
 		if libraryID != "" {
 			slog.Info("Build requested in the context of refined generation; cleaning and copying code to the local language repo before building.")
-			if err := container.Clean(state.containerConfig, state.languageRepo.Dir, libraryID); err != nil {
-				return err
+			languageRepoMutex.Lock()
+			defer languageRepoMutex.Unlock()
+			if err := cleanLibrary(ctx, state, state.languageRepo.Dir, libraryID); err != nil {
+				return "", err
 			}
-			if err := os.CopyFS(state.languageRepo.Dir, os.DirFS(outputDir)); err != nil {
-				return err
+			if err := copyGeneratedCode(state.languageRepo.Dir, outputDir, findLibraryByID(state.pipelineState, libraryID), opts.LineEndings); err != nil {
+				return "", err
 			}
-			if err := container.BuildLibrary(state.containerConfig, state.languageRepo.Dir, libraryID); err != nil {
-				return err
+			if opts.Prune {
+				if err := pruneGeneratedCode(state.languageRepo.Dir, outputDir, findLibraryByID(state.pipelineState, libraryID)); err != nil {
+					return "", err
+				}
 			}
-		} else if err := container.BuildRaw(state.containerConfig, outputDir, flagAPIPath); err != nil {
-			return err
+			committed := false
+			if opts.CommitPerApi {
+				var err error
+				if committed, err = commitLibrary(state, opts, libraryID, result.inputHash); err != nil {
+					return "", err
+				}
+			}
+			if err := container.BuildLibrary(ctx, state.containerConfig, state.languageRepo.Dir, libraryID); err != nil {
+				if committed {
+					// Each commit must correspond to a successful library, so undo this one.
+					if revertErr := gitrepo.CleanAndRevertHeadCommit(state.languageRepo); revertErr != nil {
+						return "", errors.Join(err, revertErr)
+					}
+				}
+				return "", err
+			}
+			result.committed = committed
+		} else if err := container.BuildRaw(ctx, state.containerConfig, outputDir, apiPath); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("feat: Regenerate %s", generatedID), nil
+}
+
+// Commits the library's newly-copied code to the language repo (for -commit-per-api),
+// reporting whether a commit was made: there's nothing to commit if the code is unchanged.
+// The input hash (if any) is recorded in the same commit, so that later runs only skip the
+// library once its code has been committed; it's not recorded if the code is unchanged, to
+// avoid commits which only change the hash.
+func commitLibrary(state *commandState, opts *GenerateOptions, libraryID, inputHash string) (bool, error) {
+	before, err := gitrepo.HeadHash(state.languageRepo)
+	if err != nil {
+		return false, err
+	}
+	clean, err := gitrepo.IsClean(state.languageRepo)
+	if err != nil {
+		return false, err
+	}
+	if clean {
+		slog.Info(fmt.Sprintf("No changes to commit for library %s", libraryID))
+		return false, nil
+	}
+	if inputHash != "" {
+		if err := recordInputHash(generatorInputDirNamed(state.languageRepo.Dir, opts.GeneratorInputDir), libraryID, inputHash); err != nil {
+			return false, err
 		}
 	}
+	template := opts.CommitMessageTemplate
+	if template == "" {
+		template = defaultGenerateCommitMessageTemplate
+	}
+	apiCommit := ""
+	if strings.Contains(template, "{apiCommit}") {
+		apiCommit = apiRootCommit(opts.ApiRoot)
+	}
+	message := formatCommitTitle(state, template, findLibraryByID(state.pipelineState, libraryID), apiCommit)
+	if err := commitAll(state, state.languageRepo, message); err != nil {
+		return false, err
+	}
+	after, err := gitrepo.HeadHash(state.languageRepo)
+	if err != nil {
+		return false, err
+	}
+	return before != after, nil
+}
+
+// Returns the abbreviated hash of the HEAD commit of the API root, or "unknown" if it isn't
+// a git repo.
+func apiRootCommit(apiRoot string) string {
+	apiRepo, err := gitrepo.Open(apiRoot)
+	if err != nil {
+		return "unknown"
+	}
+	hash, err := gitrepo.HeadHash(apiRepo)
+	if err != nil {
+		return "unknown"
+	}
+	return hash[:7]
+}
+
+// Checks that the generator wrote something to outputDir, so that a generator
+// which silently produces nothing is reported as such, rather than as a
+// confusing failure when building. The ID is the library ID or API path.
+func checkGeneratorOutput(outputDir, id string) error {
+	entries, err := os.ReadDir(outputDir)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("generator produced no output for %s", id)
+	}
+	return nil
+}
+
+// Returns the names of the entries in dir, or nil if it doesn't exist.
+func listDirEntryNames(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names, nil
+}
+
+// Removes everything a failed generator wrote to outputDir, i.e. every entry other than
+// those which existed beforehand (as outputDir may be specified with -output).
+func discardPartialOutput(outputDir string, preexisting []string) error {
+	names, err := listDirEntryNames(outputDir)
+	if err != nil {
+		return err
+	}
+	discarded := 0
+	for _, name := range names {
+		if slices.Contains(preexisting, name) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(outputDir, name)); err != nil {
+			return fmt.Errorf("failed to discard partial generator output: %w", err)
+		}
+		discarded++
+	}
+	if discarded > 0 {
+		slog.Info(fmt.Sprintf("Discarded %d entries of partial generator output from %s", discarded, outputDir))
+	}
 	return nil
 }
 
+// Writes the contents of outputDir to w as a tar stream.
+func streamOutput(outputDir string, w io.Writer) error {
+	slog.Info("Streaming generated code to stdout")
+	tw := tar.NewWriter(w)
+	if err := tw.AddFS(os.DirFS(outputDir)); err != nil {
+		return fmt.Errorf("failed to stream generated code: %w", err)
+	}
+	return tw.Close()
+}
+
 // Checks if the library exists in the remote pipeline state, if so use GenerateLibrary command
 // otherwise use GenerateRaw command.
 // In case of non fatal error when looking up library, we will fallback to GenerateRaw command
 // and log the error.
-// If refined generation is used, the context's languageRepo field will be populated and the
-// library ID will be returned; otherwise, an empty string will be returned.
-func runGenerateCommand(state *commandState, outputDir string) (string, error) {
-	apiRoot, err := filepath.Abs(flagAPIRoot)
+// If refined generation is used, the library ID will be returned (even if generation fails);
+// otherwise, an empty string will be returned. Falling back to raw generation is reported as a
+// warning in the result, or as an error with -require-refined.
+func runGenerateCommand(ctx context.Context, state *commandState, opts *GenerateOptions, result *GenerateApiResult) (string, error) {
+	apiPath, outputDir := result.ApiPath, result.OutputDir
+	apiRoot, err := filepath.Abs(opts.ApiRoot)
 	if err != nil {
 		return "", err
 	}
 
-	// If we've got a language repo, it's because we've already found a library for the
-	// specified API, configured in the repo.
-	if state.languageRepo != nil {
-		libraryID := findLibraryIDByApiPath(state.pipelineState, flagAPIPath)
-		if libraryID == "" {
-			return "", errors.New("bug in Librarian: Library not found during generation, despite being found in earlier steps")
+	libraryID := ""
+	if state.pipelineState != nil {
+		if libraryID, err = findLibraryIDByApiPath(state.pipelineState, apiPath); err != nil {
+			return "", err
+		}
+	}
+	if libraryID == "" {
+		reason := "no language repo was specified"
+		if state.pipelineState != nil {
+			reason = "it isn't configured in any library in the language repo"
+		}
+		if opts.RequireRefined {
+			return "", fmt.Errorf("refusing to fall back to raw generation for %s (-require-refined): %s", apiPath, reason)
+		}
+		warning := fmt.Sprintf("Falling back to raw generation for %s: %s", apiPath, reason)
+		slog.Warn(warning)
+		result.Warnings = append(result.Warnings, warning)
+	}
+
+	// In a dry run, the language repo is never opened, but the pipeline state is still loaded.
+	if opts.DryRun {
+		if libraryID != "" {
+			slog.Info(fmt.Sprintf("Dry run: would perform refined generation for library %s with API root %s into %s", libraryID, apiRoot, outputDir))
+		} else {
+			slog.Info(fmt.Sprintf("Dry run: would perform raw generation for %s with API root %s into %s", apiPath, apiRoot, outputDir))
+		}
+		return libraryID, nil
+	}
+
+	// If we've got a language repo, it's because we've already found a library for at least
+	// one of the specified APIs, configured in the repo.
+	if state.languageRepo != nil && libraryID != "" {
+		library := findLibraryByID(state.pipelineState, libraryID)
+		generatorInput := generatorInputDirNamed(state.languageRepo.Dir, opts.GeneratorInputDir)
+		// Hashes are only recorded when committing each library, so that's the only case in
+		// which generation can be skipped; otherwise the output is always needed. A local
+		// generator isn't covered by the hash, so it's always run.
+		if opts.Build && opts.CommitPerApi && !opts.NoInputHash && state.containerConfig.LocalGenerator == "" {
+			inputHash, err := computeInputHash(ctx, state, apiRoot, generatorInput, library)
+			if err != nil {
+				slog.Warn(fmt.Sprintf("Unable to compute input hash for %s, so generating regardless: %s", libraryID, err))
+			} else if !opts.Force && inputHash == readRecordedInputHash(generatorInput, libraryID) {
+				slog.Info(fmt.Sprintf("Skipping generation for library %s, as its inputs are unchanged since it was last generated (use -force to regenerate)", libraryID))
+				result.Skipped = true
+				return libraryID, nil
+			}
+			result.inputHash = inputHash
 		}
-		generatorInput := filepath.Join(state.languageRepo.Dir, "generator-input")
 		slog.Info(fmt.Sprintf("Performing refined generation for library %s", libraryID))
-		return libraryID, container.GenerateLibrary(state.containerConfig, apiRoot, outputDir, generatorInput, libraryID)
+		if err := container.GenerateLibrary(ctx, state.containerConfig, apiRoot, opts.ApiIncludeRoots, outputDir, generatorInput, libraryID); err != nil {
+			return libraryID, err
+		}
+		return libraryID, maybePostProcess(state, generatorInput, outputDir, libraryID)
 	} else {
-		slog.Info(fmt.Sprintf("No matching library found (or no repo specified); performing raw generation for %s", flagAPIPath))
-		return "", container.GenerateRaw(state.containerConfig, apiRoot, outputDir, flagAPIPath)
+		slog.Info(fmt.Sprintf("Performing raw generation for %s", apiPath))
+		return "", container.GenerateRaw(ctx, state.containerConfig, apiRoot, opts.ApiIncludeRoots, outputDir, apiPath)
 	}
 }
 
-// Checks if the library with the given API path exists in the repo specified either
-// by a URL or a local path, and opens or clones it if so.
-func openOrCloneLanguageRepoIfLibraryExists(workRoot string) (*gitrepo.Repo, error) {
-	if flagRepoUrl == "" && flagRepoRoot == "" {
-		slog.Warn("repo url and root are not specified, cannot check if library exists")
-		return nil, nil
-	}
+// Checks if a library with any of the specified API paths exists in the repo specified either
+// by a URL or a local path, and opens or clones it if so. In a dry run, the repo is
+// never opened or cloned. This runs before the command is executed, so it reads the flags
+// itself (see openOrCloneLanguageRepoIfConfigured).
+func openOrCloneLanguageRepoIfLibraryExists(ctx context.Context, workRoot string) (*gitrepo.Repo, error) {
+	opts := generateOptionsFromFlags()
+	return openOrCloneLanguageRepoIfConfigured(ctx, workRoot, languageRepoOptionsFromFlags(), opts.ApiPaths, opts.DryRun)
+}
 
-	if flagRepoRoot != "" && flagRepoUrl != "" {
-		return nil, errors.New("do not specify both repo-root and repo-url")
+// As openOrCloneLanguageRepoIfLibraryExists, with the repo and API paths specified.
+func openOrCloneLanguageRepoIfConfigured(ctx context.Context, workRoot string, repoOpts languageRepoOptions, apiPaths []string, dryRun bool) (*gitrepo.Repo, error) {
+	pipelineState, err := loadConfiguredPipelineState(ctx, repoOpts)
+	if err != nil {
+		return nil, err
+	}
+	if pipelineState == nil {
+		return nil, nil
 	}
 
-	// Attempt to load the pipeline state either locally or from the repo URL
-	var pipelineState *statepb.PipelineState
-	var err error
-	if flagRepoRoot != "" {
-		pipelineState, err = loadPipelineStateFile(filepath.Join(flagRepoRoot, "generator-input", pipelineStateFile))
-	} else {
-		var languageRepoMetadata githubrepo.GitHubRepo
-		languageRepoMetadata, err = githubrepo.ParseUrl(flagRepoUrl)
+	anyConfigured := false
+	for _, apiPath := range apiPaths {
+		libraryID, err := findLibraryIDByApiPath(pipelineState, apiPath)
 		if err != nil {
-			slog.Warn("failed to parse", "repo url:", flagRepoUrl, "error", err)
 			return nil, err
 		}
-		pipelineState, err = fetchRemotePipelineState(context.Background(), languageRepoMetadata, "HEAD")
+		if libraryID == "" {
+			slog.Info(fmt.Sprintf("API path %s not configured in repo", apiPath))
+		} else {
+			slog.Info(fmt.Sprintf("API path %s configured in repo library %s", apiPath, libraryID))
+			anyConfigured = true
+		}
+	}
+	// If no library exists, we don't use the repo at all.
+	if !anyConfigured {
+		return nil, nil
 	}
+	if dryRun {
+		slog.Info("Dry run: not opening or cloning the language repo")
+		return nil, nil
+	}
+	// Otherwise (if a library *does* exist), clone or open it as normal.
+	return cloneOrOpenLanguageRepo(ctx, workRoot)
+}
 
-	if err != nil {
-		return nil, err
+// Specifies the language repo from which loadConfiguredPipelineState loads the pipeline
+// state, populated from flags by languageRepoOptionsFromFlags.
+type languageRepoOptions struct {
+	// The local repo (see -repo-root) or the URL of a remote one (see -repo-url). At most
+	// one may be specified.
+	repoRoot string
+	repoURL  string
+	// The ref of a remote repo from which the state is loaded (see -repo-ref).
+	repoRef string
+	// The branch used instead of HEAD for a remote repo, if any (see -base-branch).
+	baseBranch string
+	// How the state of a remote repo is cached.
+	stateCache stateCacheOptions
+}
+
+func languageRepoOptionsFromFlags() languageRepoOptions {
+	return languageRepoOptions{
+		repoRoot:   flagRepoRoot,
+		repoURL:    flagRepoUrl,
+		repoRef:    flagRepoRef,
+		baseBranch: flagBaseBranch,
+		stateCache: stateCacheOptionsFromFlags(),
 	}
+}
 
-	// If the library doesn't exist, we don't use the repo at all.
-	libraryID := findLibraryIDByApiPath(pipelineState, flagAPIPath)
-	if libraryID == "" {
-		slog.Info(fmt.Sprintf("API path %s not configured in repo", flagAPIPath))
+// Loads the pipeline state from the repo specified either by a URL or a local path,
+// without cloning the repo. If no repo is specified, nil is returned.
+func loadConfiguredPipelineState(ctx context.Context, opts languageRepoOptions) (*statepb.PipelineState, error) {
+	if opts.repoURL == "" && opts.repoRoot == "" {
+		slog.Warn("repo url and root are not specified, cannot check if library exists")
 		return nil, nil
 	}
 
-	slog.Info(fmt.Sprintf("API path %s configured in repo library %s", flagAPIPath, libraryID))
-	// Otherwise (if the library *does* exist), clone or open it as normal.
-	return cloneOrOpenLanguageRepo(workRoot)
+	if opts.repoRoot != "" && opts.repoURL != "" {
+		return nil, errors.New("do not specify both repo-root and repo-url")
+	}
+
+	// Attempt to load the pipeline state either locally or from the repo URL
+	if opts.repoRoot != "" {
+		return loadPipelineStateFile(findPipelineStateFile(generatorInputDir(opts.repoRoot)))
+	}
+	languageRepoMetadata, err := githubrepo.ParseUrl(opts.repoURL)
+	if err != nil {
+		slog.Warn("failed to parse", "repo url:", opts.repoURL, "error", err)
+		return nil, err
+	}
+	// The repo is cloned on the base branch unless -repo-ref specifies otherwise, so the
+	// state is loaded from the same branch.
+	ref := opts.repoRef
+	if ref == "HEAD" && opts.baseBranch != "" {
+		ref = opts.baseBranch
+	}
+	return fetchCachedRemotePipelineState(ctx, opts.stateCache, languageRepoMetadata, ref)
 }
@@ -0,0 +1,251 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/googleapis/librarian/internal/container"
+	"github.com/googleapis/librarian/internal/gitrepo"
+	"github.com/googleapis/librarian/internal/statepb"
+)
+
+// A local generator which writes some output and then fails, as a generator which crashes
+// part way through would.
+const failingGeneratorScript = `#!/bin/sh
+for arg in "$@"; do
+  case "$arg" in
+    --output=*) output="${arg#--output=}" ;;
+  esac
+done
+mkdir -p "$output/packages/foo"
+echo partial > "$output/packages/foo/partial.txt"
+exit 1
+`
+
+// Returns the paths of all the files and directories within dir, relative to dir.
+func listTree(t *testing.T, dir string) []string {
+	t.Helper()
+	paths := []string{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, relative)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return paths
+}
+
+func TestGenerateErrorLeavesRepoUntouched(t *testing.T) {
+	workRoot := t.TempDir()
+	repoDir := t.TempDir()
+	apiRoot := t.TempDir()
+	outputDir := filepath.Join(workRoot, "output")
+	for _, dir := range []string{outputDir, filepath.Join(apiRoot, "google/foo/v1"), generatorInputDir(repoDir), filepath.Join(repoDir, "packages/foo")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(repoDir, "packages/foo/existing.txt"), []byte("existing"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// The output directory may contain files from before generation (e.g. with -output), which are kept.
+	if err := os.WriteFile(filepath.Join(outputDir, "preexisting.txt"), []byte("preexisting"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	generator := filepath.Join(workRoot, "generator.sh")
+	if err := os.WriteFile(generator, []byte(failingGeneratorScript), 0755); err != nil {
+		t.Fatal(err)
+	}
+	repoBefore := listTree(t, repoDir)
+
+	ctx := context.Background()
+	containerConfig, err := container.NewContainerConfig(ctx, workRoot, "generator-image", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	containerConfig.LocalGenerator = generator
+	state := &commandState{
+		ctx:          ctx,
+		workRoot:     workRoot,
+		languageRepo: &gitrepo.Repo{Dir: repoDir},
+		pipelineState: &statepb.PipelineState{
+			Libraries: []*statepb.LibraryState{
+				{
+					Id:          "foo",
+					ApiPaths:    []string{"google/foo/v1"},
+					SourcePaths: []string{"packages/foo"},
+				},
+			},
+		},
+		containerConfig: containerConfig,
+	}
+	opts := &GenerateOptions{
+		ApiRoot:     apiRoot,
+		Build:       true,
+		NoInputHash: true,
+	}
+	result := &GenerateApiResult{ApiPath: "google/foo/v1", OutputDir: outputDir}
+
+	generateAPIPath(state, opts, result)
+
+	if result.Err == nil {
+		t.Fatal("generateAPIPath() succeeded; expected an error from the failing generator")
+	}
+	if result.LibraryID != "foo" {
+		t.Errorf("library ID is %q, want %q", result.LibraryID, "foo")
+	}
+	if repoAfter := listTree(t, repoDir); !slices.Equal(repoBefore, repoAfter) {
+		t.Errorf("repo was modified: contents were %v, now %v", repoBefore, repoAfter)
+	}
+	if outputAfter := listTree(t, outputDir); !slices.Equal(outputAfter, []string{".", "preexisting.txt"}) {
+		t.Errorf("partial output wasn't discarded: output directory contains %v", outputAfter)
+	}
+}
+
+// Generate is used without the generate command's flags, so it must work from the options alone.
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		name     string
+		apiPaths []string
+		hook     string
+		want     []string
+	}{
+		{
+			name:     "single API path",
+			apiPaths: []string{"google/foo/v1"},
+			want:     []string{"generated.txt"},
+		},
+		{
+			name:     "multiple API paths",
+			apiPaths: []string{"google/foo/v1", "google/bar/v1"},
+			want:     []string{"google-bar-v1/generated.txt", "google-foo-v1/generated.txt"},
+		},
+		{
+			name:     "post-generate hook",
+			apiPaths: []string{"google/foo/v1"},
+			hook:     `echo >"$1/hooked.txt"`,
+			want:     []string{"generated.txt", "hooked.txt"},
+		},
+	}
+	for _, test := range tests {
+		workRoot := t.TempDir()
+		apiRoot := t.TempDir()
+		for _, apiPath := range test.apiPaths {
+			if err := os.MkdirAll(filepath.Join(apiRoot, apiPath), 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		opts := GenerateOptions{
+			ApiPaths:         test.apiPaths,
+			ApiRoot:          apiRoot,
+			WorkRoot:         workRoot,
+			Image:            "generator-image",
+			LocalGenerator:   writeScript(t, filepath.Join(workRoot, "generator.sh"), markerGeneratorScript),
+			PostGenerateHook: test.hook,
+		}
+		result, err := Generate(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("Generate(%s) failed: %s", test.name, err)
+		}
+		if len(result.Apis) != len(test.apiPaths) {
+			t.Fatalf("Generate(%s) expected %d results, got %d", test.name, len(test.apiPaths), len(result.Apis))
+		}
+		for _, api := range result.Apis {
+			if api.Err != nil {
+				t.Errorf("Generate(%s) failed for %s: %s", test.name, api.ApiPath, api.Err)
+			}
+		}
+		outputDir := filepath.Join(workRoot, "output")
+		got := []string{}
+		for _, path := range listTree(t, outputDir) {
+			if info, err := os.Stat(filepath.Join(outputDir, path)); err == nil && !info.IsDir() {
+				got = append(got, filepath.ToSlash(path))
+			}
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("Generate(%s) expected output %v, got %v", test.name, test.want, got)
+		}
+	}
+}
+
+func TestLoadConfiguredPipelineState(t *testing.T) {
+	repoRoot := t.TempDir()
+	if err := os.MkdirAll(generatorInputDir(repoRoot), 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := `{"libraries": [{"id": "foo", "apiPaths": ["google/foo/v1"]}]}`
+	if err := os.WriteFile(filepath.Join(generatorInputDir(repoRoot), pipelineStateFile), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		opts    languageRepoOptions
+		want    []string
+		wantErr bool
+	}{
+		{name: "no repo"},
+		{name: "local repo", opts: languageRepoOptions{repoRoot: repoRoot}, want: []string{"foo"}},
+		{name: "local and remote repo", opts: languageRepoOptions{repoRoot: repoRoot, repoURL: "https://github.com/owner/repo"}, wantErr: true},
+	}
+	for _, test := range tests {
+		state, err := loadConfiguredPipelineState(context.Background(), test.opts)
+		if (err != nil) != test.wantErr {
+			t.Errorf("loadConfiguredPipelineState(%s) expected error %t, got %v", test.name, test.wantErr, err)
+			continue
+		}
+		got := []string{}
+		if state != nil {
+			for _, library := range state.Libraries {
+				got = append(got, library.Id)
+			}
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("loadConfiguredPipelineState(%s) expected libraries %v, got %v", test.name, test.want, got)
+		}
+	}
+}
+
+func TestImageWithDigest(t *testing.T) {
+	const digest = "sha256:abcd"
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{image: "gcr.io/project/generator:latest", want: "gcr.io/project/generator@" + digest},
+		{image: "gcr.io/project/generator", want: "gcr.io/project/generator@" + digest},
+		{image: "localhost:5000/generator:v1", want: "localhost:5000/generator@" + digest},
+		{image: "localhost:5000/generator", want: "localhost:5000/generator@" + digest},
+		{image: "generator:v1@sha256:1234", want: "generator@" + digest},
+	}
+	for _, test := range tests {
+		if got := imageWithDigest(test.image, digest); got != test.want {
+			t.Errorf("imageWithDigest(%s) expected %s, got %s", test.image, test.want, got)
+		}
+	}
+}
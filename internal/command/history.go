@@ -0,0 +1,76 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/googleapis/librarian/internal/utils"
+)
+
+// RunRecord is an entry in the run history, appended (as a single line of JSON) to the
+// file specified with -history-file. Along with the API commits recorded in each commit
+// message, its provenance fields allow the run's changes to be reproduced.
+type RunRecord struct {
+	// Command is the name of the command which was run.
+	Command string `json:"command"`
+	// StartTime is when the run started, in RFC 3339 format.
+	StartTime string `json:"startTime"`
+	// DurationSeconds is the time taken by the run.
+	DurationSeconds float64 `json:"durationSeconds"`
+	// Success indicates whether the run succeeded.
+	Success bool `json:"success"`
+	// Error describes why the run failed, if it did.
+	Error string `json:"error,omitempty"`
+	// BaseCommit is the HEAD commit of the language repo when the run started, if any.
+	BaseCommit string `json:"baseCommit,omitempty"`
+	// Image is the language container image.
+	Image string `json:"image,omitempty"`
+	// ImageDigest is the digest of the image, if known.
+	ImageDigest string `json:"imageDigest,omitempty"`
+	// PullRequests are the URLs of the PRs created or updated by the run.
+	PullRequests []string `json:"pullRequests,omitempty"`
+}
+
+// Appends a RunRecord for the run to the file specified with -history-file, if any. Failure
+// to do so is logged, but doesn't fail the command, which has already finished.
+func maybeAppendRunHistory(c *Command, state *commandState, runErr error) {
+	if flagHistoryFile == "" || state == nil {
+		return
+	}
+	record := RunRecord{
+		Command:         c.Name,
+		StartTime:       state.startTime.UTC().Format(time.RFC3339),
+		DurationSeconds: time.Since(state.startTime).Seconds(),
+		Success:         runErr == nil,
+		BaseCommit:      state.baseCommit,
+		Image:           state.containerConfig.Image,
+		ImageDigest:     state.imageDigest,
+		PullRequests:    state.pullRequestURLs,
+	}
+	if runErr != nil {
+		record.Error = runErr.Error()
+	}
+	data, err := json.Marshal(record)
+	if err == nil {
+		err = utils.AppendToFile(flagHistoryFile, string(data)+"\n")
+	}
+	if err != nil {
+		slog.Warn(fmt.Sprintf("Unable to append to run history %s: %s", flagHistoryFile, err))
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/googleapis/librarian/internal/container"
+)
+
+func TestMaybeAppendRunHistory(t *testing.T) {
+	defer func(original string) { flagHistoryFile = original }(flagHistoryFile)
+	flagHistoryFile = filepath.Join(t.TempDir(), "history.jsonl")
+	state := &commandState{
+		startTime:       time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		baseCommit:      "abc123",
+		imageDigest:     "sha256:1234",
+		containerConfig: &container.ContainerConfig{Image: "generator:latest"},
+		pullRequestURLs: []string{"https://github.com/owner/repo/pull/1"},
+	}
+	maybeAppendRunHistory(CmdGenerate, state, nil)
+	maybeAppendRunHistory(CmdGenerate, state, errors.New("failed"))
+
+	file, err := os.Open(flagHistoryFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	records := []RunRecord{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record RunRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 2 {
+		t.Fatalf("maybeAppendRunHistory() expected 2 records, got %d", len(records))
+	}
+	for i, want := range []RunRecord{{Success: true}, {Error: "failed"}} {
+		got := records[i]
+		if got.Command != "generate" || got.StartTime != "2025-01-02T03:04:05Z" || got.BaseCommit != "abc123" ||
+			got.Image != "generator:latest" || got.ImageDigest != "sha256:1234" || !slices.Equal(got.PullRequests, state.pullRequestURLs) {
+			t.Errorf("maybeAppendRunHistory() record %d has unexpected provenance: %+v", i, got)
+		}
+		if got.Success != want.Success || got.Error != want.Error {
+			t.Errorf("maybeAppendRunHistory() record %d expected success %t and error %q, got %t and %q", i, want.Success, want.Error, got.Success, got.Error)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/googleapis/librarian/internal/container"
+	"github.com/googleapis/librarian/internal/statepb"
+)
+
+// The file within the generator-input directory in which the input hash of each library
+// is recorded, as a JSON object mapping library IDs to hashes. It's committed along with
+// the library's regenerated code, so a hash is only recorded once that commit exists (and
+// is only seen by later runs once it's been merged, for a cloned language repo).
+const inputHashesFile = "input-hashes.json"
+
+// Computes a hash of everything which is expected to affect the code generated for a
+// library: the files under each of its API paths, the generator image, and the language
+// repo's generator-input directory (other than the recorded input hashes themselves).
+func computeInputHash(ctx context.Context, state *commandState, apiRoot, generatorInput string, library *statepb.LibraryState) (string, error) {
+	imageID, err := container.ImageID(ctx, state.containerConfig)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "library %s\x00image %s\x00", library.Id, imageID)
+	apiPaths := slices.Clone(library.ApiPaths)
+	slices.Sort(apiPaths)
+	for _, apiPath := range apiPaths {
+		if err := hashDir(h, "api/"+apiPath, filepath.Join(apiRoot, apiPath), ""); err != nil {
+			return "", err
+		}
+	}
+	if err := hashDir(h, "generator-input", generatorInput, inputHashesFile); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Writes the relative path and content of every file within dir (in a consistent order)
+// to h, with the given prefix for each path. The file with the relative path excluded
+// (if any) is skipped.
+func hashDir(h hash.Hash, prefix, dir, excluded string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		relative, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if relative == excluded {
+			return nil
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		fileHash := sha256.New()
+		if _, err := io.Copy(fileHash, file); err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s/%s\x00%x\x00", prefix, filepath.ToSlash(relative), fileHash.Sum(nil))
+		return nil
+	})
+}
+
+// Returns the input hashes recorded in the language repo's generator-input directory,
+// keyed by library ID.
+func loadInputHashes(generatorInput string) (map[string]string, error) {
+	hashes := map[string]string{}
+	data, err := os.ReadFile(filepath.Join(generatorInput, inputHashesFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return hashes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &hashes); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", inputHashesFile, err)
+	}
+	return hashes, nil
+}
+
+// Returns the input hash recorded for a library by the last committed generation,
+// or an empty string if there is none.
+func readRecordedInputHash(generatorInput, libraryID string) string {
+	hashes, err := loadInputHashes(generatorInput)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("Unable to read recorded input hash for %s: %s", libraryID, err))
+		return ""
+	}
+	return hashes[libraryID]
+}
+
+// Records the input hash for a library in the language repo's generator-input directory,
+// to be committed along with its regenerated code.
+func recordInputHash(generatorInput, libraryID, inputHash string) error {
+	hashes, err := loadInputHashes(generatorInput)
+	if err != nil {
+		return err
+	}
+	hashes[libraryID] = inputHash
+	data, err := json.MarshalIndent(hashes, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(generatorInput, inputHashesFile), append(data, '\n'), 0644)
+}
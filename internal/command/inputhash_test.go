@@ -0,0 +1,159 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/googleapis/librarian/internal/container"
+	"github.com/googleapis/librarian/internal/gitrepo"
+	"github.com/googleapis/librarian/internal/statepb"
+)
+
+// A container runtime which always reports the same image ID. Running a container just
+// records that it ran, by writing a file to the directory mounted as /output.
+const fakeRuntimeScript = `#!/bin/sh
+if [ "$1" != run ]; then
+  echo sha256:1234
+  exit 0
+fi
+for arg in "$@"; do
+  case "$arg" in
+    *:/output) echo generated > "${arg%:/output}/generated.txt" ;;
+  esac
+done
+`
+
+// A local generator which records that it ran by writing a file to its output.
+const markerGeneratorScript = `#!/bin/sh
+for arg in "$@"; do
+  case "$arg" in
+    --output=*) output="${arg#--output=}" ;;
+  esac
+done
+echo generated > "$output/generated.txt"
+`
+
+type fakeRuntime struct {
+	binary string
+}
+
+func (runtime fakeRuntime) Binary() string {
+	return runtime.binary
+}
+
+func (fakeRuntime) UserArgs(uid, gid string) []string {
+	return nil
+}
+
+func (fakeRuntime) RegistryConfigEnvironment(configFile string) ([]string, error) {
+	return nil, nil
+}
+
+func writeScript(t *testing.T, path, content string) string {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestInputHashSkipsUnchangedLibraries(t *testing.T) {
+	tests := []struct {
+		name           string
+		recorded       string // "current" for the hash of the current inputs
+		force          bool
+		noCommitPerApi bool
+		localGenerator bool
+		skipped        bool
+	}{
+		{name: "unchanged", recorded: "current", skipped: true},
+		{name: "unchanged with force", recorded: "current", force: true},
+		{name: "unchanged without commit-per-api", recorded: "current", noCommitPerApi: true},
+		{name: "unchanged with local generator", recorded: "current", localGenerator: true},
+		{name: "changed", recorded: "stale"},
+		{name: "never recorded"},
+	}
+	for _, test := range tests {
+		workRoot := t.TempDir()
+		repoDir := t.TempDir()
+		apiRoot := t.TempDir()
+		outputDir := filepath.Join(workRoot, "output")
+		generatorInput := generatorInputDirNamed(repoDir, "")
+		for _, dir := range []string{outputDir, filepath.Join(apiRoot, "google/foo/v1"), generatorInput} {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.WriteFile(filepath.Join(apiRoot, "google/foo/v1/foo.proto"), []byte("syntax = \"proto3\";"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		ctx := context.Background()
+		containerConfig, err := container.NewContainerConfig(ctx, workRoot, "generator-image", nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		containerConfig.Runtime = fakeRuntime{binary: writeScript(t, filepath.Join(workRoot, "runtime.sh"), fakeRuntimeScript)}
+		if test.localGenerator {
+			containerConfig.LocalGenerator = writeScript(t, filepath.Join(workRoot, "generator.sh"), markerGeneratorScript)
+		}
+		library := &statepb.LibraryState{Id: "foo", ApiPaths: []string{"google/foo/v1"}, SourcePaths: []string{"packages/foo"}}
+		state := &commandState{
+			ctx:             ctx,
+			workRoot:        workRoot,
+			languageRepo:    &gitrepo.Repo{Dir: repoDir},
+			pipelineState:   &statepb.PipelineState{Libraries: []*statepb.LibraryState{library}},
+			containerConfig: containerConfig,
+		}
+		current, err := computeInputHash(ctx, state, apiRoot, generatorInput, library)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch test.recorded {
+		case "current":
+			err = recordInputHash(generatorInput, "foo", current)
+		case "stale":
+			err = recordInputHash(generatorInput, "foo", "stale")
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Recording a hash mustn't change the hash of the inputs, as it's in generator-input.
+		if after, err := computeInputHash(ctx, state, apiRoot, generatorInput, library); err != nil || after != current {
+			t.Errorf("%s: computeInputHash() changed from %s to %s (%v) after recording", test.name, current, after, err)
+		}
+
+		result := &GenerateApiResult{ApiPath: "google/foo/v1", OutputDir: outputDir}
+		opts := &GenerateOptions{ApiRoot: apiRoot, Build: true, CommitPerApi: !test.noCommitPerApi, Force: test.force}
+		if _, err := runGenerateCommand(ctx, state, opts, result); err != nil {
+			t.Fatalf("%s: runGenerateCommand() failed: %s", test.name, err)
+		}
+		_, statErr := os.Stat(filepath.Join(outputDir, "generated.txt"))
+		if generated := statErr == nil; result.Skipped != test.skipped || generated == test.skipped {
+			t.Errorf("%s: runGenerateCommand() expected skipped=%t, got skipped=%t, generated=%t", test.name, test.skipped, result.Skipped, generated)
+		}
+		// The hash is only recorded on commit when it was checked.
+		want := current
+		if test.noCommitPerApi || test.localGenerator {
+			want = ""
+		}
+		if !test.skipped && result.inputHash != want {
+			t.Errorf("%s: runGenerateCommand() expected input hash %q to be recorded on commit, got %q", test.name, want, result.inputHash)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/googleapis/librarian/internal/container"
+)
+
+// The file at the root of a language repo listing (with gitignore-style patterns) the
+// paths which Librarian must never overwrite or remove, e.g. handwritten files within a
+// generated library directory.
+const librarianIgnoreFile = ".librarianignore"
+
+// The paths within a language repo which are protected by its .librarianignore file.
+type librarianIgnore struct {
+	repoDir string
+	matcher gitignore.Matcher
+}
+
+// Loads the .librarianignore file from the root of the repo. If there's no such file,
+// nil is returned, which doesn't match any paths.
+func loadLibrarianIgnore(repoDir string) (*librarianIgnore, error) {
+	file, err := os.Open(filepath.Join(repoDir, librarianIgnoreFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var patterns []gitignore.Pattern
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, gitignore.ParsePattern(line, nil))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", librarianIgnoreFile, err)
+	}
+	return &librarianIgnore{repoDir: repoDir, matcher: gitignore.NewMatcher(patterns)}, nil
+}
+
+// Reports whether the given path (which may be absolute, or relative to the repo root)
+// is protected, either because it matches a pattern or because a directory containing it
+// does, as with .gitignore.
+func (ignore *librarianIgnore) protects(path string, isDir bool) bool {
+	if ignore == nil {
+		return false
+	}
+	if filepath.IsAbs(path) {
+		relative, err := filepath.Rel(ignore.repoDir, path)
+		if err != nil || !filepath.IsLocal(relative) {
+			return false
+		}
+		path = relative
+	}
+	parts := strings.Split(filepath.ToSlash(path), "/")
+	for i := 1; i < len(parts); i++ {
+		if ignore.matcher.Match(parts[:i], true) {
+			return true
+		}
+	}
+	return ignore.matcher.Match(parts, isDir)
+}
+
+// Runs the container's clean command for the library, preserving any files protected by
+// the repo's .librarianignore file within the library's source paths (where cleaning is
+// expected to affect): they're copied to the work root beforehand, and copied back
+// afterwards (even if cleaning fails).
+func cleanLibrary(ctx context.Context, state *commandState, repoDir, libraryID string) (err error) {
+	ignore, err := loadLibrarianIgnore(repoDir)
+	if err != nil {
+		return err
+	}
+	if ignore == nil {
+		return container.Clean(ctx, state.containerConfig, repoDir, libraryID)
+	}
+	backupDir, err := os.MkdirTemp(state.workRoot, "librarianignore-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(backupDir)
+	protected, err := copyProtectedFiles(backupDir, repoDir, cleanedSourcePaths(state, libraryID), ignore)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, relative := range protected {
+			if restoreErr := copyPreservingMode(filepath.Join(repoDir, relative), filepath.Join(backupDir, relative)); restoreErr != nil {
+				err = errors.Join(err, fmt.Errorf("failed to restore %s: %w", relative, restoreErr))
+			}
+		}
+		if len(protected) > 0 {
+			slog.Info(fmt.Sprintf("Preserved %d files matched by %s while cleaning", len(protected), librarianIgnoreFile))
+		}
+	}()
+	return container.Clean(ctx, state.containerConfig, repoDir, libraryID)
+}
+
+// Returns the paths (relative to the repo root) which cleaning the library, or every
+// library if the ID is empty, may affect: the libraries' source paths. If any library has
+// no source paths, the whole repo may be affected.
+func cleanedSourcePaths(state *commandState, libraryID string) []string {
+	if state.pipelineState == nil {
+		return []string{"."}
+	}
+	paths := []string{}
+	for _, library := range state.pipelineState.Libraries {
+		if libraryID != "" && library.Id != libraryID {
+			continue
+		}
+		if len(library.SourcePaths) == 0 {
+			return []string{"."}
+		}
+		paths = append(paths, library.SourcePaths...)
+	}
+	return paths
+}
+
+// Copies the protected files within the given source paths of repoDir to backupDir,
+// returning their relative paths. Source paths which don't exist are ignored.
+func copyProtectedFiles(backupDir, repoDir string, sourcePaths []string, ignore *librarianIgnore) ([]string, error) {
+	var protected []string
+	for _, sourcePath := range sourcePaths {
+		err := filepath.WalkDir(filepath.Join(repoDir, sourcePath), func(path string, d fs.DirEntry, err error) error {
+			if errors.Is(err, fs.ErrNotExist) && path == filepath.Join(repoDir, sourcePath) {
+				return filepath.SkipDir
+			}
+			if err != nil {
+				return err
+			}
+			relative, err := filepath.Rel(repoDir, path)
+			if err != nil || relative == "." {
+				return err
+			}
+			if d.IsDir() {
+				if d.Name() == ".git" {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			// Source paths may overlap, e.g. if one library is nested within another.
+			if !ignore.protects(relative, false) || slices.Contains(protected, relative) {
+				return nil
+			}
+			if err := copyPreservingMode(filepath.Join(backupDir, relative), path); err != nil {
+				return err
+			}
+			protected = append(protected, relative)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return protected, nil
+}
+
+// Copies a file or symlink exactly (without normalizing line endings), replacing any
+// existing file and creating parent directories as required.
+func copyPreservingMode(destPath, sourcePath string) error {
+	info, err := os.Lstat(sourcePath)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(destPath), 0777); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(destPath); err != nil {
+		return err
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		target, err := os.Readlink(sourcePath)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, destPath)
+	}
+	content, err := os.ReadFile(sourcePath)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(destPath, content, info.Mode().Perm())
+}
@@ -0,0 +1,75 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestCopyProtectedFiles(t *testing.T) {
+	tests := []struct {
+		name        string
+		sourcePaths []string
+		want        []string
+	}{
+		{
+			name:        "single library",
+			sourcePaths: []string{"packages/foo"},
+			want:        []string{"packages/foo/handwritten.txt"},
+		},
+		{
+			name:        "overlapping source paths",
+			sourcePaths: []string{"packages", "packages/foo"},
+			want:        []string{"packages/bar/handwritten.txt", "packages/foo/handwritten.txt"},
+		},
+		{
+			name:        "missing source path",
+			sourcePaths: []string{"packages/missing", "packages/bar"},
+			want:        []string{"packages/bar/handwritten.txt"},
+		},
+		{
+			name:        "whole repo",
+			sourcePaths: []string{"."},
+			want:        []string{"handwritten.txt", "packages/bar/handwritten.txt", "packages/foo/handwritten.txt"},
+		},
+	}
+	for _, test := range tests {
+		repoDir := t.TempDir()
+		writeTestFiles(t, repoDir, []string{
+			"handwritten.txt",
+			"packages/foo/generated.txt",
+			"packages/foo/handwritten.txt",
+			"packages/bar/handwritten.txt",
+		})
+		if err := os.WriteFile(filepath.Join(repoDir, librarianIgnoreFile), []byte("handwritten.txt\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		ignore, err := loadLibrarianIgnore(repoDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := copyProtectedFiles(t.TempDir(), repoDir, test.sourcePaths, ignore)
+		if err != nil {
+			t.Fatalf("copyProtectedFiles(%s) failed: %s", test.name, err)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, test.want) {
+			t.Errorf("copyProtectedFiles(%s) expected %v, got %v", test.name, test.want, got)
+		}
+	}
+}
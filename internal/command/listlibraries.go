@@ -0,0 +1,104 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/googleapis/librarian/internal/gitrepo"
+	"github.com/googleapis/librarian/internal/statepb"
+)
+
+const (
+	formatTable = "table"
+	formatJson  = "json"
+)
+
+var CmdListLibraries = &Command{
+	Name:  "list-libraries",
+	Short: "List the libraries configured in a language repo.",
+	flagFunctions: []func(fs *flag.FlagSet){
+		addFlagRepoRoot,
+		addFlagRepoUrl,
+		addFlagRepoRef,
+		addFlagStateCacheTTL,
+		addFlagNoStateCache,
+		addFlagFormat,
+	},
+	maybeGetLanguageRepo: func(ctx context.Context, workRoot string) (*gitrepo.Repo, error) {
+		return nil, nil
+	},
+	maybeLoadStateAndConfig: func(languageRepo *gitrepo.Repo) (*statepb.PipelineState, *statepb.PipelineConfig, error) {
+		return nil, nil, nil
+	},
+	execute: listLibraries,
+}
+
+// A library as listed in JSON format.
+type listedLibrary struct {
+	ID       string   `json:"id"`
+	ApiPaths []string `json:"apiPaths"`
+}
+
+func listLibraries(state *commandState) error {
+	if flagFormat != formatTable && flagFormat != formatJson {
+		return fmt.Errorf("invalid format %q; must be %s or %s", flagFormat, formatTable, formatJson)
+	}
+	pipelineState, err := loadConfiguredPipelineState(state.ctx, languageRepoOptionsFromFlags())
+	if err != nil {
+		return err
+	}
+	if pipelineState == nil {
+		return errors.New("one of repo-root or repo-url must be specified")
+	}
+	if flagFormat == formatJson {
+		return writeLibrariesJson(os.Stdout, pipelineState)
+	}
+	return writeLibrariesTable(os.Stdout, pipelineState)
+}
+
+func writeLibrariesJson(w io.Writer, pipelineState *statepb.PipelineState) error {
+	libraries := []listedLibrary{}
+	for _, library := range pipelineState.Libraries {
+		apiPaths := library.ApiPaths
+		if apiPaths == nil {
+			apiPaths = []string{}
+		}
+		libraries = append(libraries, listedLibrary{ID: library.Id, ApiPaths: apiPaths})
+	}
+	data, err := json.MarshalIndent(libraries, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+func writeLibrariesTable(w io.Writer, pipelineState *statepb.PipelineState) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tAPI PATHS")
+	for _, library := range pipelineState.Libraries {
+		fmt.Fprintf(tw, "%s\t%s\n", library.Id, strings.Join(library.ApiPaths, ", "))
+	}
+	return tw.Flush()
+}
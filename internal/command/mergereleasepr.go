@@ -15,6 +15,7 @@
 package command
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
@@ -24,11 +25,13 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/google/go-github/v69/github"
 	"github.com/googleapis/librarian/internal/githubrepo"
 	"github.com/googleapis/librarian/internal/gitrepo"
+	"github.com/googleapis/librarian/internal/offline"
 	"github.com/googleapis/librarian/internal/statepb"
 )
 
@@ -51,14 +54,20 @@ var CmdMergeReleasePR = &Command{
 	Short: "Merge a validated release PR.",
 	flagFunctions: []func(fs *flag.FlagSet){
 		addFlagImage,
+		addFlagImages,
+		addFlagSecretProvider,
+		addFlagSecretsDir,
 		addFlagSecretsProject,
+		addFlagVaultMount,
 		addFlagWorkRoot,
 		addFlagBaselineCommit,
 		addFlagReleaseID,
 		addFlagReleasePRUrl,
+		addFlagSquash,
+		addFlagSquashMessageTemplate,
 		addFlagSyncUrlPrefix,
 	},
-	maybeGetLanguageRepo: func(workRoot string) (*gitrepo.Repo, error) {
+	maybeGetLanguageRepo: func(ctx context.Context, workRoot string) (*gitrepo.Repo, error) {
 		return nil, nil
 	},
 	maybeLoadStateAndConfig: func(languageRepo *gitrepo.Repo) (*statepb.PipelineState, *statepb.PipelineConfig, error) {
@@ -81,6 +90,17 @@ func mergeReleasePRImpl(state *commandState) error {
 	if githubrepo.GetAccessToken() == "" {
 		return errors.New("no GitHub access token specified")
 	}
+	if err := githubrepo.CheckAccessTokenScopes(state.ctx); err != nil {
+		return err
+	}
+	// Checked up front, so that an invalid template is reported before waiting for the PR.
+	var squashTemplate *template.Template
+	if flagSquash {
+		var err error
+		if squashTemplate, err = parseSquashMessageTemplate(); err != nil {
+			return err
+		}
+	}
 	// We'll assume the PR URL is in the format https://github.com/{owner}/{name}/pulls/{pull-number}
 	prRepo, err := githubrepo.ParseUrl(flagReleasePRUrl)
 	if err != nil {
@@ -98,7 +118,7 @@ func mergeReleasePRImpl(state *commandState) error {
 		return err
 	}
 
-	mergeCommit, err := mergePullRequest(state, prMetadata)
+	mergeCommit, err := mergePullRequest(state, prMetadata, squashTemplate)
 	if err != nil {
 		return err
 	}
@@ -237,12 +257,26 @@ func waitForPullRequestReadinessSingleIteration(state *commandState, prMetadata
 	return true, nil
 }
 
-func mergePullRequest(state *commandState, prMetadata githubrepo.PullRequestMetadata) (string, error) {
+// Merges the PR, squashing it with a commit message created from squashTemplate if that's
+// non-nil, or rebasing it otherwise.
+func mergePullRequest(state *commandState, prMetadata githubrepo.PullRequestMetadata, squashTemplate *template.Template) (string, error) {
 	slog.Info("Merging release PR")
+	method := github.MergeMethodRebase
+	var commitTitle, commitMessage string
+	// The commit message is created before removing the do-not-merge label, so that a
+	// failure doesn't leave the PR unprotected without merging it.
+	if squashTemplate != nil {
+		var err error
+		method = github.MergeMethodSquash
+		commitTitle, commitMessage, err = createSquashCommitMessage(state, prMetadata, squashTemplate)
+		if err != nil {
+			return "", err
+		}
+	}
 	if err := githubrepo.RemoveLabelFromPullRequest(state.ctx, prMetadata.Repo, prMetadata.Number, "do-not-merge"); err != nil {
 		return "", err
 	}
-	mergeResult, err := githubrepo.MergePullRequest(state.ctx, prMetadata.Repo, prMetadata.Number, github.MergeMethodRebase)
+	mergeResult, err := githubrepo.MergePullRequest(state.ctx, prMetadata.Repo, prMetadata.Number, method, commitTitle, commitMessage)
 	if err != nil {
 		return "", err
 	}
@@ -254,6 +288,89 @@ func mergePullRequest(state *commandState, prMetadata githubrepo.PullRequestMeta
 	return *mergeResult.SHA, nil
 }
 
+// The default template for squashed release commits: a title summarizing the releases,
+// followed by the original commit message for each library (which includes the metadata
+// required to create release artifacts later).
+const defaultSquashMessageTemplate = `chore: Release {{range $i, $library := .Libraries}}{{if $i}}, {{end}}{{$library.LibraryID}} {{$library.Version}}{{end}}
+
+{{range .Libraries}}{{.Message}}
+
+{{end}}`
+
+// The data available to -squash-message-template.
+type squashMessageData struct {
+	ReleaseID string
+	Libraries []squashMessageLibrary
+}
+
+// The details of a single library release within a squashed release commit.
+type squashMessageLibrary struct {
+	LibraryID string
+	// The version of the library at the baseline commit, or empty if it
+	// had not been released before.
+	PreviousVersion string
+	Version         string
+	ReleaseNotes    string
+	// The original commit message for the release.
+	Message string
+}
+
+// Parses -squash-message-template, or the default template if it's not specified.
+func parseSquashMessageTemplate() (*template.Template, error) {
+	templateText := flagSquashTemplate
+	if templateText == "" {
+		templateText = defaultSquashMessageTemplate
+	}
+	tmpl, err := template.New("squash-message").Parse(templateText)
+	if err != nil {
+		return nil, fmt.Errorf("invalid squash message template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// Creates the title and message for a squashed release commit by applying the template
+// to the releases in the PR.
+func createSquashCommitMessage(state *commandState, prMetadata githubrepo.PullRequestMetadata, tmpl *template.Template) (string, string, error) {
+	pr, err := githubrepo.GetPullRequest(state.ctx, prMetadata.Repo, prMetadata.Number)
+	if err != nil {
+		return "", "", err
+	}
+	baseRepo := githubrepo.CreateGitHubRepoFromRepository(pr.Base.Repo)
+	baselineState, err := fetchRemotePipelineState(state.ctx, baseRepo, flagBaselineCommit)
+	if err != nil {
+		return "", "", err
+	}
+	prCommits, err := githubrepo.GetDiffCommits(state.ctx, prMetadata.Repo, *pr.Base.SHA, *pr.Head.SHA)
+	if err != nil {
+		return "", "", err
+	}
+
+	data := squashMessageData{ReleaseID: flagReleaseID}
+	for _, commit := range prCommits {
+		release, err := parseCommitMessageForRelease(*commit.Commit.Message, *commit.SHA)
+		if err != nil {
+			return "", "", err
+		}
+		library := squashMessageLibrary{
+			LibraryID:    release.LibraryID,
+			Version:      release.Version,
+			ReleaseNotes: release.ReleaseNotes,
+			Message:      strings.TrimSpace(*commit.Commit.Message),
+		}
+		if baselineLibrary := findLibraryByID(baselineState, release.LibraryID); baselineLibrary != nil {
+			library.PreviousVersion = baselineLibrary.CurrentVersion
+		}
+		data.Libraries = append(data.Libraries, library)
+	}
+
+	var builder strings.Builder
+	if err := tmpl.Execute(&builder, data); err != nil {
+		return "", "", fmt.Errorf("failed to apply squash message template: %w", err)
+	}
+	title, message, _ := strings.Cut(strings.TrimSpace(builder.String()), "\n")
+	return title, strings.TrimSpace(message), nil
+}
+
 // If flagSyncUrlPrefix is empty, this returns immediately.
 // Otherwise, polls for up to 10 minutes (once every 30 seconds) for the
 // given merge commit to be available at the repo specified via flagSyncUrlPrefix.
@@ -261,6 +378,9 @@ func waitForSync(mergeCommit string) error {
 	if flagSyncUrlPrefix == "" {
 		return nil
 	}
+	if err := offline.Check("wait for the merge commit to be synced"); err != nil {
+		return err
+	}
 	req, err := http.NewRequest("GET", flagSyncUrlPrefix+mergeCommit, nil)
 	if err != nil {
 		return fmt.Errorf("error creating HTTP request: %v", err)
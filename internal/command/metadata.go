@@ -0,0 +1,125 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/googleapis/librarian/internal/githubrepo"
+	"github.com/googleapis/librarian/internal/gitrepo"
+	"github.com/googleapis/librarian/internal/statepb"
+	"github.com/googleapis/librarian/internal/utils"
+	"gopkg.in/yaml.v3"
+)
+
+const libraryMetadataFile = "library-metadata.json"
+
+// LibraryMetadata is the standard metadata emitted for each library (when requested
+// via -emit-metadata) alongside its generated code, for downstream indexing such as
+// developer portals.
+type LibraryMetadata struct {
+	// Name is the library ID, as configured in the pipeline state.
+	Name string `json:"name"`
+	// Description is the title of the API, from its service config (if found).
+	Description string `json:"description,omitempty"`
+	// Version is the last-released version of the library, if any.
+	Version string `json:"version,omitempty"`
+	// ApiPaths are the API paths included in the library.
+	ApiPaths []string `json:"apiPaths"`
+	// Homepage is the location of the library within its GitHub repo, if known.
+	Homepage string `json:"homepage,omitempty"`
+}
+
+// Writes a LibraryMetadata file for the given library into outputDir (for -emit-metadata).
+// The file is written within the library's first source path (which is expected to be the
+// library's own directory) so that it's copied to the right place along with the rest of
+// the generated code; if the library has no source paths, the file is written at the root
+// of outputDir. The homepage refers to the base branch, if specified (see -base-branch).
+func emitLibraryMetadata(state *commandState, apiRoot, outputDir, baseBranch string, library *statepb.LibraryState) error {
+	metadata := LibraryMetadata{
+		Name:     library.Id,
+		Version:  library.CurrentVersion,
+		ApiPaths: library.ApiPaths,
+	}
+	for _, apiPath := range library.ApiPaths {
+		if title := findServiceConfigTitle(filepath.Join(apiRoot, apiPath)); title != "" {
+			metadata.Description = title
+			break
+		}
+	}
+
+	libraryDir := ""
+	if len(library.SourcePaths) > 0 {
+		libraryDir = library.SourcePaths[0]
+	}
+	if state.languageRepo != nil {
+		if gitHubRepo, err := gitrepo.GetGitHubRepoFromRemote(state.languageRepo); err == nil {
+			// The library is on the base branch once its PR is merged. Otherwise, GitHub
+			// resolves HEAD to the repo's default branch.
+			branch := baseBranch
+			if branch == "" {
+				branch = "HEAD"
+			}
+			metadata.Homepage = strings.TrimSuffix(fmt.Sprintf("%s%s/%s/tree/%s/%s", githubrepo.BaseUrl(), gitHubRepo.Owner, gitHubRepo.Name, branch, libraryDir), "/")
+		}
+	}
+
+	data, err := json.MarshalIndent(metadata, "", "    ")
+	if err != nil {
+		return err
+	}
+	metadataDir := filepath.Join(outputDir, libraryDir)
+	if err := os.MkdirAll(metadataDir, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(metadataDir, libraryMetadataFile)
+	slog.Info(fmt.Sprintf("Writing library metadata for %s to %s", library.Id, path))
+	return utils.CreateAndWriteBytesToFile(path, data)
+}
+
+// Returns the title from the service config YAML file within the given API directory,
+// or an empty string if no service config (or no title) is found.
+func findServiceConfigTitle(apiDir string) string {
+	entries, err := os.ReadDir(apiDir)
+	if err != nil {
+		return ""
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".yaml") || strings.HasSuffix(name, "gapic.yaml") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(apiDir, name))
+		if err != nil {
+			continue
+		}
+		config := make(map[string]interface{})
+		if err := yaml.Unmarshal(data, &config); err != nil {
+			continue
+		}
+		if t, _ := config["type"].(string); t != "google.api.Service" {
+			continue
+		}
+		if title, ok := config["title"].(string); ok {
+			return title
+		}
+	}
+	return ""
+}
@@ -0,0 +1,84 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"github.com/googleapis/librarian/internal/gitrepo"
+)
+
+// Commits generated output to the mirror repo specified in the options (see -mirror-repo-url),
+// and creates a pull request for it. This supports teams which publish generated code to a
+// repo separate from the source repo. Each entry in outputDirs is expected to be laid out
+// relative to the root of the mirror repo, and results in a single commit (described by
+// the corresponding entry in descriptions). Line endings are normalized as for
+// copyGeneratedCode. If there's no mirror repo, this does nothing.
+func pushToMirrorRepo(state *commandState, opts pullRequestOptions, lineEndings string, outputDirs, descriptions []string, titlePrefix, branchType string) error {
+	if opts.mirrorRepoURL == "" {
+		return nil
+	}
+	if len(outputDirs) == 0 {
+		slog.Info("No generated output to commit to mirror repo.")
+		return nil
+	}
+
+	// Take the last part of the URL as the directory name, as for the language repo,
+	// but within a separate directory so that the two can't clash.
+	bits := strings.Split(opts.mirrorRepoURL, "/")
+	repoPath := filepath.Join(state.workRoot, "mirror", bits[len(bits)-1])
+	mirrorRepo, err := gitrepo.CloneOrOpen(repoPath, opts.mirrorRepoURL, "", 0, gitCredentials())
+	if err != nil {
+		return err
+	}
+
+	prContent := new(PullRequestContent)
+	for i, outputDir := range outputDirs {
+		// Unlike when copying to the language repo, existing files are overwritten:
+		// the mirror repo will already contain a previous version of the files.
+		if err := copyDir(mirrorRepo.Dir, outputDir, true, lineEndings); err != nil {
+			return err
+		}
+		if err := commitAll(state, mirrorRepo, descriptions[i]); err != nil {
+			return err
+		}
+		addSuccessToPullRequest(prContent, "", "mirroring", descriptions[i])
+	}
+
+	// The mirror repo is independent of the language repo, so none of the language repo's
+	// pipeline configuration (e.g. commit limits) or fork applies.
+	mirrorState := *state
+	mirrorState.languageRepo = mirrorRepo
+	mirrorState.pipelineConfig = nil
+	mirrorState.forkRepo = nil
+	_, err = createPullRequest(&mirrorState, opts, prContent, titlePrefix, "", branchType)
+	// Any results written to the work root (e.g. a PR preview) still need to retain it,
+	// and the mirror PR is part of the run history.
+	state.workRootResults = mirrorState.workRootResults
+	state.pullRequestURLs = mirrorState.pullRequestURLs
+	return err
+}
+
+// Checks that -push is only specified along with -mirror-repo-url, for commands (such as
+// generate) which only push to the mirror repo, so that -push isn't silently ignored.
+func validateMirrorPush(opts pullRequestOptions) error {
+	if opts.push && opts.mirrorRepoURL == "" {
+		return errors.New("-push requires -mirror-repo-url, as generated code is only pushed to the mirror repo")
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"testing"
+)
+
+func TestValidateMirrorPush(t *testing.T) {
+	tests := []struct {
+		push      bool
+		mirrorURL string
+		wantErr   bool
+	}{
+		{push: false, mirrorURL: "", wantErr: false},
+		{push: false, mirrorURL: "https://github.com/owner/mirror", wantErr: false},
+		{push: true, mirrorURL: "https://github.com/owner/mirror", wantErr: false},
+		{push: true, mirrorURL: "", wantErr: true},
+	}
+	for _, test := range tests {
+		opts := pullRequestOptions{push: test.push, mirrorRepoURL: test.mirrorURL}
+		if err := validateMirrorPush(opts); (err != nil) != test.wantErr {
+			t.Errorf("validateMirrorPush() with -push=%t -mirror-repo-url=%q expected error %t, got %v", test.push, test.mirrorURL, test.wantErr, err)
+		}
+	}
+}
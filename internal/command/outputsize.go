@@ -0,0 +1,157 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How often the size of a generator's output is checked while it runs, with -max-output-size.
+const outputSizePollInterval = 5 * time.Second
+
+// The suffixes accepted by -max-output-size, and the number of bytes each represents. Longer
+// suffixes come first, so that e.g. "MB" isn't matched as "B".
+var byteSizeSuffixes = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"KiB", 1 << 10},
+	{"MiB", 1 << 20},
+	{"GiB", 1 << 30},
+	{"KB", 1000},
+	{"MB", 1000 * 1000},
+	{"GB", 1000 * 1000 * 1000},
+	{"K", 1 << 10},
+	{"M", 1 << 20},
+	{"G", 1 << 30},
+	{"B", 1},
+}
+
+// A size in bytes, specified as a flag with an optional unit suffix (e.g. 500MB or 2GiB).
+// Zero means there's no limit.
+type byteSize int64
+
+func (size *byteSize) Set(value string) error {
+	value = strings.TrimSpace(value)
+	multiplier := int64(1)
+	for _, unit := range byteSizeSuffixes {
+		if number, ok := strings.CutSuffix(value, unit.suffix); ok {
+			value, multiplier = strings.TrimSpace(number), unit.bytes
+			break
+		}
+	}
+	number, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || number < 0 || number > (1<<62)/multiplier {
+		return fmt.Errorf("invalid size %q; must be a number of bytes, optionally with a unit such as KB, MB, GB, KiB, MiB or GiB", value)
+	}
+	*size = byteSize(number * multiplier)
+	return nil
+}
+
+func (size *byteSize) String() string {
+	return strconv.FormatInt(int64(*size), 10)
+}
+
+// Returns the total size of the regular files within a directory. Files which are removed
+// while the directory is being walked (e.g. by a generator which is still running) are ignored.
+func dirSize(dir string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		total += info.Size()
+		return nil
+	})
+	return total, err
+}
+
+// Checks that the generator output in outputDir doesn't exceed the maximum size (if positive).
+// The ID is the library ID or API path.
+func checkOutputSize(outputDir, id string, maxSize int64) error {
+	if maxSize <= 0 {
+		return nil
+	}
+	size, err := dirSize(outputDir)
+	if err != nil {
+		return err
+	}
+	if size > maxSize {
+		return outputSizeError(id, size, maxSize)
+	}
+	return nil
+}
+
+func outputSizeError(id string, size, maxSize int64) error {
+	return fmt.Errorf("generator output for %s is %d bytes, exceeding -max-output-size of %d bytes", id, size, maxSize)
+}
+
+// Periodically checks the size of outputDir while a generator runs, so that a runaway
+// generator is killed (by cancelling the returned context) before it fills the disk. The
+// returned function stops watching, and returns an error describing the output size if
+// the limit was exceeded. Errors walking the directory are ignored here, as the generator
+// may be changing it; they're reported by the final checkOutputSize instead.
+func watchOutputSize(ctx context.Context, outputDir, id string, maxSize int64) (context.Context, func() error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	done := make(chan struct{})
+	result := make(chan error, 1)
+	go func() {
+		ticker := time.NewTicker(outputSizePollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				result <- nil
+				return
+			case <-ctx.Done():
+				result <- nil
+				return
+			case <-ticker.C:
+				if size, err := dirSize(outputDir); err == nil && size > maxSize {
+					err := outputSizeError(id, size, maxSize)
+					cancel(err)
+					result <- err
+					return
+				}
+			}
+		}
+	}()
+	return ctx, func() error {
+		close(done)
+		err := <-result
+		cancel(nil)
+		return err
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/googleapis/librarian/internal/container"
+)
+
+// The name of the optional per-library post-processing script, within
+// generator-input/{library-id}.
+const postProcessScript = "postprocess.sh"
+
+// The environment variables in which the output directory and library ID (or API path)
+// are passed to the command specified with -post-generate-hook.
+const (
+	postGenerateHookOutputDirEnvironmentVariable = "LIBRARIAN_HOOK_OUTPUT_DIR"
+	postGenerateHookLibraryIDEnvironmentVariable = "LIBRARIAN_HOOK_LIBRARY_ID"
+)
+
+// Runs the library's post-processing script (generator-input/{library-id}/postprocess.sh)
+// against the generated code in outputDir, if the script exists. This allows bespoke
+// post-generation tweaks to be committed to the language repo, without language-specific
+// logic in Librarian.
+func maybePostProcess(state *commandState, generatorInput, outputDir, libraryID string) error {
+	scriptPath := filepath.Join(libraryID, postProcessScript)
+	if _, err := os.Stat(filepath.Join(generatorInput, scriptPath)); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	slog.Info(fmt.Sprintf("Running post-processing script for %s", libraryID))
+	return container.PostProcess(state.containerConfig, outputDir, generatorInput, scriptPath, libraryID)
+}
+
+// Runs the given hook command (specified with -post-generate-hook), if any, on the host,
+// against the generated code in outputDir. Unlike the post-processing script, this isn't run in the
+// container, so it can use tools which aren't part of the image. The ID is the library
+// ID or, for raw generation, the API path. The hook's output is written to stderr.
+func maybeRunPostGenerateHook(ctx context.Context, hook, outputDir, id string) error {
+	if hook == "" {
+		return nil
+	}
+	slog.Info(fmt.Sprintf("Running post-generate hook for %s", id))
+	// The arguments are appended to the command with "$@", and are available as $1 and $2.
+	cmd := exec.CommandContext(ctx, "sh", "-c", hook+` "$@"`, "post-generate-hook", outputDir, id)
+	cmd.Env = append(os.Environ(),
+		postGenerateHookOutputDirEnvironmentVariable+"="+outputDir,
+		postGenerateHookLibraryIDEnvironmentVariable+"="+id)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("post-generate hook failed for %s: %w", id, err)
+	}
+	return nil
+}
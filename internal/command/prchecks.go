@@ -0,0 +1,111 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v69/github"
+	"github.com/googleapis/librarian/internal/githubrepo"
+)
+
+// The interval before the first poll of a PR's checks, which doubles after each poll
+// up to the maximum.
+const (
+	initialChecksPollInterval = 15 * time.Second
+	maxChecksPollInterval     = 2 * time.Minute
+)
+
+// How long to wait for any checks to be reported. GitHub may take a little while to start
+// the checks for a new PR, but a repo may have no checks at all.
+const noChecksGracePeriod = 2 * time.Minute
+
+// The check runs of a PR, by state. Pending and failed checks are described by name.
+type checkRunSummary struct {
+	passed  int
+	pending []string
+	failed  []string
+}
+
+// Summarizes the check runs of a PR. As in waitForPullRequestReadinessSingleIteration,
+// the do-not-merge and conventional commits checks are ignored.
+func summarizeCheckRuns(checkRuns []*github.CheckRun) checkRunSummary {
+	summary := checkRunSummary{}
+	for _, checkRun := range checkRuns {
+		if checkRun.GetApp().GetID() == DoNotMergeAppId || checkRun.GetApp().GetID() == ConventionalCommitsAppId {
+			continue
+		}
+		if checkRun.GetStatus() != "completed" {
+			summary.pending = append(summary.pending, checkRun.GetName())
+			continue
+		}
+		switch checkRun.GetConclusion() {
+		case "success", "neutral", "skipped":
+			summary.passed++
+		default:
+			summary.failed = append(summary.failed, fmt.Sprintf("%s (%s)", checkRun.GetName(), checkRun.GetConclusion()))
+		}
+	}
+	return summary
+}
+
+// Polls the checks of the PR, with exponential backoff, until they've all completed,
+// returning an error if any of them failed or if they haven't completed within the timeout.
+func waitForPullRequestChecks(ctx context.Context, prMetadata githubrepo.PullRequestMetadata, timeout time.Duration) error {
+	slog.Info(fmt.Sprintf("Waiting up to %s for checks to complete on PR %d", timeout, prMetadata.Number))
+	start := time.Now()
+	deadline := start.Add(timeout)
+	interval := initialChecksPollInterval
+	for {
+		pr, err := githubrepo.GetPullRequest(ctx, prMetadata.Repo, prMetadata.Number)
+		if err != nil {
+			return err
+		}
+		checkRuns, err := githubrepo.GetPullRequestCheckRuns(ctx, pr)
+		if err != nil {
+			return err
+		}
+		summary := summarizeCheckRuns(checkRuns)
+		remaining := time.Until(deadline)
+		if len(summary.failed) > 0 {
+			return fmt.Errorf("checks failed for PR %s: %s", prMetadata.URL, strings.Join(summary.failed, ", "))
+		}
+		if len(summary.pending) == 0 {
+			if summary.passed > 0 {
+				slog.Info(fmt.Sprintf("All %d checks passed for PR %d", summary.passed, prMetadata.Number))
+				return nil
+			}
+			if time.Since(start) >= noChecksGracePeriod || remaining <= 0 {
+				slog.Info(fmt.Sprintf("No checks were reported for PR %d", prMetadata.Number))
+				return nil
+			}
+		}
+		if remaining <= 0 {
+			return fmt.Errorf("timed out after %s waiting for checks on PR %s; still pending: %s", timeout, prMetadata.URL, strings.Join(summary.pending, ", "))
+		}
+		wait := min(interval, remaining)
+		slog.Info(fmt.Sprintf("%d checks passed and %d pending for PR %d; checking again in %s", summary.passed, len(summary.pending), prMetadata.Number, wait.Round(time.Second)))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		interval = min(interval*2, maxChecksPollInterval)
+	}
+}
@@ -15,6 +15,7 @@
 package command
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"flag"
@@ -36,17 +37,25 @@ var CmdPublishReleaseArtifacts = &Command{
 	flagFunctions: []func(fs *flag.FlagSet){
 		addFlagArtifactRoot,
 		addFlagImage,
+		addFlagImages,
 		addFlagWorkRoot,
 		addFlagLanguage,
+		addFlagSecretProvider,
+		addFlagSecretsDir,
 		addFlagSecretsProject,
+		addFlagVaultMount,
 		addFlagTagRepoUrl,
+		addFlagContainerRuntime,
+		addFlagLocalGenerator,
+		addFlagRegistryConfig,
+		addFlagContainerLogs,
 	},
-	maybeGetLanguageRepo: func(workRoot string) (*gitrepo.Repo, error) {
+	maybeGetLanguageRepo: func(ctx context.Context, workRoot string) (*gitrepo.Repo, error) {
 		return nil, nil
 	},
 	maybeLoadStateAndConfig: func(languageRepo *gitrepo.Repo) (*statepb.PipelineState, *statepb.PipelineConfig, error) {
 		// Load the state and config from the artifact directory. These will have been created by create-release-artifacts.
-		state, err := loadPipelineStateFile(filepath.Join(flagArtifactRoot, pipelineStateFile))
+		state, err := loadPipelineStateFile(findPipelineStateFile(flagArtifactRoot))
 		if err != nil {
 			return nil, nil, err
 		}
@@ -56,7 +65,8 @@ var CmdPublishReleaseArtifacts = &Command{
 		}
 		return state, config, nil
 	},
-	execute: publishReleaseArtifactsImpl,
+	execute:        publishReleaseArtifactsImpl,
+	runsContainers: true,
 }
 
 func publishReleaseArtifactsImpl(state *commandState) error {
@@ -67,6 +77,9 @@ func publishReleaseArtifactsImpl(state *commandState) error {
 	if err := validateRequiredFlag("tag-repo-url", flagTagRepoUrl); err != nil {
 		return err
 	}
+	if err := githubrepo.CheckAccessTokenScopes(state.ctx); err != nil {
+		return err
+	}
 
 	releasesJson, err := utils.ReadAllBytesFromFile(filepath.Join(flagArtifactRoot, "releases.json"))
 	if err != nil {
@@ -18,10 +18,18 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/googleapis/librarian/internal/githubrepo"
 	"github.com/googleapis/librarian/internal/gitrepo"
+	"github.com/googleapis/librarian/internal/tracing"
+	"github.com/googleapis/librarian/internal/utils"
 )
 
 // A PullRequestContent builds up the content of a pull request.
@@ -31,88 +39,546 @@ import (
 // (It's important that each entry in "Successes" represents *exactly*
 // one commit, in the same order in which the commits were created. This
 // is assumed when observing pull request commit limits.)
+// The add*ToPullRequest helpers may be called concurrently, e.g. from parallel workers.
 type PullRequestContent struct {
+	// Guards Successes, Errors and the fields derived from them.
+	mutex     sync.Mutex
 	Successes []string
 	Errors    []string
+	// Structured versions of Successes and Errors, in the same order,
+	// used when formatting large PRs as tables.
+	successEntries []pullRequestEntry
+	errorEntries   []pullRequestEntry
+	// The first error added, with its details, for -fail-fast.
+	firstError error
+	// Whether the commits for Successes are squashed into a single commit when
+	// the pull request is created.
+	squash bool
 }
 
+// A single success or error, in terms of the library (or API) it applies to,
+// the action that was performed, and its outcome.
+type pullRequestEntry struct {
+	id     string
+	action string
+	status string
+}
+
+// The number of successes and errors (combined) above which they're formatted as
+// collapsible tables rather than simple lists.
+const pullRequestTableThreshold = 20
+
 // Add details to a PullRequestContent of a partial error which prevents a
 // single API or library from being configured/regenerated/released,
 // but without halting the overall process. A warning is logged locally with the error details,
-// but we don't include detailed errors in the PR, as this could reveal sensitive information.
+// but we don't include detailed errors in the PR (unless -verbose-pr-errors is specified), as this
+// could reveal sensitive information.
 // The action should describe what failed, e.g. "configuring", "building", "generating".
 func addErrorToPullRequest(pr *PullRequestContent, id string, err error, action string) {
-	slog.Warn(fmt.Sprintf("Error while %s %s: %s", action, id, err))
-	pr.Errors = append(pr.Errors, fmt.Sprintf("Error while %s %s", action, id))
+	text := logPartialError(id, err, action)
+	pr.mutex.Lock()
+	defer pr.mutex.Unlock()
+	pr.Errors = append(pr.Errors, text)
+	status := "failed" + exitCodeSuffix(err)
+	if flagVerbosePRErrors {
+		status = fmt.Sprintf("failed: %s", err)
+	}
+	pr.errorEntries = append(pr.errorEntries, pullRequestEntry{id: id, action: action, status: status})
+	if pr.firstError == nil {
+		pr.firstError = fmt.Errorf("error while %s %s: %w", action, id, err)
+	}
+}
+
+// Returns the first error added to a PullRequestContent if failFast is true (see -fail-fast),
+// so that the command stops immediately instead of continuing with the next library or API.
+func failFastError(pr *PullRequestContent, failFast bool) error {
+	pr.mutex.Lock()
+	defer pr.mutex.Unlock()
+	if !failFast || pr.firstError == nil {
+		return nil
+	}
+	return fmt.Errorf("stopping at first failure (-fail-fast): %w", pr.firstError)
 }
 
-// Adds a success entry to a PullRequestContent.
-func addSuccessToPullRequest(pr *PullRequestContent, text string) {
+// Adds a success entry to a PullRequestContent. The text is used in simple lists; the
+// ID (of the library or API, or empty if the change isn't specific to one) and action
+// (e.g. "generating") are used when formatting tables.
+func addSuccessToPullRequest(pr *PullRequestContent, id, action, text string) {
+	pr.mutex.Lock()
+	defer pr.mutex.Unlock()
 	pr.Successes = append(pr.Successes, text)
+	pr.successEntries = append(pr.successEntries, pullRequestEntry{id: id, action: action, status: "succeeded"})
+}
+
+// The outcome of createPullRequest.
+type PullRequestOutcome string
+
+const (
+	// A pull request was created (or an existing one updated, with -update-existing).
+	PullRequestCreated PullRequestOutcome = "Created"
+	// There were no successes or errors, so there was nothing to create a pull request for.
+	PullRequestSkippedEmpty PullRequestOutcome = "SkippedEmpty"
+	// A pull request would have been created, but -push wasn't specified.
+	PullRequestSkippedNoPush PullRequestOutcome = "SkippedNoPush"
+	// There were only errors, so no pull request was created.
+	PullRequestFailedAllErrors PullRequestOutcome = "FailedAllErrors"
+)
+
+// The result of createPullRequest. Metadata (including the PR number and URL) is only
+// populated when the outcome is PullRequestCreated.
+type PullRequestResult struct {
+	Outcome  PullRequestOutcome
+	Metadata *githubrepo.PullRequestMetadata
+}
+
+// Settings controlling how pull requests are created, populated from flags by
+// pullRequestOptionsFromFlags so that createPullRequest doesn't depend on them directly.
+// Each command populates them once, and passes them to everything which needs them.
+type pullRequestOptions struct {
+	push                bool
+	draft               bool
+	updateExisting      bool
+	keepBranchOnFailure bool
+	labels              []string
+	reviewers           []string
+	assignees           []string
+	autoMerge           bool
+	autoMergeMethod     string
+	// The branch the PR is created against (and existing PRs must target). If empty, the
+	// repo's default branch is used.
+	baseBranch string
+	// Whether to wait for the PR's checks to complete (see waitForPullRequestChecks).
+	waitForChecks bool
+	checksTimeout time.Duration
+	// Used to find an existing PR to update.
+	existingLabel  string
+	existingAuthor string
+	// Used to format branch names; see formatBranchName.
+	branchTemplate string
+	branchPrefix   string
+	apiPath        string
+	// Whether to stop at the first library or API which fails, without creating a PR.
+	failFast bool
+	// The repo to which generated code is also pushed (see pushToMirrorRepo), if any.
+	mirrorRepoURL string
+}
+
+func pullRequestOptionsFromFlags() pullRequestOptions {
+	return pullRequestOptions{
+		push:                flagPush,
+		draft:               flagDraft,
+		updateExisting:      flagUpdateExisting,
+		keepBranchOnFailure: flagKeepBranchOnFailure,
+		labels:              flagPRLabels,
+		reviewers:           flagPRReviewers,
+		assignees:           flagPRAssignees,
+		autoMerge:           flagPRAutoMerge,
+		autoMergeMethod:     flagPRAutoMergeMethod,
+		baseBranch:          flagBaseBranch,
+		waitForChecks:       flagWaitForChecks,
+		checksTimeout:       flagChecksTimeout,
+		existingLabel:       flagExistingPRLabel,
+		existingAuthor:      flagExistingPRAuthor,
+		branchTemplate:      flagBranchTemplate,
+		branchPrefix:        flagBranchPrefix,
+		apiPath:             flagAPIPath,
+		failFast:            flagFailFast,
+		mirrorRepoURL:       flagMirrorRepoUrl,
+	}
 }
 
 // Creates a GitHub pull request based on the given content, with a title prefix (e.g. "feat: API regeneration")
-// using a branch with a name of the form "librarian-{branchtype}-{timestamp}".
+// using a branch named according to the branch template (by default "librarian-{branchtype}-{timestamp}").
 // If content is empty, the pull request is not created and no error is returned.
-// If content only contains errors, the pull request is not created and an error is returned (to highlight that everything failed)
+// If content only contains errors, the pull request is not created and an error is returned (to highlight that everything failed),
+// along with a result indicating that this was the case.
 // If content contains any successes, a pull request is created and no error is returned (if the creation is successful) even if the content includes errors.
 // If the pull request would contain an excessive number of commits (as configured in pipeline-config.json)
-func createPullRequest(state *commandState, content *PullRequestContent, titlePrefix, descriptionSuffix, branchType string) (*githubrepo.PullRequestMetadata, error) {
+func createPullRequest(state *commandState, opts pullRequestOptions, content *PullRequestContent, titlePrefix, descriptionSuffix, branchType string) (_ *PullRequestResult, err error) {
+	_, phase := tracing.StartPhase(state.ctx, "create-pull-request")
+	defer func() { phase.End(err) }()
+
 	anySuccesses := len(content.Successes) > 0
 	anyErrors := len(content.Errors) > 0
 	languageRepo := state.languageRepo
 
 	excessSuccesses := []string{}
+	var excessEntries []pullRequestEntry
 	if state.pipelineConfig != nil {
 		maxCommits := int(state.pipelineConfig.MaxPullRequestCommits)
 		if maxCommits > 0 && len(content.Successes) > maxCommits {
 			// We've got too many commits. Roll some back locally, and we'll add them to the description.
 			excessSuccesses = content.Successes[maxCommits:]
 			content.Successes = content.Successes[:maxCommits]
+			if len(content.successEntries) > maxCommits {
+				excessEntries = content.successEntries[maxCommits:]
+				content.successEntries = content.successEntries[:maxCommits]
+			}
 			slog.Info(fmt.Sprintf("%d excess commits created; winding back language repo.", len(excessSuccesses)))
 			if err := gitrepo.CleanAndRevertCommits(languageRepo, len(excessSuccesses)); err != nil {
 				return nil, err
 			}
 		}
 	}
+	if content.squash && len(content.Successes) > 1 {
+		if err := squashCommits(state, len(content.Successes), titlePrefix); err != nil {
+			return nil, err
+		}
+	}
 
 	var description string
 	if !anySuccesses && !anyErrors {
 		slog.Info("No PR to create, and no errors.")
-		return nil, nil
+		return &PullRequestResult{Outcome: PullRequestSkippedEmpty}, nil
 	} else if !anySuccesses && anyErrors {
 		slog.Error("No PR to create, but errors were logged (and restated below). Aborting.")
 		for _, error := range content.Errors {
 			slog.Error(error)
 		}
-		return nil, errors.New("errors encountered but no PR to create")
+		return &PullRequestResult{Outcome: PullRequestFailedAllErrors}, errors.New("errors encountered but no PR to create")
 	}
 
-	successesText := formatListAsMarkdown("Changes in this PR", content.Successes)
-	errorsText := formatListAsMarkdown("Errors", content.Errors)
-	excessText := formatListAsMarkdown("Excess changes not included", excessSuccesses)
-
-	description = strings.TrimSpace(successesText + errorsText + excessText + "\n" + descriptionSuffix)
+	useTables := len(content.Successes)+len(content.Errors)+len(excessSuccesses) > pullRequestTableThreshold
+	successesSection := &pullRequestSection{title: "Changes in this PR", list: content.Successes, entries: content.successEntries}
+	errorsSection := &pullRequestSection{title: "Errors", list: content.Errors, entries: content.errorEntries}
+	excessSection := &pullRequestSection{title: "Excess changes not included", list: excessSuccesses, entries: excessEntries}
+	sections := []*pullRequestSection{successesSection, errorsSection, excessSection}
+	trailer := "\n" + formatProvenance(state) + descriptionSuffix
+	description = formatPullRequestDescription(sections, useTables, trailer)
+	// The trailer is never truncated, as it may contain metadata used by later stages.
+	// Sections are truncated least important first.
+	for _, section := range []*pullRequestSection{excessSection, errorsSection, successesSection} {
+		if len(description) <= maxPullRequestBodyLength {
+			break
+		}
+		description = truncatePullRequestSection(section, func() string {
+			return formatPullRequestDescription(sections, useTables, trailer)
+		})
+		if section.truncated > 0 {
+			slog.Warn(fmt.Sprintf("PR description too long; truncated %d entries from section '%s'", section.truncated, section.title))
+		}
+	}
 
-	title := fmt.Sprintf("%s: %s", titlePrefix, formatTimestamp(state.startTime))
+	title := fmt.Sprintf("%s: %s", titlePrefix, formatTitleTimestamp(state.startTime))
 
-	if !flagPush {
+	if !opts.push {
 		slog.Info(fmt.Sprintf("Push not specified; would have created PR with the following title and description:\n%s\n\n%s", title, description))
-		return nil, nil
+		branch := formatBranchName(opts, branchType, formatTimestamp(state.startTime))
+		if err := writePullRequestPreview(state, title, branch, description); err != nil {
+			return nil, err
+		}
+		return &PullRequestResult{Outcome: PullRequestSkippedNoPush}, nil
 	}
 
 	gitHubRepo, err := gitrepo.GetGitHubRepoFromRemote(languageRepo)
 	if err != nil {
 		return nil, err
 	}
+	if opts.baseBranch == "" {
+		if opts.baseBranch, err = githubrepo.GetDefaultBranch(state.ctx, gitHubRepo); err != nil {
+			return nil, err
+		}
+	}
+	// Checked before pushing, as GitHub's error for a missing base branch is unclear.
+	exists, err := githubrepo.BranchExists(state.ctx, gitHubRepo, opts.baseBranch)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("base branch %s (-base-branch) doesn't exist in %s/%s", opts.baseBranch, gitHubRepo.Owner, gitHubRepo.Name)
+	}
 
-	branch := fmt.Sprintf("librarian-%s-%s", branchType, formatTimestamp(state.startTime))
-	err = gitrepo.PushBranch(languageRepo, branch, githubrepo.GetAccessToken())
+	var prMetadata *githubrepo.PullRequestMetadata
+	if opts.updateExisting {
+		prMetadata, err = updateExistingPullRequest(state, opts, gitHubRepo, branchType, title, description)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if prMetadata == nil {
+		branch := formatBranchName(opts, branchType, formatTimestamp(state.startTime))
+		err = pushPullRequestBranch(state, branch, false)
+		if err != nil {
+			slog.Info(fmt.Sprintf("Received error pushing branch: '%s'", err))
+			return nil, err
+		}
+		headOwner := ""
+		if state.forkRepo != nil {
+			headOwner = state.forkRepo.Owner
+		}
+		prMetadata, err = githubrepo.CreatePullRequest(state.ctx, gitHubRepo, headOwner, branch, opts.baseBranch, title, description, opts.draft)
+		if err != nil {
+			// Don't leave an orphaned branch behind, unless asked to (e.g. for diagnosis).
+			if opts.keepBranchOnFailure {
+				slog.Warn(fmt.Sprintf("Failed to create PR; keeping branch %s", branch))
+			} else if deleteErr := deletePullRequestBranch(state, branch); deleteErr != nil {
+				slog.Warn(fmt.Sprintf("Failed to create PR, and then failed to delete branch %s: %s", branch, deleteErr))
+			}
+			return nil, err
+		}
+	}
+	for _, label := range opts.labels {
+		// As with auto-merge, the PR is more important than its labels.
+		if err := githubrepo.AddLabelToPullRequest(state.ctx, *prMetadata, label); err != nil {
+			slog.Warn(fmt.Sprintf("Unable to add label '%s' to PR %d: %s", label, prMetadata.Number, err))
+			continue
+		}
+		prMetadata.Labels = append(prMetadata.Labels, label)
+	}
+	// Reviewers are requested one at a time, as GitHub rejects the whole request if any
+	// reviewer doesn't exist (or isn't a collaborator).
+	for _, reviewer := range opts.reviewers {
+		if err := githubrepo.RequestReviewers(state.ctx, *prMetadata, []string{reviewer}); err != nil {
+			slog.Warn(fmt.Sprintf("Unable to request review from '%s' for PR %d: %s", reviewer, prMetadata.Number, err))
+		}
+	}
+	if len(opts.assignees) > 0 {
+		if err := githubrepo.AddAssignees(state.ctx, *prMetadata, opts.assignees); err != nil {
+			slog.Warn(fmt.Sprintf("Unable to add assignees to PR %d: %s", prMetadata.Number, err))
+		}
+	}
+	if opts.autoMerge {
+		// Auto-merge may be disabled for the repo; that shouldn't fail the whole command,
+		// as the PR can still be merged manually.
+		if err := githubrepo.EnablePullRequestAutoMerge(state.ctx, *prMetadata, strings.ToUpper(opts.autoMergeMethod)); err != nil {
+			slog.Warn(fmt.Sprintf("Unable to enable auto-merge for PR %d: %s", prMetadata.Number, err))
+		} else {
+			slog.Info(fmt.Sprintf("Enabled auto-merge (%s) for PR %d", opts.autoMergeMethod, prMetadata.Number))
+		}
+	}
+	result := &PullRequestResult{Outcome: PullRequestCreated, Metadata: prMetadata}
+	state.pullRequestURLs = append(state.pullRequestURLs, prMetadata.URL)
+	if opts.waitForChecks {
+		// The PR exists regardless of its checks, so it's still returned.
+		return result, waitForPullRequestChecks(state.ctx, *prMetadata, opts.checksTimeout)
+	}
+	return result, nil
+}
+
+// Writes the PR which would have been created (without -push) to pr-preview.md in the work
+// root, as Markdown which can be reviewed before enabling push. The description is exactly
+// as it would be submitted. If a command would create multiple PRs (e.g. with -mirror-repo-url),
+// each is appended to the file.
+func writePullRequestPreview(state *commandState, title, branch, description string) error {
+	const results = "PR preview"
+	path := filepath.Join(state.workRoot, "pr-preview.md")
+	preview := fmt.Sprintf("# %s\n\nBranch: `%s`\n\n---\n\n%s\n", title, branch, description)
+	var err error
+	if slices.Contains(state.workRootResults, results) {
+		err = utils.AppendToFile(path, "\n---\n\n"+preview)
+	} else {
+		err = utils.CreateAndWriteToFile(path, preview)
+		retainWorkRoot(state, results)
+	}
 	if err != nil {
+		return err
+	}
+	slog.Info(fmt.Sprintf("Wrote PR preview to %s", path))
+	return nil
+}
+
+// Looks for an open PR previously created by Librarian for the same type of change, i.e. one
+// whose branch matches the branch template for any timestamp. If there is one, its branch is
+// replaced with the current HEAD of the language repo (which already contains all the changes
+// from the current main branch) and its title and description are updated. If there's no
+// such PR, nil is returned so that a new PR can be created.
+func updateExistingPullRequest(state *commandState, opts pullRequestOptions, gitHubRepo githubrepo.GitHubRepo, branchType, title, description string) (*githubrepo.PullRequestMetadata, error) {
+	// Timestamps never contain characters modified by sanitization, so we can format the
+	// branch name with a sentinel timestamp and then replace it with a pattern.
+	const sentinelTimestamp = "00000000T000000Z"
+	pattern := regexp.QuoteMeta(formatBranchName(opts, branchType, sentinelTimestamp))
+	// Branches created before timestamps included milliseconds are matched too.
+	pattern = strings.ReplaceAll(pattern, sentinelTimestamp, `\d{8}T\d{6}(\.\d{3})?Z`)
+	branchRegex, err := regexp.Compile("^" + pattern + "$")
+	if err != nil {
+		return nil, err
+	}
+	branchPrefix, _, _ := strings.Cut(formatBranchName(opts, branchType, sentinelTimestamp), sentinelTimestamp)
+	var headRepo githubrepo.GitHubRepo
+	if state.forkRepo != nil {
+		headRepo = *state.forkRepo
+	}
+	candidates, err := githubrepo.FindPullRequests(state.ctx, gitHubRepo, githubrepo.PullRequestFilter{
+		Label:        opts.existingLabel,
+		Author:       opts.existingAuthor,
+		BranchPrefix: branchPrefix,
+		HeadRepo:     headRepo,
+		BaseBranch:   opts.baseBranch,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var prMetadata *githubrepo.PullRequestMetadata
+	for _, candidate := range candidates {
+		if branchRegex.MatchString(candidate.Branch) {
+			prMetadata = candidate
+			break
+		}
+	}
+	if prMetadata == nil {
+		slog.Info("No existing PR to update; creating a new one.")
+		return nil, nil
+	}
+	branch := prMetadata.Branch
+	slog.Info(fmt.Sprintf("Updating existing PR %d (branch %s)", prMetadata.Number, branch))
+	if err := pushPullRequestBranch(state, branch, true); err != nil {
 		slog.Info(fmt.Sprintf("Received error pushing branch: '%s'", err))
 		return nil, err
 	}
-	return githubrepo.CreatePullRequest(state.ctx, gitHubRepo, branch, title, description)
+	if err := githubrepo.UpdatePullRequest(state.ctx, *prMetadata, title, description); err != nil {
+		return nil, err
+	}
+	return prMetadata, nil
+}
+
+// Pushes HEAD of the language repo to the given branch, either in the fork specified by
+// -fork-repo or in the language repo's default remote.
+func pushPullRequestBranch(state *commandState, branch string, force bool) error {
+	if state.forkRepo != nil {
+		return gitrepo.PushBranchToGitHubRepo(state.languageRepo, *state.forkRepo, branch, gitCredentials(), force)
+	}
+	if force {
+		return gitrepo.ForcePushBranch(state.languageRepo, branch, gitCredentials())
+	}
+	return gitrepo.PushBranch(state.languageRepo, branch, gitCredentials())
+}
+
+// Deletes a branch pushed by pushPullRequestBranch.
+func deletePullRequestBranch(state *commandState, branch string) error {
+	if state.forkRepo != nil {
+		return githubrepo.DeleteBranch(state.ctx, *state.forkRepo, branch)
+	}
+	return gitrepo.DeleteRemoteBranch(state.languageRepo, branch, gitCredentials())
+}
+
+// Squashes the last count commits in the language repo into a single commit with the given
+// title, followed by the original commit messages (so that they're still used for release notes).
+func squashCommits(state *commandState, count int, title string) error {
+	messages, err := gitrepo.SoftResetCommits(state.languageRepo, count)
+	if err != nil {
+		return err
+	}
+	for i, message := range messages {
+		messages[i] = strings.TrimSpace(message)
+	}
+	slog.Info(fmt.Sprintf("Squashing %d commits", count))
+	return commitAll(state, state.languageRepo, fmt.Sprintf("%s\n\n%s\n", title, strings.Join(messages, "\n\n")))
+}
+
+// Formats the name of the branch to push for a PR, by expanding the placeholders in
+// the branch template (-branch-template) and then sanitizing the result so that it's a
+// valid git ref name.
+func formatBranchName(opts pullRequestOptions, branchType, timestamp string) string {
+	template := opts.branchTemplate
+	if template == "" {
+		template = defaultBranchTemplate
+	}
+	branch := strings.NewReplacer(
+		"{prefix}", opts.branchPrefix,
+		"{type}", branchType,
+		"{timestamp}", timestamp,
+		"{apiPath}", opts.apiPath,
+	).Replace(template)
+	return sanitizeBranchName(branch)
+}
+
+// Characters (and sequences) which aren't allowed in git ref names; see "git help check-ref-format".
+var invalidBranchCharactersRegex = regexp.MustCompile(`[\x00-\x20\x7f~^:?*\[\\]|\.\.|@\{`)
+var repeatedSlashesRegex = regexp.MustCompile(`/{2,}`)
+
+// Replaces characters which aren't valid in git ref names with "-", and fixes up
+// the other constraints on ref names (components must not start with "." or end
+// with ".lock", and the name must not start or end with "/" or end with ".").
+func sanitizeBranchName(branch string) string {
+	branch = invalidBranchCharactersRegex.ReplaceAllString(branch, "-")
+	branch = repeatedSlashesRegex.ReplaceAllString(branch, "/")
+	branch = strings.Trim(branch, "/")
+	components := strings.Split(branch, "/")
+	for i, component := range components {
+		component = strings.TrimPrefix(component, ".")
+		if strings.HasSuffix(component, ".lock") {
+			component = strings.TrimSuffix(component, ".lock") + "-lock"
+		}
+		components[i] = component
+	}
+	branch = strings.TrimSuffix(strings.Join(components, "/"), ".")
+	if branch == "" || branch == "@" {
+		return "librarian"
+	}
+	return branch
+}
+
+// Formats the provenance of the changes in a PR: the language repo commit which
+// was HEAD when the command started, and the container image used (and its digest). Together with
+// the API commits (recorded in each commit message) this allows the changes to be
+// reproduced. The result ends with a line break, unless it's empty.
+func formatProvenance(state *commandState) string {
+	var builder strings.Builder
+	if state.baseCommit != "" {
+		builder.WriteString(fmt.Sprintf("Librarian-Base-Commit: %s\n", state.baseCommit))
+	}
+	if state.containerConfig != nil && state.containerConfig.Image != "" {
+		builder.WriteString(fmt.Sprintf("Librarian-Image: %s\n", state.containerConfig.Image))
+	}
+	if state.imageDigest != "" {
+		builder.WriteString(fmt.Sprintf("Librarian-Image-Digest: %s\n", state.imageDigest))
+	}
+	return builder.String()
+}
+
+// GitHub rejects PR bodies longer than this. (The limit is in characters; we check
+// the length in bytes, which is never smaller.)
+const maxPullRequestBodyLength = 65536
+
+// A section of a PR description, which may have been truncated to fit within
+// maxPullRequestBodyLength. The number of entries removed is recorded so that
+// the PR can say that it's incomplete.
+type pullRequestSection struct {
+	title     string
+	list      []string
+	entries   []pullRequestEntry
+	truncated int
+}
+
+func formatPullRequestDescription(sections []*pullRequestSection, useTables bool, trailer string) string {
+	var builder strings.Builder
+	for _, section := range sections {
+		list, entries := section.list, section.entries
+		if section.truncated > 0 {
+			note := fmt.Sprintf("...%d more truncated, see run logs", section.truncated)
+			list = append(slices.Clone(list), note)
+			entries = append(slices.Clone(entries), pullRequestEntry{id: "...", status: note})
+		}
+		if useTables {
+			builder.WriteString(formatEntriesAsMarkdownTable(section.title, list, entries))
+		} else {
+			builder.WriteString(formatListAsMarkdown(section.title, list))
+		}
+	}
+	builder.WriteString(trailer)
+	return strings.TrimSpace(builder.String())
+}
+
+// Removes as few entries as possible from the end of the section for the description
+// (as returned by format) to fit within maxPullRequestBodyLength, and returns the
+// resulting description. If the description doesn't fit even with every entry removed,
+// all entries are removed.
+func truncatePullRequestSection(section *pullRequestSection, format func() string) string {
+	list, entries := section.list, section.entries
+	keep := func(count int) {
+		section.list = list[:count]
+		section.truncated = len(list) - count
+		if len(entries) == len(list) {
+			section.entries = entries[:count]
+		}
+	}
+	// Find the smallest number of entries to remove such that the description fits.
+	removed := sort.Search(len(list)+1, func(removed int) bool {
+		keep(len(list) - removed)
+		return len(format()) <= maxPullRequestBodyLength
+	})
+	keep(len(list) - min(removed, len(list)))
+	return format()
 }
 
 // Formats the given list as a single Markdown string, with a title preceding the list,
@@ -132,3 +598,38 @@ func formatListAsMarkdown(title string, list []string) string {
 	builder.WriteString("\n\n")
 	return builder.String()
 }
+
+// Formats the given entries as a single Markdown string, with a title preceding a table
+// (with library, action and status columns) in a collapsible block. If the structured
+// entries don't correspond to the textual list, the list is formatted as with
+// formatListAsMarkdown instead. If the list is empty, an empty string is returned.
+func formatEntriesAsMarkdownTable(title string, list []string, entries []pullRequestEntry) string {
+	if len(list) == 0 {
+		return ""
+	}
+	if len(entries) != len(list) {
+		return formatListAsMarkdown(title, list)
+	}
+	var builder strings.Builder
+	builder.WriteString("## ")
+	builder.WriteString(title)
+	builder.WriteString("\n\n")
+	builder.WriteString(fmt.Sprintf("<details>\n<summary>%d entries</summary>\n\n", len(entries)))
+	builder.WriteString("| Library | Action | Status |\n")
+	builder.WriteString("| --- | --- | --- |\n")
+	for _, entry := range entries {
+		id := entry.id
+		if id == "" {
+			id = "-"
+		}
+		builder.WriteString(fmt.Sprintf("| %s | %s | %s |\n", escapeMarkdownTableCell(id), escapeMarkdownTableCell(entry.action), escapeMarkdownTableCell(entry.status)))
+	}
+	builder.WriteString("\n</details>\n\n")
+	return builder.String()
+}
+
+// Escapes text so that it can be used in a single Markdown table cell.
+func escapeMarkdownTableCell(text string) string {
+	text = strings.ReplaceAll(text, "|", "\\|")
+	return strings.Join(strings.Fields(text), " ")
+}
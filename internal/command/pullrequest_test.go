@@ -0,0 +1,90 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// Run with -race to detect unsynchronized access.
+func TestPullRequestContentConcurrentAdds(t *testing.T) {
+	const workers = 50
+	const addsPerWorker = 20
+	pr := new(PullRequestContent)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < addsPerWorker; j++ {
+				id := fmt.Sprintf("library-%d-%d", i, j)
+				addSuccessToPullRequest(pr, id, "generating", "Generated "+id)
+				addErrorToPullRequest(pr, id, errors.New("failed"), "building")
+				failFastError(pr, true)
+			}
+		}()
+	}
+	wg.Wait()
+
+	want := workers * addsPerWorker
+	if len(pr.Successes) != want || len(pr.successEntries) != want {
+		t.Errorf("got %d successes and %d success entries; want %d of each", len(pr.Successes), len(pr.successEntries), want)
+	}
+	if len(pr.Errors) != want || len(pr.errorEntries) != want {
+		t.Errorf("got %d errors and %d error entries; want %d of each", len(pr.Errors), len(pr.errorEntries), want)
+	}
+	for i, entry := range pr.successEntries {
+		if pr.Successes[i] != "Generated "+entry.id {
+			t.Errorf("success %d is %q, but its entry is for %s", i, pr.Successes[i], entry.id)
+		}
+	}
+	if pr.firstError == nil {
+		t.Error("firstError not set")
+	}
+}
+
+func TestFormatBranchName(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		opts pullRequestOptions
+		want string
+	}{
+		{
+			name: "default template",
+			opts: pullRequestOptions{branchPrefix: "librarian"},
+			want: "librarian-regen-20250101T000000Z",
+		},
+		{
+			name: "custom template",
+			opts: pullRequestOptions{branchTemplate: "{prefix}/{type}/{apiPath}", branchPrefix: "bot", apiPath: "google/cloud/functions/v2"},
+			want: "bot/regen/google/cloud/functions/v2",
+		},
+		{
+			name: "sanitized",
+			opts: pullRequestOptions{branchTemplate: "{prefix}/{apiPath}..{type}", branchPrefix: ".hidden", apiPath: "a b"},
+			want: "hidden/a-b-regen",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			if got := formatBranchName(test.opts, "regen", "20250101T000000Z"); got != test.want {
+				t.Errorf("formatBranchName() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
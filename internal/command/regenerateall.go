@@ -0,0 +1,200 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"path"
+	"path/filepath"
+
+	"github.com/googleapis/librarian/internal/statepb"
+)
+
+var CmdRegenerateAll = &Command{
+	Name:  "regenerate-all",
+	Short: "Regenerate every library configured in a language repo, creating a single PR.",
+	flagFunctions: []func(fs *flag.FlagSet){
+		addFlagImage,
+		addFlagImages,
+		addFlagWorkRoot,
+		addFlagAPIRoot,
+		addFlagLanguage,
+		addFlagInclude,
+		addFlagExclude,
+		addFlagRepoRoot,
+		addFlagResetRepo,
+		addFlagRepoUrl,
+		addFlagRepoRef,
+		addFlagForce,
+		addFlagCommitMessageTemplate,
+		addFlagSSHKey,
+		addFlagCloneDepth,
+		addFlagSecretProvider,
+		addFlagSecretsDir,
+		addFlagSecretsProject,
+		addFlagVaultMount,
+		addFlagEmitMetadata,
+		addFlagValidateImage,
+		addFlagPush,
+		addFlagGitUserEmail,
+		addFlagGitUserName,
+		addFlagSignCommits,
+		addFlagSigningKey,
+		addFlagGenerateTimeout,
+		addFlagMaxOutputSize,
+		addFlagMaxConcurrency,
+		addFlagSummaryFile,
+		addFlagPull,
+		addFlagImageDigest,
+		addFlagLineEndings,
+		addFlagPostGenerateHook,
+		addFlagPrune,
+		addFlagPRAutoMerge,
+		addFlagPRAutoMergeMethod,
+		addFlagDraft,
+		addFlagPRLabel,
+		addFlagPRReviewer,
+		addFlagPRAssignee,
+		addFlagBranchPrefix,
+		addFlagBaseBranch,
+		addFlagHistoryFile,
+		addFlagBranchTemplate,
+		addFlagTitleTimezone,
+		addFlagKeepBranchOnFailure,
+		addFlagWaitForChecks,
+		addFlagChecksTimeout,
+		addFlagVerbosePRErrors,
+		addFlagFailFast,
+		addFlagUpdateExisting,
+		addFlagExistingPRAuthor,
+		addFlagExistingPRLabel,
+		addFlagContainerRetries,
+		addFlagContainerRuntime,
+		addFlagLocalGenerator,
+		addFlagRegistryConfig,
+		addFlagContainerLogs,
+		addFlagContainerEnv,
+	},
+	maybeGetLanguageRepo:    cloneOrOpenLanguageRepo,
+	maybeLoadStateAndConfig: loadRepoStateAndConfig,
+	execute:                 regenerateAll,
+	runsContainers:          true,
+}
+
+// Performs refined generation (and building) for every library in the pipeline state
+// matching -include and -exclude, as generate does for multiple API paths. Each changed
+// library is committed separately, and the commits are included in a single PR.
+func regenerateAll(state *commandState) error {
+	if err := validatePush(state); err != nil {
+		return err
+	}
+	opts := generateOptionsFromFlags()
+	if err := validateLineEndings(opts.LineEndings); err != nil {
+		return err
+	}
+	prOpts := pullRequestOptionsFromFlags()
+	if err := validatePRAutoMerge(prOpts); err != nil {
+		return err
+	}
+	if err := validateTitleTimezone(); err != nil {
+		return err
+	}
+
+	libraries := selectLibraries(state.pipelineState.Libraries, flagInclude, flagExclude)
+	if len(libraries) == 0 {
+		slog.Info("No libraries to regenerate")
+		return nil
+	}
+	// Refined generation is per library, so any of its API paths identifies it.
+	apiPaths := []string{}
+	for _, library := range libraries {
+		apiPaths = append(apiPaths, library.ApiPaths[0])
+	}
+
+	if err := maybePullImage(state, &opts); err != nil {
+		return err
+	}
+	apiRoot := flagAPIRoot
+	if apiRoot == "" {
+		apiRepo, err := cloneGoogleapis(state.workRoot)
+		if err != nil {
+			return err
+		}
+		apiRoot = apiRepo.Dir
+	}
+
+	opts.OutputDir = filepath.Join(state.workRoot, "output")
+	opts.ApiRoot = apiRoot
+	opts.ApiPaths = apiPaths
+	opts.Build = true
+	opts.CommitPerApi = true
+	opts.RequireRefined = true
+	generated, err := generate(state, opts)
+	if err != nil {
+		return err
+	}
+	results := generated.Apis
+	if err := maybeWriteGenerateSummary(state, results); err != nil {
+		return err
+	}
+
+	prContent := new(PullRequestContent)
+	unchanged := 0
+	for _, result := range results {
+		id := result.LibraryID
+		if id == "" {
+			id = result.ApiPath
+		}
+		switch {
+		case result.Err != nil:
+			addErrorToPullRequest(prContent, id, result.Err, "regenerating")
+		case result.committed:
+			addSuccessToPullRequest(prContent, id, "regenerating", result.Description)
+		default:
+			unchanged++
+		}
+	}
+	slog.Info(fmt.Sprintf("Regenerated %d libraries: %d changed, %d unchanged, %d failed", len(results), len(prContent.Successes), unchanged, len(prContent.Errors)))
+	_, err = createPullRequest(state, prOpts, prContent, "feat: Regenerate all libraries", "", "regen-all")
+	return err
+}
+
+// Returns the libraries whose IDs match any of the include patterns (or all libraries, if
+// there are none) and none of the exclude patterns, skipping those without API paths as
+// they can't be generated. The patterns have already been validated.
+func selectLibraries(libraries []*statepb.LibraryState, include, exclude []string) []*statepb.LibraryState {
+	matchesAny := func(patterns []string, libraryID string) bool {
+		for _, pattern := range patterns {
+			if matched, _ := path.Match(pattern, libraryID); matched {
+				return true
+			}
+		}
+		return false
+	}
+	selected := []*statepb.LibraryState{}
+	for _, library := range libraries {
+		if len(include) > 0 && !matchesAny(include, library.Id) || matchesAny(exclude, library.Id) {
+			continue
+		}
+		if len(library.ApiPaths) == 0 {
+			slog.Info(fmt.Sprintf("Skipping library %s, as it has no API paths", library.Id))
+			continue
+		}
+		selected = append(selected, library)
+	}
+	return selected
+}
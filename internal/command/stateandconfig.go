@@ -19,6 +19,7 @@ import (
 	"context"
 	"encoding/json"
 	"os"
+	"path"
 	"path/filepath"
 
 	"github.com/googleapis/librarian/internal/githubrepo"
@@ -36,28 +37,78 @@ func loadRepoStateAndConfig(languageRepo *gitrepo.Repo) (*statepb.PipelineState,
 	if languageRepo == nil {
 		return nil, nil, nil
 	}
-	state, err := loadRepoPipelineState(languageRepo)
+	return loadStateAndConfig(generatorInputDir(languageRepo.Dir))
+}
+
+// Loads the pipeline state and config from the given generator-input directory.
+func loadStateAndConfig(generatorInput string) (*statepb.PipelineState, *statepb.PipelineConfig, error) {
+	state, err := loadPipelineStateFile(findPipelineStateFile(generatorInput))
 	if err != nil {
 		return nil, nil, err
 	}
-	config, err := loadRepoPipelineConfig(languageRepo)
+	config, err := loadPipelineConfigFile(filepath.Join(generatorInput, pipelineConfigFile))
 	if err != nil {
 		return nil, nil, err
 	}
 	return state, config, nil
 }
 
+// Returns the directory within the language repo at repoDir which contains the pipeline
+// state and configuration, and any other generator input (see -generator-input-dir).
+func generatorInputDir(repoDir string) string {
+	return generatorInputDirNamed(repoDir, flagGeneratorInputDir)
+}
+
+// As generatorInputDir, but with the directory (relative to the repo) specified rather
+// than taken from -generator-input-dir. The default is used if dir is empty.
+func generatorInputDirNamed(repoDir, dir string) string {
+	if dir == "" {
+		dir = defaultGeneratorInputDir
+	}
+	return filepath.Join(repoDir, dir)
+}
+
+// Returns the path of the (JSON) pipeline state file within a language repo, for fetching
+// it remotely.
+func remotePipelineStatePath() string {
+	return path.Join(filepath.ToSlash(flagGeneratorInputDir), pipelineStateFile)
+}
+
+// Returns the path of the pipeline state file in the given directory: pipeline-state.json
+// if it exists, otherwise a YAML version if one exists. If there's no state file at all,
+// the path of the JSON file is returned, so that it's reported as missing.
+func findPipelineStateFile(dir string) string {
+	jsonPath := filepath.Join(dir, pipelineStateFile)
+	if _, err := os.Stat(jsonPath); err == nil {
+		return jsonPath
+	}
+	for _, name := range pipelineStateYamlFiles {
+		yamlPath := filepath.Join(dir, name)
+		if _, err := os.Stat(yamlPath); err == nil {
+			return yamlPath
+		}
+	}
+	return jsonPath
+}
+
 func loadRepoPipelineState(languageRepo *gitrepo.Repo) (*statepb.PipelineState, error) {
-	path := filepath.Join(languageRepo.Dir, "generator-input", pipelineStateFile)
-	return loadPipelineStateFile(path)
+	return loadPipelineStateFile(findPipelineStateFile(generatorInputDir(languageRepo.Dir)))
 }
 
+// Loads the pipeline state from a file, in YAML format if the file has a .yaml or .yml
+// extension and JSON format otherwise.
 func loadPipelineStateFile(path string) (*statepb.PipelineState, error) {
-	return parsePipelineState(func() ([]byte, error) { return os.ReadFile(path) })
+	return parsePipelineState(func() ([]byte, error) {
+		content, err := os.ReadFile(path)
+		if err != nil || !isYamlFile(path) {
+			return content, err
+		}
+		return yamlToJson(content)
+	})
 }
 
 func loadRepoPipelineConfig(languageRepo *gitrepo.Repo) (*statepb.PipelineConfig, error) {
-	path := filepath.Join(languageRepo.Dir, "generator-input", "pipeline-config.json")
+	path := filepath.Join(generatorInputDir(languageRepo.Dir), pipelineConfigFile)
 	return loadPipelineConfigFile(path)
 }
 
@@ -65,8 +116,10 @@ func loadPipelineConfigFile(path string) (*statepb.PipelineConfig, error) {
 	return parsePipelineConfig(func() ([]byte, error) { return os.ReadFile(path) })
 }
 
+// Saves the pipeline state to the language repo, in the same format (JSON or YAML) as
+// the existing file. Comments in a YAML file are preserved.
 func savePipelineState(state *commandState) error {
-	path := filepath.Join(state.languageRepo.Dir, "generator-input", pipelineStateFile)
+	path := findPipelineStateFile(generatorInputDir(state.languageRepo.Dir))
 	// Marshal the protobuf message as JSON...
 	unformatted, err := protojson.Marshal(state.pipelineState)
 	if err != nil {
@@ -78,15 +131,57 @@ func savePipelineState(state *commandState) error {
 	if err != nil {
 		return err
 	}
+	content := formatted.Bytes()
+	if isYamlFile(path) {
+		original, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if content, err = jsonToYaml(content, original); err != nil {
+			return err
+		}
+	}
 	// The file mode is likely to be irrelevant, given that the permissions aren't changed
 	// if the file exists, which we expect it to anyway.
-	err = os.WriteFile(path, formatted.Bytes(), os.FileMode(0644))
+	err = os.WriteFile(path, content, os.FileMode(0644))
 	return err
 }
 
 func fetchRemotePipelineState(ctx context.Context, repo githubrepo.GitHubRepo, ref string) (*statepb.PipelineState, error) {
 	return parsePipelineState(func() ([]byte, error) {
-		return githubrepo.GetRawContent(ctx, repo, "generator-input/"+pipelineStateFile, ref)
+		return getRemotePipelineStateContent(ctx, repo, ref)
+	})
+}
+
+// Fetches the pipeline state file content (as JSON) from the repo at the given ref, trying
+// the JSON file first and then the YAML alternatives.
+func getRemotePipelineStateContent(ctx context.Context, repo githubrepo.GitHubRepo, ref string) ([]byte, error) {
+	content, jsonErr := githubrepo.GetRawContent(ctx, repo, remotePipelineStatePath(), ref)
+	if jsonErr == nil {
+		return content, nil
+	}
+	for _, name := range pipelineStateYamlFiles {
+		yamlPath := path.Join(filepath.ToSlash(flagGeneratorInputDir), name)
+		if content, err := githubrepo.GetRawContent(ctx, repo, yamlPath, ref); err == nil {
+			return yamlToJson(content)
+		}
+	}
+	return nil, jsonErr
+}
+
+// As fetchRemotePipelineState, but using the state cache where possible (see
+// statecache.go). This is only suitable where slightly stale state is acceptable.
+func fetchCachedRemotePipelineState(ctx context.Context, cacheOpts stateCacheOptions, repo githubrepo.GitHubRepo, ref string) (*statepb.PipelineState, error) {
+	return parsePipelineState(func() ([]byte, error) {
+		if content := readCachedPipelineState(cacheOpts, repo, ref); content != nil {
+			return content, nil
+		}
+		content, err := getRemotePipelineStateContent(ctx, repo, ref)
+		if err != nil {
+			return nil, err
+		}
+		writeCachedPipelineState(cacheOpts, repo, ref, content)
+		return content, nil
 	})
 }
 
@@ -99,6 +194,9 @@ func parsePipelineState(contentLoader func() ([]byte, error)) (*statepb.Pipeline
 	if err := protojson.Unmarshal(bytes, state); err != nil {
 		return nil, err
 	}
+	if err := statepb.Validate(state); err != nil {
+		return nil, err
+	}
 	return state, nil
 }
 
@@ -0,0 +1,132 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/googleapis/librarian/internal/githubrepo"
+)
+
+// The default value of -state-cache-ttl.
+const defaultStateCacheTTL = 5 * time.Minute
+
+// A cached copy of the pipeline state file of a remote repo, at a given ref. The repo
+// and ref are recorded (as well as being used to derive the file name) so that entries
+// can be checked on reading.
+type stateCacheEntry struct {
+	Repo      string    `json:"repo"`
+	Ref       string    `json:"ref"`
+	FetchedAt time.Time `json:"fetchedAt"`
+	Content   []byte    `json:"content"`
+}
+
+// Configures the state cache, populated from flags by stateCacheOptionsFromFlags.
+type stateCacheOptions struct {
+	// The directory containing the cache entries.
+	dir string
+	// How long entries are used for (see -state-cache-ttl). If zero, the cache is disabled.
+	ttl time.Duration
+	// Whether to ignore existing entries, while still refreshing them (see -no-state-cache).
+	noCache bool
+}
+
+func stateCacheOptionsFromFlags() stateCacheOptions {
+	return stateCacheOptions{
+		dir:     stateCacheDir(flagWorkRoot),
+		ttl:     flagStateCacheTTL,
+		noCache: flagNoStateCache,
+	}
+}
+
+// Returns the directory containing the state cache for the given -work-root. Unless it's
+// specified, each run has its own working directory, so a shared directory is used instead.
+func stateCacheDir(workRoot string) string {
+	if workRoot != "" {
+		return filepath.Join(workRoot, "state-cache")
+	}
+	return filepath.Join(os.TempDir(), "librarian-state-cache")
+}
+
+// Returns the name of the repo used in the cache, including the GitHub base URL so that
+// repos with the same name on different GitHub instances don't collide.
+func cachedRepoName(repo githubrepo.GitHubRepo) string {
+	return fmt.Sprintf("%s%s/%s", githubrepo.BaseUrl(), repo.Owner, repo.Name)
+}
+
+func stateCachePath(dir, repoName, ref string) string {
+	hash := sha256.Sum256([]byte(repoName + "@" + ref + ":" + remotePipelineStatePath()))
+	return filepath.Join(dir, hex.EncodeToString(hash[:])+".json")
+}
+
+// Returns the cached pipeline state file content for the repo at the given ref, or nil
+// if caching is disabled or there's no unexpired cache entry. Problems reading the cache
+// are logged, but otherwise treated as a cache miss.
+func readCachedPipelineState(opts stateCacheOptions, repo githubrepo.GitHubRepo, ref string) []byte {
+	if opts.noCache || opts.ttl <= 0 {
+		return nil
+	}
+	repoName := cachedRepoName(repo)
+	path := stateCachePath(opts.dir, repoName, ref)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn(fmt.Sprintf("Unable to read state cache entry %s: %s", path, err))
+		}
+		return nil
+	}
+	entry := &stateCacheEntry{}
+	if err := json.Unmarshal(data, entry); err != nil {
+		slog.Warn(fmt.Sprintf("Ignoring invalid state cache entry %s: %s", path, err))
+		return nil
+	}
+	if entry.Repo != repoName || entry.Ref != ref {
+		return nil
+	}
+	age := time.Since(entry.FetchedAt)
+	if age > opts.ttl {
+		return nil
+	}
+	slog.Info(fmt.Sprintf("Using pipeline state for %s at %s cached %s ago", repoName, ref, age.Round(time.Second)))
+	return entry.Content
+}
+
+// Writes the pipeline state file content for the repo at the given ref to the cache,
+// unless caching is disabled with a zero TTL. (With -no-state-cache, the cache is still
+// refreshed.) Failure to write the cache is logged, but otherwise ignored.
+func writeCachedPipelineState(opts stateCacheOptions, repo githubrepo.GitHubRepo, ref string, content []byte) {
+	if opts.ttl <= 0 {
+		return
+	}
+	repoName := cachedRepoName(repo)
+	entry := &stateCacheEntry{Repo: repoName, Ref: ref, FetchedAt: time.Now(), Content: content}
+	data, err := json.Marshal(entry)
+	if err == nil {
+		err = os.MkdirAll(opts.dir, 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(stateCachePath(opts.dir, repoName, ref), data, 0644)
+	}
+	if err != nil {
+		slog.Warn(fmt.Sprintf("Unable to write state cache entry for %s at %s: %s", repoName, ref, err))
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/googleapis/librarian/internal/githubrepo"
+)
+
+func TestReadCachedPipelineState(t *testing.T) {
+	repo := githubrepo.GitHubRepo{Owner: "owner", Name: "repo"}
+	tests := []struct {
+		name      string
+		ttl       time.Duration
+		noCache   bool
+		age       time.Duration
+		entryRepo string // Empty for the repo being read
+		entryRef  string // Empty for the ref being read
+		missing   bool
+		want      string // Empty for a cache miss
+	}{
+		{name: "fresh", ttl: 5 * time.Minute, age: time.Minute, want: "content"},
+		{name: "expired", ttl: 5 * time.Minute, age: 10 * time.Minute},
+		{name: "zero TTL", ttl: 0, age: time.Minute},
+		{name: "no state cache", ttl: 5 * time.Minute, noCache: true, age: time.Minute},
+		{name: "missing", ttl: 5 * time.Minute, missing: true},
+		{name: "other repo", ttl: 5 * time.Minute, age: time.Minute, entryRepo: "https://github.com/owner/other"},
+		{name: "other ref", ttl: 5 * time.Minute, age: time.Minute, entryRef: "other"},
+	}
+	for _, test := range tests {
+		opts := stateCacheOptions{dir: t.TempDir(), ttl: test.ttl, noCache: test.noCache}
+		if !test.missing {
+			entry := stateCacheEntry{Repo: cachedRepoName(repo), Ref: "main", FetchedAt: time.Now().Add(-test.age), Content: []byte("content")}
+			if test.entryRepo != "" {
+				entry.Repo = test.entryRepo
+			}
+			if test.entryRef != "" {
+				entry.Ref = test.entryRef
+			}
+			data, err := json.Marshal(entry)
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := stateCachePath(opts.dir, cachedRepoName(repo), "main")
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := string(readCachedPipelineState(opts, repo, "main")); got != test.want {
+			t.Errorf("readCachedPipelineState(%s) expected %q, got %q", test.name, test.want, got)
+		}
+	}
+}
+
+func TestStateCachePath(t *testing.T) {
+	const dir, repoName, ref = "cache", "https://github.com/owner/repo", "main"
+	tests := []struct {
+		name     string
+		repoName string
+		ref      string
+		same     bool
+	}{
+		{name: "same repo and ref", repoName: repoName, ref: ref, same: true},
+		{name: "other ref", repoName: repoName, ref: "other"},
+		{name: "other repo", repoName: "https://github.com/owner/other", ref: ref},
+		{name: "other GitHub instance", repoName: "https://github.example.com/owner/repo", ref: ref},
+		{name: "ambiguous concatenation", repoName: repoName + "@main", ref: ""},
+	}
+	for _, test := range tests {
+		same := stateCachePath(dir, test.repoName, test.ref) == stateCachePath(dir, repoName, ref)
+		if same != test.same {
+			t.Errorf("stateCachePath(%s) expected same path as %s@%s: %t, got %t", test.name, repoName, ref, test.same, same)
+		}
+	}
+}
+
+func TestWriteCachedPipelineState(t *testing.T) {
+	repo := githubrepo.GitHubRepo{Owner: "owner", Name: "repo"}
+	tests := []struct {
+		name    string
+		ttl     time.Duration
+		noCache bool
+		want    string // Empty if nothing should be cached
+	}{
+		{name: "cached", ttl: 5 * time.Minute, want: "content"},
+		{name: "zero TTL", ttl: 0},
+		// The cache is still refreshed, for use by later runs.
+		{name: "no state cache", ttl: 5 * time.Minute, noCache: true, want: "content"},
+	}
+	for _, test := range tests {
+		opts := stateCacheOptions{dir: filepath.Join(t.TempDir(), "state-cache"), ttl: test.ttl, noCache: test.noCache}
+		writeCachedPipelineState(opts, repo, "main", []byte("content"))
+		opts.noCache = false
+		if got := string(readCachedPipelineState(opts, repo, "main")); got != test.want {
+			t.Errorf("writeCachedPipelineState(%s) expected %q to be cached, got %q", test.name, test.want, got)
+		}
+	}
+}
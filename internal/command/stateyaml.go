@@ -0,0 +1,159 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The pipeline state may be maintained as YAML (which is easier to edit by hand, and
+// allows comments) instead of JSON. The YAML has the same structure as the JSON; it's
+// converted to JSON on loading, and back to YAML on saving, with the comments of the
+// original YAML carried over to the corresponding nodes.
+
+// The alternative names of the pipeline state file, in YAML format. The JSON file
+// takes precedence if multiple files exist.
+var pipelineStateYamlFiles = []string{"pipeline-state.yaml", "pipeline-state.yml"}
+
+// Reports whether the file at the given path is in YAML format, based on its extension.
+func isYamlFile(path string) bool {
+	extension := strings.ToLower(filepath.Ext(path))
+	return extension == ".yaml" || extension == ".yml"
+}
+
+// Converts YAML content to the equivalent JSON, for parsing with protojson.
+func yamlToJson(content []byte) ([]byte, error) {
+	var value interface{}
+	if err := yaml.Unmarshal(content, &value); err != nil {
+		return nil, fmt.Errorf("failed to parse YAML: %w", err)
+	}
+	return json.Marshal(value)
+}
+
+// Converts JSON content to the equivalent YAML, preserving the order of fields. Every
+// node is formatted in YAML's default style (block style, with scalars only quoted where
+// necessary) rather than the JSON style in which it was parsed. If original YAML content
+// is provided (e.g. the file being overwritten), its comments are copied to the
+// corresponding nodes of the new YAML.
+func jsonToYaml(content, original []byte) ([]byte, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(content, &node); err != nil {
+		return nil, err
+	}
+	resetYamlStyle(&node)
+	if original != nil {
+		var originalNode yaml.Node
+		if err := yaml.Unmarshal(original, &originalNode); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML: %w", err)
+		}
+		copyYamlComments(&node, &originalNode)
+	}
+	var buffer bytes.Buffer
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// Copies the comments of the source node, and recursively of its descendants, to the
+// corresponding nodes of the destination. Mapping entries correspond if they have the
+// same key. Sequence elements correspond if they're mappings with the same "id" (as
+// libraries are identified), or otherwise if they're at the same index.
+func copyYamlComments(destination, source *yaml.Node) {
+	destination.HeadComment = source.HeadComment
+	destination.LineComment = source.LineComment
+	destination.FootComment = source.FootComment
+	if destination.Kind != source.Kind {
+		return
+	}
+	switch destination.Kind {
+	case yaml.DocumentNode:
+		if len(destination.Content) > 0 && len(source.Content) > 0 {
+			copyYamlComments(destination.Content[0], source.Content[0])
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(destination.Content); i += 2 {
+			key := destination.Content[i]
+			if j := yamlMappingKeyIndex(source, key.Value); j >= 0 {
+				copyYamlComments(key, source.Content[j])
+				copyYamlComments(destination.Content[i+1], source.Content[j+1])
+			}
+		}
+	case yaml.SequenceNode:
+		for i, element := range destination.Content {
+			if match := findCorrespondingYamlElement(source, element, i); match != nil {
+				copyYamlComments(element, match)
+			}
+		}
+	}
+}
+
+// Returns the index of the key node with the given value in a mapping node, or -1 if
+// there's no such key.
+func yamlMappingKeyIndex(mapping *yaml.Node, key string) int {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// Returns the element of a source sequence node corresponding to the given element (at
+// the given index) of the destination sequence, or nil if there's none.
+func findCorrespondingYamlElement(source, element *yaml.Node, index int) *yaml.Node {
+	if id := yamlMappingValue(element, "id"); id != "" {
+		for _, candidate := range source.Content {
+			if yamlMappingValue(candidate, "id") == id {
+				return candidate
+			}
+		}
+		return nil
+	}
+	if index < len(source.Content) {
+		return source.Content[index]
+	}
+	return nil
+}
+
+// Returns the value of the given key in a mapping node, if the node is a mapping and
+// the value is a scalar, or the empty string otherwise.
+func yamlMappingValue(node *yaml.Node, key string) string {
+	if node.Kind != yaml.MappingNode {
+		return ""
+	}
+	if i := yamlMappingKeyIndex(node, key); i >= 0 && node.Content[i+1].Kind == yaml.ScalarNode {
+		return node.Content[i+1].Value
+	}
+	return ""
+}
+
+func resetYamlStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetYamlStyle(child)
+	}
+}
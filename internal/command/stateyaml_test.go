@@ -0,0 +1,86 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/googleapis/librarian/internal/gitrepo"
+	"github.com/googleapis/librarian/internal/statepb"
+)
+
+const commentedPipelineStateYaml = `# The state of the pipeline for this repo.
+imageTag: v1 # Updated by update-image-tag.
+libraries:
+  # The bar library is generated by hand.
+  - id: bar
+    currentVersion: 1.0.0
+  - id: foo # The foo library.
+    currentVersion: 2.0.0
+`
+
+func TestSavePipelineStatePreservesYamlComments(t *testing.T) {
+	defer func(original string) { flagGeneratorInputDir = original }(flagGeneratorInputDir)
+	flagGeneratorInputDir = ""
+	repoDir := t.TempDir()
+	inputDir := generatorInputDir(repoDir)
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(inputDir, "pipeline-state.yaml")
+	if err := os.WriteFile(path, []byte(commentedPipelineStateYaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	languageRepo := &gitrepo.Repo{Dir: repoDir}
+	pipelineState, err := loadRepoPipelineState(languageRepo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Change a value, and reorder the libraries so that comments have to follow their IDs.
+	pipelineState.ImageTag = "v2"
+	pipelineState.Libraries = []*statepb.LibraryState{pipelineState.Libraries[1], pipelineState.Libraries[0]}
+	if err := savePipelineState(&commandState{languageRepo: languageRepo, pipelineState: pipelineState}); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := string(content)
+	expected := []string{
+		"# The state of the pipeline for this repo.\n",
+		"imageTag: v2 # Updated by update-image-tag.\n",
+		"- id: foo # The foo library.\n",
+		"# The bar library is generated by hand.\n  - id: bar\n",
+	}
+	for _, text := range expected {
+		if !strings.Contains(saved, text) {
+			t.Errorf("savePipelineState expected saved YAML to contain %q, got:\n%s", text, saved)
+		}
+	}
+	if !strings.Contains(saved, "id: foo") || strings.Index(saved, "id: foo") > strings.Index(saved, "id: bar") {
+		t.Errorf("savePipelineState expected foo before bar, got:\n%s", saved)
+	}
+	reloaded, err := loadRepoPipelineState(languageRepo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.ImageTag != "v2" || len(reloaded.Libraries) != 2 || reloaded.Libraries[0].Id != "foo" {
+		t.Errorf("loadRepoPipelineState expected the saved state, got %v", reloaded)
+	}
+}
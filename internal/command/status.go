@@ -0,0 +1,101 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/googleapis/librarian/internal/gitrepo"
+	"github.com/googleapis/librarian/internal/statepb"
+)
+
+var CmdStatus = &Command{
+	Name:  "status",
+	Short: "Report how an API is configured for generation in a language repo.",
+	flagFunctions: []func(fs *flag.FlagSet){
+		addFlagAPIPath,
+		addFlagRepoRoot,
+		addFlagRepoUrl,
+		addFlagRepoRef,
+		addFlagStateCacheTTL,
+		addFlagNoStateCache,
+	},
+	maybeGetLanguageRepo: func(ctx context.Context, workRoot string) (*gitrepo.Repo, error) {
+		return nil, nil
+	},
+	maybeLoadStateAndConfig: func(languageRepo *gitrepo.Repo) (*statepb.PipelineState, *statepb.PipelineConfig, error) {
+		return nil, nil, nil
+	},
+	execute: reportStatus,
+}
+
+func reportStatus(state *commandState) error {
+	if err := validateRequiredFlag("api-path", flagAPIPath); err != nil {
+		return err
+	}
+	// This loads the state in the same way as generate does when deciding whether
+	// to clone the language repo, but nothing is cloned or generated.
+	pipelineState, err := loadConfiguredPipelineState(state.ctx, languageRepoOptionsFromFlags())
+	if err != nil {
+		return err
+	}
+	for _, apiPath := range parseAPIPaths(flagAPIPath) {
+		if err := writeApiStatus(os.Stdout, pipelineState, apiPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Writes the status of a single API path. A nil pipeline state indicates that no
+// repo was specified, in which case generate would always perform raw generation.
+func writeApiStatus(w io.Writer, pipelineState *statepb.PipelineState, apiPath string) error {
+	libraryID := ""
+	if pipelineState != nil {
+		var err error
+		if libraryID, err = findLibraryIDByApiPath(pipelineState, apiPath); err != nil {
+			return err
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "API path:\t%s\n", apiPath)
+	switch {
+	case pipelineState == nil:
+		fmt.Fprintf(tw, "Configured:\tunknown (no repo specified)\n")
+	case libraryID == "":
+		fmt.Fprintf(tw, "Configured:\tno\n")
+	default:
+		library := findLibraryByID(pipelineState, libraryID)
+		lastGeneratedCommit := library.LastGeneratedCommit
+		if lastGeneratedCommit == "" {
+			lastGeneratedCommit = "(none recorded)"
+		}
+		fmt.Fprintf(tw, "Configured:\tyes\n")
+		fmt.Fprintf(tw, "Library ID:\t%s\n", libraryID)
+		fmt.Fprintf(tw, "Last generated commit:\t%s\n", lastGeneratedCommit)
+	}
+	if libraryID != "" {
+		fmt.Fprintf(tw, "Generation mode:\trefined\n")
+	} else {
+		fmt.Fprintf(tw, "Generation mode:\traw\n")
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,155 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/googleapis/librarian/internal/container"
+	"github.com/googleapis/librarian/internal/tracing"
+	"github.com/googleapis/librarian/internal/utils"
+)
+
+// The current version of the GenerateSummary schema. This must be incremented
+// whenever a field is removed or its meaning changes; adding fields doesn't
+// require a new version.
+const generateSummarySchemaVersion = 1
+
+// Generation modes, as reported in GenerateSummaryApi.
+const (
+	generationModeRefined = "refined"
+	generationModeRaw     = "raw"
+)
+
+// GenerateSummary is the machine-readable summary of a generate run, written
+// to the file specified with -summary-file.
+type GenerateSummary struct {
+	// SchemaVersion is the version of this schema; see generateSummarySchemaVersion.
+	SchemaVersion int `json:"schemaVersion"`
+	// WorkRoot is the working directory for the run.
+	WorkRoot string `json:"workRoot"`
+	// Image is the language container image used for generation.
+	Image string `json:"image"`
+	// ImageDigest is the digest of the image, if known.
+	ImageDigest string `json:"imageDigest,omitempty"`
+	// BaseCommit is the HEAD commit of the language repo when the run started, if any.
+	BaseCommit string `json:"baseCommit,omitempty"`
+	// StartTime is when the run started, in RFC 3339 format.
+	StartTime string `json:"startTime"`
+	// DryRun indicates whether this was a dry run, in which nothing was generated.
+	DryRun bool `json:"dryRun"`
+	// Apis contains the result for each API path, sorted by API path.
+	Apis []GenerateSummaryApi `json:"apis"`
+	// Phases contains the timing of each phase of the run completed before the summary
+	// was written (including each container invocation), in the order they completed.
+	Phases []GenerateSummaryPhase `json:"phases"`
+}
+
+// GenerateSummaryPhase is the timing of a single phase of a run, within a GenerateSummary.
+type GenerateSummaryPhase struct {
+	// Name is the name of the phase, e.g. "clone" or "container build-library", followed by its
+	// attributes (such as the image and library ID) in parentheses.
+	Name string `json:"name"`
+	// DurationSeconds is the time taken by the phase.
+	DurationSeconds float64 `json:"durationSeconds"`
+	// Success indicates whether the phase succeeded.
+	Success bool `json:"success"`
+}
+
+// GenerateSummaryApi is the result of generating a single API path, within a GenerateSummary.
+type GenerateSummaryApi struct {
+	// ApiPath is the API path, as specified with -api-path.
+	ApiPath string `json:"apiPath"`
+	// Mode is either "refined" or "raw".
+	Mode string `json:"mode"`
+	// LibraryID is the ID of the library, for refined generation.
+	LibraryID string `json:"libraryId,omitempty"`
+	// OutputDir is the directory into which the API was generated.
+	OutputDir string `json:"outputDir"`
+	// DurationSeconds is the time taken to generate (and build, if requested) the API.
+	DurationSeconds float64 `json:"durationSeconds"`
+	// Success indicates whether generation (and building, if requested) succeeded.
+	Success bool `json:"success"`
+	// Error is the error message if generation failed.
+	Error string `json:"error,omitempty"`
+	// Skipped indicates that generation was skipped, as the library's inputs were
+	// unchanged since it was last generated.
+	Skipped bool `json:"skipped,omitempty"`
+	// ExitCode is the exit code of the container, if generation (or building) failed
+	// because a container exited with a non-zero exit code.
+	ExitCode int `json:"exitCode,omitempty"`
+	// Warnings describes problems which didn't prevent generation, such as
+	// falling back to raw generation.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Writes a GenerateSummary for the given results to the file specified with -summary-file,
+// if any.
+func maybeWriteGenerateSummary(state *commandState, results []GenerateApiResult) error {
+	if flagSummaryFile == "" {
+		return nil
+	}
+	summary := GenerateSummary{
+		SchemaVersion: generateSummarySchemaVersion,
+		WorkRoot:      state.workRoot,
+		Image:         state.containerConfig.Image,
+		ImageDigest:   state.imageDigest,
+		BaseCommit:    state.baseCommit,
+		StartTime:     state.startTime.UTC().Format(time.RFC3339),
+		DryRun:        flagDryRun,
+		Apis:          []GenerateSummaryApi{},
+		Phases:        []GenerateSummaryPhase{},
+	}
+	for _, timing := range tracing.PhaseTimings() {
+		summary.Phases = append(summary.Phases, GenerateSummaryPhase{
+			Name:            timing.Name,
+			DurationSeconds: timing.Duration.Seconds(),
+			Success:         !timing.Failed,
+		})
+	}
+	for _, result := range results {
+		api := GenerateSummaryApi{
+			ApiPath:         result.ApiPath,
+			Mode:            generationModeRaw,
+			LibraryID:       result.LibraryID,
+			OutputDir:       result.OutputDir,
+			DurationSeconds: result.Duration.Seconds(),
+			Success:         result.Err == nil,
+			Skipped:         result.Skipped,
+			Warnings:        result.Warnings,
+		}
+		if result.LibraryID != "" {
+			api.Mode = generationModeRefined
+		}
+		if result.Err != nil {
+			api.Error = result.Err.Error()
+			var exitErr *container.ExitError
+			if errors.As(result.Err, &exitErr) {
+				api.ExitCode = exitErr.ExitCode
+			}
+		}
+		summary.Apis = append(summary.Apis, api)
+	}
+	data, err := json.MarshalIndent(summary, "", "    ")
+	if err != nil {
+		return err
+	}
+	slog.Info(fmt.Sprintf("Writing generation summary to %s", flagSummaryFile))
+	return utils.CreateAndWriteBytesToFile(flagSummaryFile, data)
+}
@@ -33,32 +33,90 @@ var CmdUpdateApis = &Command{
 	Short: "Regenerate APIs in a language repo with new specifications.",
 	flagFunctions: []func(fs *flag.FlagSet){
 		addFlagImage,
+		addFlagImages,
 		addFlagWorkRoot,
 		addFlagAPIRoot,
 		addFlagBranch,
 		addFlagGitUserEmail,
 		addFlagGitUserName,
+		addFlagSignCommits,
+		addFlagSigningKey,
 		addFlagLanguage,
 		addFlagLibraryID,
 		addFlagPush,
+		addFlagForkRepo,
 		addFlagRepoRoot,
+		addFlagResetRepo,
 		addFlagRepoUrl,
+		addFlagSSHKey,
+		addFlagCloneDepth,
+		addFlagSecretProvider,
+		addFlagSecretsDir,
 		addFlagSecretsProject,
+		addFlagVaultMount,
+		addFlagMirrorRepoUrl,
+		addFlagEmitMetadata,
+		addFlagValidateImage,
+		addFlagLineEndings,
+		addFlagPostGenerateHook,
+		addFlagPrune,
+		addFlagPRAutoMerge,
+		addFlagPRAutoMergeMethod,
+		addFlagDraft,
+		addFlagPRLabel,
+		addFlagPRReviewer,
+		addFlagPRAssignee,
+		addFlagBranchPrefix,
+		addFlagBaseBranch,
+		addFlagHistoryFile,
+		addFlagBranchTemplate,
+		addFlagCommitMessageTemplate,
+		addFlagSquash,
+		addFlagTitleTimezone,
+		addFlagKeepBranchOnFailure,
+		addFlagWaitForChecks,
+		addFlagChecksTimeout,
+		addFlagVerbosePRErrors,
+		addFlagFailFast,
+		addFlagUpdateExisting,
+		addFlagExistingPRAuthor,
+		addFlagExistingPRLabel,
+		addFlagContainerRetries,
+		addFlagContainerRuntime,
+		addFlagLocalGenerator,
+		addFlagRegistryConfig,
+		addFlagContainerLogs,
+		addFlagContainerEnv,
 	},
 	maybeGetLanguageRepo:    cloneOrOpenLanguageRepo,
 	maybeLoadStateAndConfig: loadRepoStateAndConfig,
 	execute:                 updateAPIs,
+	runsContainers:          true,
 }
 
 func updateAPIs(state *commandState) error {
-	if err := validatePush(); err != nil {
+	if err := validatePush(state); err != nil {
+		return err
+	}
+	if err := validateLineEndings(flagLineEndings); err != nil {
+		return err
+	}
+	prOpts := pullRequestOptionsFromFlags()
+	if err := validatePRAutoMerge(prOpts); err != nil {
+		return err
+	}
+	if err := validateTitleTimezone(); err != nil {
+		return err
+	}
+
+	includeRoots, err := resolveAPIIncludeRoots(flagAPIIncludeRoots)
+	if err != nil {
 		return err
 	}
 
 	var apiRepo *gitrepo.Repo
 	cleanWorkingTreePostGeneration := true
 	if flagAPIRoot == "" {
-		var err error
 		apiRepo, err = cloneGoogleapis(state.workRoot)
 		if err != nil {
 			return err
@@ -95,24 +153,39 @@ func updateAPIs(state *commandState) error {
 		return err
 	}
 
-	prContent := new(PullRequestContent)
+	prContent := &PullRequestContent{squash: flagSquash}
+	// Successfully-generated output is also committed to the mirror repo, if there is one.
+	var mirrorDirs, mirrorDescriptions []string
 	// Perform "generate, clean, commit, build" on each library.
 	for _, library := range state.pipelineState.Libraries {
-		err := updateLibrary(state, apiRepo, outputDir, library, prContent)
+		previousSuccesses := len(prContent.Successes)
+		err := updateLibrary(state, apiRepo, includeRoots, outputDir, library, prContent)
 		if err != nil {
 			return err
 		}
+		if err := failFastError(prContent, prOpts.failFast); err != nil {
+			return err
+		}
+		if err := cancelledError(state.ctx); err != nil {
+			return err
+		}
+		if len(prContent.Successes) > previousSuccesses {
+			mirrorDirs = append(mirrorDirs, filepath.Join(outputDir, library.Id))
+			mirrorDescriptions = append(mirrorDescriptions, fmt.Sprintf("feat: Regenerate %s", library.Id))
+		}
 	}
 
 	// Clean  the API repo in case it was changed, but not if it was already dirty before the command.
 	if cleanWorkingTreePostGeneration {
 		gitrepo.CleanWorkingTree(apiRepo)
 	}
-	_, err := createPullRequest(state, prContent, "feat: API regeneration", "", "regen")
-	return err
+	if _, err := createPullRequest(state, prOpts, prContent, "feat: API regeneration", "", "regen"); err != nil {
+		return err
+	}
+	return pushToMirrorRepo(state, prOpts, flagLineEndings, mirrorDirs, mirrorDescriptions, "feat: API regeneration", "regen")
 }
 
-func updateLibrary(state *commandState, apiRepo *gitrepo.Repo, outputRoot string, library *statepb.LibraryState, prContent *PullRequestContent) error {
+func updateLibrary(state *commandState, apiRepo *gitrepo.Repo, includeRoots []string, outputRoot string, library *statepb.LibraryState, prContent *PullRequestContent) error {
 	containerConfig := state.containerConfig
 	languageRepo := state.languageRepo
 
@@ -155,15 +228,38 @@ func updateLibrary(state *commandState, apiRepo *gitrepo.Repo, outputRoot string
 	// We could potentially just keep a single copy and update it, but it's clearer diagnostically if we can tell
 	// what state we passed into the container.
 	generatorInput := filepath.Join(state.workRoot, "generator-input", library.Id)
-	if err := os.CopyFS(generatorInput, os.DirFS(filepath.Join(state.languageRepo.Dir, "generator-input"))); err != nil {
+	if err := os.CopyFS(generatorInput, os.DirFS(generatorInputDir(state.languageRepo.Dir))); err != nil {
 		return err
 	}
 
-	if err := container.GenerateLibrary(containerConfig, apiRepo.Dir, outputDir, generatorInput, library.Id); err != nil {
+	if err := container.GenerateLibrary(state.ctx, containerConfig, apiRepo.Dir, includeRoots, outputDir, generatorInput, library.Id); err != nil {
+		addErrorToPullRequest(prContent, library.Id, err, "generating")
+		return nil
+	}
+	if err := checkGeneratorOutput(outputDir, library.Id); err != nil {
 		addErrorToPullRequest(prContent, library.Id, err, "generating")
 		return nil
 	}
-	if err := container.Clean(containerConfig, languageRepo.Dir, library.Id); err != nil {
+	if err := maybePostProcess(state, generatorInput, outputDir, library.Id); err != nil {
+		addErrorToPullRequest(prContent, library.Id, err, "post-processing")
+		return nil
+	}
+	if err := maybeRunPostGenerateHook(state.ctx, flagPostGenerateHook, outputDir, library.Id); err != nil {
+		addErrorToPullRequest(prContent, library.Id, err, "generating")
+		return nil
+	}
+	if flagEmitMetadata {
+		if err := emitLibraryMetadata(state, apiRepo.Dir, outputDir, flagBaseBranch, library); err != nil {
+			return err
+		}
+	}
+	if flagValidateImage != "" {
+		if err := container.Validate(state.ctx, containerConfig, outputDir, library.Id); err != nil {
+			addErrorToPullRequest(prContent, library.Id, err, "validating")
+			return nil
+		}
+	}
+	if err := cleanLibrary(state.ctx, state, languageRepo.Dir, library.Id); err != nil {
 		addErrorToPullRequest(prContent, library.Id, err, "cleaning")
 		// Clean up any changes before starting the next iteration.
 		if err := gitrepo.CleanWorkingTree(languageRepo); err != nil {
@@ -171,9 +267,14 @@ func updateLibrary(state *commandState, apiRepo *gitrepo.Repo, outputRoot string
 		}
 		return nil
 	}
-	if err := os.CopyFS(languageRepo.Dir, os.DirFS(outputDir)); err != nil {
+	if err := copyGeneratedCode(languageRepo.Dir, outputDir, library, flagLineEndings); err != nil {
 		return err
 	}
+	if flagPrune {
+		if err := pruneGeneratedCode(languageRepo.Dir, outputDir, library); err != nil {
+			return err
+		}
+	}
 
 	library.LastGeneratedCommit = commits[0].Hash.String()
 	if err := savePipelineState(state); err != nil {
@@ -191,16 +292,16 @@ func updateLibrary(state *commandState, apiRepo *gitrepo.Repo, outputRoot string
 		// changes separately.
 		msg = fmt.Sprintf("feat: Initial generation for %s", library.Id)
 	} else {
-		msg = createCommitMessage(library.Id, commits)
+		msg = createCommitMessage(state, library, commits)
 	}
-	if err := commitAll(languageRepo, msg); err != nil {
+	if err := commitAll(state, languageRepo, msg); err != nil {
 		return err
 	}
 
 	// Once we've committed, we can build - but then check that nothing has changed afterwards.
 	// We consider a "something changed" error as fatal, whereas a build error just needs to
 	// undo the commit, report the failure and continue
-	buildErr := container.BuildLibrary(containerConfig, languageRepo.Dir, library.Id)
+	buildErr := container.BuildLibrary(state.ctx, containerConfig, languageRepo.Dir, library.Id)
 	clean, err := gitrepo.IsClean(languageRepo)
 	if err != nil {
 		return err
@@ -210,23 +311,38 @@ func updateLibrary(state *commandState, apiRepo *gitrepo.Repo, outputRoot string
 	}
 
 	if buildErr != nil {
-		addErrorToPullRequest(prContent, library.Id, err, "building")
+		addErrorToPullRequest(prContent, library.Id, buildErr, "building")
 		if err = gitrepo.CleanAndRevertHeadCommit(languageRepo); err != nil {
 			return err
 		}
 		return nil
 	}
 
-	addSuccessToPullRequest(prContent, fmt.Sprintf("Generated %s", library.Id))
+	addSuccessToPullRequest(prContent, library.Id, "generating", fmt.Sprintf("Generated %s", library.Id))
 	return nil
 }
 
-func createCommitMessage(libraryID string, commits []object.Commit) string {
+// Expands the placeholders in a commit message template (see -commit-message-template)
+// for a library's regeneration commit.
+func formatCommitTitle(state *commandState, template string, library *statepb.LibraryState, apiCommit string) string {
+	return strings.NewReplacer(
+		"{libraryId}", library.Id,
+		"{apiPath}", strings.Join(library.ApiPaths, ", "),
+		"{apiCommit}", apiCommit,
+		"{timestamp}", formatTimestamp(state.startTime),
+	).Replace(template)
+}
+
+func createCommitMessage(state *commandState, library *statepb.LibraryState, commits []object.Commit) string {
 	const PiperPrefix = "PiperOrigin-RevId: "
 	var builder strings.Builder
 
 	// Start the commit with a line on its own saying what's being regenerated.
-	builder.WriteString(fmt.Sprintf("regen: Regenerate %s at API commit %s", libraryID, commits[0].Hash.String()[0:7]))
+	template := flagCommitMessageTemplate
+	if template == "" {
+		template = defaultCommitMessageTemplate
+	}
+	builder.WriteString(formatCommitTitle(state, template, library, commits[0].Hash.String()[0:7]))
 	builder.WriteString("\n")
 	builder.WriteString("\n")
 
@@ -36,29 +36,80 @@ var CmdUpdateImageTag = &Command{
 		addFlagBranch,
 		addFlagGitUserEmail,
 		addFlagGitUserName,
+		addFlagSignCommits,
+		addFlagSigningKey,
 		addFlagLanguage,
 		addFlagPush,
+		addFlagForkRepo,
 		addFlagRepoRoot,
+		addFlagResetRepo,
 		addFlagRepoUrl,
+		addFlagSSHKey,
+		addFlagCloneDepth,
+		addFlagSecretProvider,
+		addFlagSecretsDir,
 		addFlagSecretsProject,
+		addFlagVaultMount,
 		addFlagTag,
+		addFlagLineEndings,
+		addFlagPostGenerateHook,
+		addFlagPrune,
+		addFlagPRAutoMerge,
+		addFlagPRAutoMergeMethod,
+		addFlagDraft,
+		addFlagPRLabel,
+		addFlagPRReviewer,
+		addFlagPRAssignee,
+		addFlagBranchPrefix,
+		addFlagBaseBranch,
+		addFlagHistoryFile,
+		addFlagBranchTemplate,
+		addFlagTitleTimezone,
+		addFlagKeepBranchOnFailure,
+		addFlagWaitForChecks,
+		addFlagChecksTimeout,
+		addFlagVerbosePRErrors,
+		addFlagUpdateExisting,
+		addFlagExistingPRAuthor,
+		addFlagExistingPRLabel,
+		addFlagContainerRetries,
+		addFlagContainerRuntime,
+		addFlagLocalGenerator,
+		addFlagRegistryConfig,
+		addFlagContainerLogs,
+		addFlagContainerEnv,
 	},
 	maybeGetLanguageRepo:    cloneOrOpenLanguageRepo,
 	maybeLoadStateAndConfig: loadRepoStateAndConfig,
 	execute:                 updateImageTag,
+	runsContainers:          true,
 }
 
 func updateImageTag(state *commandState) error {
-	if err := validatePush(); err != nil {
+	if err := validatePush(state); err != nil {
+		return err
+	}
+	if err := validateLineEndings(flagLineEndings); err != nil {
+		return err
+	}
+	prOpts := pullRequestOptionsFromFlags()
+	if err := validatePRAutoMerge(prOpts); err != nil {
+		return err
+	}
+	if err := validateTitleTimezone(); err != nil {
 		return err
 	}
 	if err := validateRequiredFlag("tag", flagTag); err != nil {
 		return err
 	}
 
+	includeRoots, err := resolveAPIIncludeRoots(flagAPIIncludeRoots)
+	if err != nil {
+		return err
+	}
+
 	var apiRepo *gitrepo.Repo
 	if flagAPIRoot == "" {
-		var err error
 		apiRepo, err = cloneGoogleapis(state.workRoot)
 		if err != nil {
 			return err
@@ -97,18 +148,22 @@ func updateImageTag(state *commandState) error {
 	}
 	// Derive the new image to use, and save it in the context.
 	ps.ImageTag = flagTag
-	state.containerConfig.Image = deriveImage(ps)
+	image, err := deriveImage(ps)
+	if err != nil {
+		return err
+	}
+	state.containerConfig.Image = image
 	savePipelineState(state)
 
 	// Take a defensive copy of the generator input directory from the language repo.
 	generatorInput := filepath.Join(state.workRoot, "generator-input")
-	if err := os.CopyFS(generatorInput, os.DirFS(filepath.Join(languageRepo.Dir, "generator-input"))); err != nil {
+	if err := os.CopyFS(generatorInput, os.DirFS(generatorInputDir(languageRepo.Dir))); err != nil {
 		return err
 	}
 
 	// Perform "generate, clean" on each library.
 	for _, library := range ps.Libraries {
-		err := regenerateLibrary(state, apiRepo, generatorInput, outputDir, library)
+		err := regenerateLibrary(state, apiRepo, includeRoots, generatorInput, outputDir, library)
 		if err != nil {
 			return err
 		}
@@ -116,25 +171,25 @@ func updateImageTag(state *commandState) error {
 
 	// Commit any changes
 	commitMsg := fmt.Sprintf("chore: update generation image tag to %s", flagTag)
-	if err := commitAll(languageRepo, commitMsg); err != nil {
+	if err := commitAll(state, languageRepo, commitMsg); err != nil {
 		return err
 	}
 
 	// Build everything at the end. (This is more efficient than building each library with a separate container invocation.)
 	slog.Info("Building all libraries.")
-	if err := container.BuildLibrary(state.containerConfig, languageRepo.Dir, ""); err != nil {
+	if err := container.BuildLibrary(state.ctx, state.containerConfig, languageRepo.Dir, ""); err != nil {
 		return err
 	}
 
 	// The PullRequestContent for update-image-tag is slightly different to others, but we
 	// can massage it into a similar state.
 	prContent := new(PullRequestContent)
-	addSuccessToPullRequest(prContent, "Regenerated all libraries with new image tag.")
-	_, err := createPullRequest(state, prContent, "chore: update generation image tag", "", "update-image-tag")
+	addSuccessToPullRequest(prContent, "", "regenerating all libraries", "Regenerated all libraries with new image tag.")
+	_, err = createPullRequest(state, prOpts, prContent, "chore: update generation image tag", "", "update-image-tag")
 	return err
 }
 
-func regenerateLibrary(state *commandState, apiRepo *gitrepo.Repo, generatorInput string, outputRoot string, library *statepb.LibraryState) error {
+func regenerateLibrary(state *commandState, apiRepo *gitrepo.Repo, includeRoots []string, generatorInput string, outputRoot string, library *statepb.LibraryState) error {
 	containerConfig := state.containerConfig
 	languageRepo := state.languageRepo
 
@@ -156,15 +211,29 @@ func regenerateLibrary(state *commandState, apiRepo *gitrepo.Repo, generatorInpu
 		return err
 	}
 
-	if err := container.GenerateLibrary(containerConfig, apiRepo.Dir, outputDir, generatorInput, library.Id); err != nil {
+	if err := container.GenerateLibrary(state.ctx, containerConfig, apiRepo.Dir, includeRoots, outputDir, generatorInput, library.Id); err != nil {
+		return err
+	}
+	if err := checkGeneratorOutput(outputDir, library.Id); err != nil {
+		return err
+	}
+	if err := maybePostProcess(state, generatorInput, outputDir, library.Id); err != nil {
 		return err
 	}
-	if err := container.Clean(containerConfig, languageRepo.Dir, library.Id); err != nil {
+	if err := maybeRunPostGenerateHook(state.ctx, flagPostGenerateHook, outputDir, library.Id); err != nil {
 		return err
 	}
-	if err := os.CopyFS(languageRepo.Dir, os.DirFS(outputDir)); err != nil {
+	if err := cleanLibrary(state.ctx, state, languageRepo.Dir, library.Id); err != nil {
 		return err
 	}
+	if err := copyGeneratedCode(languageRepo.Dir, outputDir, library, flagLineEndings); err != nil {
+		return err
+	}
+	if flagPrune {
+		if err := pruneGeneratedCode(languageRepo.Dir, outputDir, library); err != nil {
+			return err
+		}
+	}
 	if err := gitrepo.CleanWorkingTree(apiRepo); err != nil {
 		return err
 	}
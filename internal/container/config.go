@@ -16,7 +16,10 @@ package container
 
 import (
 	"context"
+	"io"
+	"os"
 
+	"github.com/googleapis/librarian/internal/secrets"
 	"github.com/googleapis/librarian/internal/statepb"
 )
 
@@ -24,17 +27,85 @@ type ContainerConfig struct {
 	// The Docker image to run.
 	Image string
 
+	// The container runtime with which to run images. If this is nil, Docker is used.
+	Runtime Runtime
+
+	// A local executable to run instead of the image for generation commands, for
+	// developing the generator itself, if any. It's invoked with the same command and
+	// arguments as the image's entrypoint, with paths within the container replaced by
+	// the host paths they'd be mounted from. All other commands (e.g. builds) still
+	// run in the image.
+	LocalGenerator string
+
+	// The Docker image to run to validate generated code, if any. This is
+	// typically an organization-specific linter, and is run via Validate.
+	ValidationImage string
+
+	// Additional environment variables for generation and build commands, each
+	// either KEY=VALUE or KEY (to inherit the value from the current environment).
+	Environment []string
+
+	// The directory in which to write a log file of container output for each
+	// library (or API path, for raw generation), if any.
+	LogDir string
+
+	// The registry config file (e.g. a docker config.json) used to authenticate when
+	// pulling images, if any. Otherwise, the runtime's default configuration is used.
+	RegistryConfig string
+
+	// The number of times to retry generation and build commands which fail
+	// with transient Docker errors (as opposed to failures within the container).
+	Retries int
+
+	// Where the standard output of containers is written. If this is nil,
+	// os.Stdout is used.
+	Stdout io.Writer
+
+	// The context for the command being run, used for tracing.
+	ctx context.Context
+
 	// The provider for environment variables, if any.
 	envProvider *EnvironmentProvider
 }
 
-func NewContainerConfig(ctx context.Context, workRoot, image, secretsProject string, pipelineConfig *statepb.PipelineConfig) (*ContainerConfig, error) {
-	envProvider, err := newEnvironmentProvider(ctx, workRoot, secretsProject, pipelineConfig)
+func NewContainerConfig(ctx context.Context, workRoot, image string, secretProvider secrets.SecretProvider, pipelineConfig *statepb.PipelineConfig) (*ContainerConfig, error) {
+	envProvider, err := newEnvironmentProvider(ctx, workRoot, secretProvider, pipelineConfig)
 	if err != nil {
 		return nil, err
 	}
 	return &ContainerConfig{
 		Image:       image,
+		ctx:         ctx,
 		envProvider: envProvider,
 	}, nil
 }
+
+// Returns the context for the command being run, or a background context if
+// there is none.
+func (config *ContainerConfig) commandContext() context.Context {
+	if config.ctx == nil {
+		return context.Background()
+	}
+	return config.ctx
+}
+
+// Returns the environment in which to run the container runtime, so that it uses
+// the registry config file (if any).
+func (config *ContainerConfig) runtimeEnvironment() ([]string, error) {
+	if config.RegistryConfig == "" {
+		return nil, nil
+	}
+	variables, err := config.runtime().RegistryConfigEnvironment(config.RegistryConfig)
+	if err != nil {
+		return nil, err
+	}
+	return append(os.Environ(), variables...), nil
+}
+
+// Returns the configured runtime, defaulting to Docker.
+func (config *ContainerConfig) runtime() Runtime {
+	if config.Runtime == nil {
+		return dockerRuntime{}
+	}
+	return config.Runtime
+}
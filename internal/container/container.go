@@ -15,13 +15,26 @@
 package container
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"os/exec"
 	"os/user"
+	"path"
+	"path/filepath"
 	"slices"
 	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/googleapis/librarian/internal/offline"
+	"github.com/googleapis/librarian/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type ContainerCommand string
@@ -38,8 +51,85 @@ const (
 	ContainerCommandIntegrationTestLibrary ContainerCommand = "integration-test-library"
 	ContainerCommandPackageLibrary         ContainerCommand = "package-library"
 	ContainerCommandPublishLibrary         ContainerCommand = "publish-library"
+	ContainerCommandValidate               ContainerCommand = "validate"
+	ContainerCommandDetectBreakingChanges  ContainerCommand = "detect-breaking-changes"
+	// This isn't a command implemented by language containers: it's used to run
+	// a post-processing script from the language repo via a shell entrypoint.
+	ContainerCommandPostProcess ContainerCommand = "postprocess"
+)
+
+// The exit code used by "docker run" when the error is with docker itself,
+// rather than the command run in the container.
+const dockerErrorExitCode = 125
+
+// The exit codes used by "docker run" when the container's entrypoint can't be
+// invoked or can't be found, respectively.
+const (
+	entrypointNotExecutableExitCode = 126
+	entrypointNotFoundExitCode      = 127
 )
 
+// An ExitError is returned when a container exits with a non-zero exit code (or
+// the container runtime fails to run it). The underlying *exec.ExitError can be
+// obtained with errors.As.
+type ExitError struct {
+	// Command is the container command which was run.
+	Command ContainerCommand
+	// ExitCode is the exit code of the container runtime's process.
+	ExitCode int
+
+	err error
+}
+
+func (e *ExitError) Error() string {
+	if e.FailedToStart() {
+		return fmt.Sprintf("container for command %s failed to start (exit code %d)", e.Command, e.ExitCode)
+	}
+	return fmt.Sprintf("container command %s failed with exit code %d", e.Command, e.ExitCode)
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.err
+}
+
+// Reports whether the container couldn't be run at all (as opposed to the command
+// within the container failing), based on the exit codes used by "docker run".
+func (e *ExitError) FailedToStart() bool {
+	switch e.ExitCode {
+	case dockerErrorExitCode, entrypointNotExecutableExitCode, entrypointNotFoundExitCode:
+		return true
+	default:
+		return false
+	}
+}
+
+// The backoff between retries of transient docker failures starts at
+// initialRetryBackoff, and doubles up to maxRetryBackoff.
+const initialRetryBackoff = time.Second
+const maxRetryBackoff = 30 * time.Second
+
+// Used to give each container a unique name within this process.
+var containerCounter atomic.Int64
+
+// The file (within the output directory) in which detect-breaking-changes
+// describes any breaking changes it finds.
+const breakingChangesFile = "breaking-changes.txt"
+
+// The commands to which the additional environment variables in ContainerConfig.Environment are passed.
+var customEnvironmentContainerCommands = []ContainerCommand{
+	ContainerCommandGenerateRaw,
+	ContainerCommandGenerateLibrary,
+	ContainerCommandBuildRaw,
+	ContainerCommandBuildLibrary,
+}
+
+// The commands which are run by ContainerConfig.LocalGenerator, if it's set. Other commands
+// (e.g. builds) still run in the image, so that only generation is affected.
+var localGeneratorContainerCommands = []ContainerCommand{
+	ContainerCommandGenerateRaw,
+	ContainerCommandGenerateLibrary,
+}
+
 var networkEnabledContainerCommands = []ContainerCommand{
 	ContainerCommandBuildRaw,
 	ContainerCommandBuildLibrary,
@@ -48,7 +138,22 @@ var networkEnabledContainerCommands = []ContainerCommand{
 	ContainerCommandPublishLibrary,
 }
 
-func GenerateRaw(config *ContainerConfig, apiRoot, output, apiPath string) error {
+// Returns the mounts and arguments for the auxiliary API roots (beyond the primary root
+// containing the API path) which protos may be imported from, e.g. for cross-repo
+// dependencies. Each is mounted at /apis-include-{n}, and passed as --api-include-root,
+// in order; no arguments are passed if there are none.
+func includeRootMountsAndArgs(includeRoots []string) ([]string, []string) {
+	mounts := []string{}
+	args := []string{}
+	for i, includeRoot := range includeRoots {
+		containerPath := fmt.Sprintf("/apis-include-%d", i+1)
+		mounts = append(mounts, fmt.Sprintf("%s:%s", includeRoot, containerPath))
+		args = append(args, fmt.Sprintf("--api-include-root=%s", containerPath))
+	}
+	return mounts, args
+}
+
+func GenerateRaw(ctx context.Context, config *ContainerConfig, apiRoot string, includeRoots []string, output, apiPath string) error {
 	if apiRoot == "" {
 		return fmt.Errorf("apiRoot cannot be empty")
 	}
@@ -67,10 +172,13 @@ func GenerateRaw(config *ContainerConfig, apiRoot, output, apiPath string) error
 		fmt.Sprintf("%s:/apis", apiRoot),
 		fmt.Sprintf("%s:/output", output),
 	}
-	return runDocker(config, ContainerCommandGenerateRaw, mounts, commandArgs)
+	includeMounts, includeArgs := includeRootMountsAndArgs(includeRoots)
+	commandArgs = append(commandArgs, includeArgs...)
+	mounts = append(mounts, includeMounts...)
+	return runDockerWithRetries(ctx, config, ContainerCommandGenerateRaw, mounts, commandArgs)
 }
 
-func GenerateLibrary(config *ContainerConfig, apiRoot, output, generatorInput, libraryID string) error {
+func GenerateLibrary(ctx context.Context, config *ContainerConfig, apiRoot string, includeRoots []string, output, generatorInput, libraryID string) error {
 	if apiRoot == "" {
 		return fmt.Errorf("apiRoot cannot be empty")
 	}
@@ -94,10 +202,13 @@ func GenerateLibrary(config *ContainerConfig, apiRoot, output, generatorInput, l
 		fmt.Sprintf("%s:/output", output),
 		fmt.Sprintf("%s:/generator-input", generatorInput),
 	}
-	return runDocker(config, ContainerCommandGenerateLibrary, mounts, commandArgs)
+	includeMounts, includeArgs := includeRootMountsAndArgs(includeRoots)
+	commandArgs = append(commandArgs, includeArgs...)
+	mounts = append(mounts, includeMounts...)
+	return runDockerWithRetries(ctx, config, ContainerCommandGenerateLibrary, mounts, commandArgs)
 }
 
-func Clean(config *ContainerConfig, repoRoot, libraryID string) error {
+func Clean(ctx context.Context, config *ContainerConfig, repoRoot, libraryID string) error {
 	if repoRoot == "" {
 		return fmt.Errorf("repoRoot cannot be empty")
 	}
@@ -110,10 +221,10 @@ func Clean(config *ContainerConfig, repoRoot, libraryID string) error {
 	if libraryID != "" {
 		commandArgs = append(commandArgs, fmt.Sprintf("--library-id=%s", libraryID))
 	}
-	return runDocker(config, ContainerCommandClean, mounts, commandArgs)
+	return runDocker(ctx, config, ContainerCommandClean, mounts, commandArgs)
 }
 
-func BuildRaw(config *ContainerConfig, generatorOutput, apiPath string) error {
+func BuildRaw(ctx context.Context, config *ContainerConfig, generatorOutput, apiPath string) error {
 	if generatorOutput == "" {
 		return fmt.Errorf("generatorOutput cannot be empty")
 	}
@@ -127,10 +238,10 @@ func BuildRaw(config *ContainerConfig, generatorOutput, apiPath string) error {
 		"--generator-output=/generator-output",
 		fmt.Sprintf("--api-path=%s", apiPath),
 	}
-	return runDocker(config, ContainerCommandBuildRaw, mounts, commandArgs)
+	return runDockerWithRetries(ctx, config, ContainerCommandBuildRaw, mounts, commandArgs)
 }
 
-func BuildLibrary(config *ContainerConfig, repoRoot, libraryId string) error {
+func BuildLibrary(ctx context.Context, config *ContainerConfig, repoRoot, libraryId string) error {
 	if repoRoot == "" {
 		return fmt.Errorf("repoRoot cannot be empty")
 	}
@@ -143,7 +254,32 @@ func BuildLibrary(config *ContainerConfig, repoRoot, libraryId string) error {
 	if libraryId != "" {
 		commandArgs = append(commandArgs, fmt.Sprintf("--library-id=%s", libraryId))
 	}
-	return runDocker(config, ContainerCommandBuildLibrary, mounts, commandArgs)
+	return runDockerWithRetries(ctx, config, ContainerCommandBuildLibrary, mounts, commandArgs)
+}
+
+// Runs the validation image (rather than the language-specific image) against generated
+// code in outputDir. The library ID is optional, as raw generation has no library.
+// A non-zero exit code from the validation image is reported as an error.
+func Validate(ctx context.Context, config *ContainerConfig, outputDir, libraryID string) error {
+	if config.ValidationImage == "" {
+		return fmt.Errorf("validation image cannot be empty")
+	}
+	if outputDir == "" {
+		return fmt.Errorf("outputDir cannot be empty")
+	}
+	mounts := []string{
+		fmt.Sprintf("%s:/output", outputDir),
+	}
+	commandArgs := []string{
+		"--output=/output",
+	}
+	if libraryID != "" {
+		commandArgs = append(commandArgs, fmt.Sprintf("--library-id=%s", libraryID))
+	}
+	validationConfig := *config
+	validationConfig.Image = config.ValidationImage
+	validationConfig.LocalGenerator = ""
+	return runDocker(ctx, &validationConfig, ContainerCommandValidate, mounts, commandArgs)
 }
 
 func Configure(config *ContainerConfig, apiRoot, apiPath, generatorInput string) error {
@@ -165,7 +301,43 @@ func Configure(config *ContainerConfig, apiRoot, apiPath, generatorInput string)
 		fmt.Sprintf("%s:/apis", apiRoot),
 		fmt.Sprintf("%s:/generator-input", generatorInput),
 	}
-	return runDocker(config, ContainerCommandConfigure, mounts, commandArgs)
+	return runDocker(config.commandContext(), config, ContainerCommandConfigure, mounts, commandArgs)
+}
+
+// Detects breaking changes in the API surface of a library since the given previous
+// release tag. The container describes any breaking changes it finds in a file within
+// outputDir; the description is returned, and is empty if no breaking changes were found.
+func DetectBreakingChanges(config *ContainerConfig, languageRepo, outputDir, libID, previousReleaseTag string) (string, error) {
+	if languageRepo == "" {
+		return "", fmt.Errorf("languageRepo cannot be empty")
+	}
+	if outputDir == "" {
+		return "", fmt.Errorf("outputDir cannot be empty")
+	}
+	if previousReleaseTag == "" {
+		return "", fmt.Errorf("previousReleaseTag cannot be empty")
+	}
+	commandArgs := []string{
+		"--repo-root=/repo",
+		"--output=/output",
+		fmt.Sprintf("--library-id=%s", libID),
+		fmt.Sprintf("--previous-release-tag=%s", previousReleaseTag),
+	}
+	mounts := []string{
+		fmt.Sprintf("%s:/repo", languageRepo),
+		fmt.Sprintf("%s:/output", outputDir),
+	}
+	if err := runDocker(config.commandContext(), config, ContainerCommandDetectBreakingChanges, mounts, commandArgs); err != nil {
+		return "", err
+	}
+	content, err := os.ReadFile(filepath.Join(outputDir, breakingChangesFile))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
 }
 
 func PrepareLibraryRelease(config *ContainerConfig, languageRepo, inputsDirectory, libId, releaseVersion string) error {
@@ -180,7 +352,7 @@ func PrepareLibraryRelease(config *ContainerConfig, languageRepo, inputsDirector
 		fmt.Sprintf("%s:/inputs", inputsDirectory),
 	}
 
-	return runDocker(config, ContainerCommandPrepareLibraryRelease, mounts, commandArgs)
+	return runDocker(config.commandContext(), config, ContainerCommandPrepareLibraryRelease, mounts, commandArgs)
 }
 
 func IntegrationTestLibrary(config *ContainerConfig, languageRepo, libId string) error {
@@ -192,7 +364,7 @@ func IntegrationTestLibrary(config *ContainerConfig, languageRepo, libId string)
 		fmt.Sprintf("%s:/repo", languageRepo),
 	}
 
-	return runDocker(config, ContainerCommandIntegrationTestLibrary, mounts, commandArgs)
+	return runDocker(config.commandContext(), config, ContainerCommandIntegrationTestLibrary, mounts, commandArgs)
 }
 
 func PackageLibrary(config *ContainerConfig, languageRepo, libId, outputDir string) error {
@@ -206,7 +378,7 @@ func PackageLibrary(config *ContainerConfig, languageRepo, libId, outputDir stri
 		fmt.Sprintf("%s:/output", outputDir),
 	}
 
-	return runDocker(config, ContainerCommandPackageLibrary, mounts, commandArgs)
+	return runDocker(config.commandContext(), config, ContainerCommandPackageLibrary, mounts, commandArgs)
 }
 
 func PublishLibrary(config *ContainerConfig, outputDir, libId, libVersion string) error {
@@ -219,46 +391,272 @@ func PublishLibrary(config *ContainerConfig, outputDir, libId, libVersion string
 		fmt.Sprintf("%s:/output", outputDir),
 	}
 
-	return runDocker(config, ContainerCommandPublishLibrary, mounts, commandArgs)
+	return runDocker(config.commandContext(), config, ContainerCommandPublishLibrary, mounts, commandArgs)
 }
 
-func runDocker(config *ContainerConfig, command ContainerCommand, mounts []string, commandArgs []string) error {
+// Runs the post-processing script at scriptPath (relative to generatorInput) against
+// the generated code in output for the given library. The script is run in the image
+// specified by config (for hermeticity) using a shell entrypoint, with the output
+// directory as the working directory and the library ID as its only argument.
+func PostProcess(config *ContainerConfig, output, generatorInput, scriptPath, libraryID string) error {
+	if output == "" {
+		return fmt.Errorf("output cannot be empty")
+	}
+	if generatorInput == "" {
+		return fmt.Errorf("generatorInput cannot be empty")
+	}
+	if scriptPath == "" {
+		return fmt.Errorf("scriptPath cannot be empty")
+	}
+	if libraryID == "" {
+		return fmt.Errorf("libraryID cannot be empty")
+	}
+	mounts := []string{
+		fmt.Sprintf("%s:/output", output),
+		fmt.Sprintf("%s:/generator-input:ro", generatorInput),
+	}
+	args := []string{
+		"--entrypoint=/bin/sh",
+		"--workdir=/output",
+	}
+	commandArgs := []string{
+		path.Join("/generator-input", filepath.ToSlash(scriptPath)),
+		libraryID,
+	}
+	return runContainer(config.commandContext(), config, ContainerCommandPostProcess, args, mounts, commandArgs)
+}
+
+// Pulls the image specified by config, returning its resolved digests (of the form
+// "sha256:..."). An image can have multiple digests if it's known by multiple repos.
+func PullImage(ctx context.Context, config *ContainerConfig) ([]string, error) {
+	if config.Image == "" {
+		return nil, fmt.Errorf("image cannot be empty")
+	}
+	if err := offline.Check(fmt.Sprintf("pull image %s", config.Image)); err != nil {
+		return nil, err
+	}
+	binary := config.runtime().Binary()
+	env, err := config.runtimeEnvironment()
+	if err != nil {
+		return nil, err
+	}
+	slog.Info(fmt.Sprintf("Pulling image %s", config.Image))
+	pull := exec.CommandContext(ctx, binary, "pull", config.Image)
+	pull.Env = env
+	pull.Stdout = os.Stderr
+	pull.Stderr = os.Stderr
+	if err := pull.Run(); err != nil {
+		return nil, fmt.Errorf("failed to pull image %s: %w", config.Image, err)
+	}
+	return ImageDigests(ctx, config)
+}
+
+// Returns the digests (of the form "sha256:...") of the image specified by config, which
+// must already be present locally.
+func ImageDigests(ctx context.Context, config *ContainerConfig) ([]string, error) {
+	if config.Image == "" {
+		return nil, fmt.Errorf("image cannot be empty")
+	}
+	output, err := exec.CommandContext(ctx, config.runtime().Binary(), "image", "inspect", "--format={{json .RepoDigests}}", config.Image).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect image %s: %w", config.Image, err)
+	}
+	var repoDigests []string
+	if err := json.Unmarshal(output, &repoDigests); err != nil {
+		return nil, fmt.Errorf("failed to parse digests of image %s: %w", config.Image, err)
+	}
+	digests := []string{}
+	for _, repoDigest := range repoDigests {
+		if _, digest, ok := strings.Cut(repoDigest, "@"); ok && !slices.Contains(digests, digest) {
+			digests = append(digests, digest)
+		}
+	}
+	if len(digests) == 0 {
+		return nil, fmt.Errorf("no digest found for image %s", config.Image)
+	}
+	return digests, nil
+}
+
+// Returns the ID of the image specified by config, which must already be present locally.
+// Unlike its digests, the ID is available for images which haven't been pushed or pulled.
+func ImageID(ctx context.Context, config *ContainerConfig) (string, error) {
+	if config.Image == "" {
+		return "", fmt.Errorf("image cannot be empty")
+	}
+	output, err := exec.CommandContext(ctx, config.runtime().Binary(), "image", "inspect", "--format={{.Id}}", config.Image).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect image %s: %w", config.Image, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// Checks that the container runtime is installed and usable (e.g. for docker, that the
+// daemon is running), by running its "info" command.
+func CheckRuntime(ctx context.Context, config *ContainerConfig) error {
+	binary := config.runtime().Binary()
+	if _, err := exec.LookPath(binary); err != nil {
+		return fmt.Errorf("%s not found: %w", binary, err)
+	}
+	var stderr bytes.Buffer
+	info := exec.CommandContext(ctx, binary, "info")
+	info.Stderr = &stderr
+	if err := info.Run(); err != nil {
+		return fmt.Errorf("%s info failed: %w: %s", binary, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// Checks that the image can be pulled from its registry (with any registry config in
+// effect), by inspecting its manifest rather than pulling it.
+func CheckImagePullable(ctx context.Context, config *ContainerConfig) error {
+	if config.Image == "" {
+		return fmt.Errorf("image cannot be empty")
+	}
+	if err := offline.Check(fmt.Sprintf("inspect image %s in its registry", config.Image)); err != nil {
+		return err
+	}
+	env, err := config.runtimeEnvironment()
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	inspect := exec.CommandContext(ctx, config.runtime().Binary(), "manifest", "inspect", config.Image)
+	inspect.Env = env
+	inspect.Stderr = &stderr
+	if err := inspect.Run(); err != nil {
+		return fmt.Errorf("failed to inspect manifest of image %s: %w: %s", config.Image, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// Runs docker as for runDocker, but retrying (config.Retries times, with exponential
+// backoff) if docker fails with a transient error.
+func runDockerWithRetries(ctx context.Context, config *ContainerConfig, command ContainerCommand, mounts []string, commandArgs []string) error {
+	attempts := config.Retries + 1
+	backoff := initialRetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := runDocker(ctx, config, command, mounts, commandArgs)
+		if err == nil {
+			if attempt > 1 {
+				slog.Info(fmt.Sprintf("Container command %s succeeded on attempt %d of %d", command, attempt, attempts))
+			}
+			return nil
+		}
+		if attempt >= attempts || !isTransientDockerError(err) || ctx.Err() != nil {
+			if attempts > 1 {
+				return fmt.Errorf("container command %s failed on attempt %d of %d: %w", command, attempt, attempts, err)
+			}
+			return err
+		}
+		slog.Warn(fmt.Sprintf("Container command %s failed with transient error on attempt %d of %d: %s; retrying in %s", command, attempt, attempts, err, backoff))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxRetryBackoff)
+	}
+}
+
+// Reports whether err represents a transient failure of docker itself (e.g. the daemon
+// being unavailable) rather than a failure of the command within the container. Docker
+// uses exit code 125 for the former; any other exit code is from the container.
+func isTransientDockerError(err error) bool {
+	var exitErr *ExitError
+	return errors.As(err, &exitErr) && exitErr.ExitCode == dockerErrorExitCode
+}
+
+func runDocker(ctx context.Context, config *ContainerConfig, command ContainerCommand, mounts []string, commandArgs []string) error {
+	if config.LocalGenerator != "" && slices.Contains(localGeneratorContainerCommands, command) {
+		return runLocalGenerator(ctx, config, command, mounts, commandArgs)
+	}
+	return runContainer(ctx, config, command, nil, mounts, append([]string{string(command)}, commandArgs...))
+}
+
+// Runs a container for the given command. The extraArgs are passed to "docker run"
+// before the image name; the containerArgs are passed to the container's entrypoint.
+// If ctx is cancelled (or its deadline is exceeded) while the container is running,
+// the container is killed.
+func runContainer(ctx context.Context, config *ContainerConfig, command ContainerCommand, extraArgs []string, mounts []string, containerArgs []string) (err error) {
+	attributes := []attribute.KeyValue{tracing.AttributeImage.String(config.Image)}
+	libraryID := ""
+	for _, arg := range containerArgs {
+		if id, ok := strings.CutPrefix(arg, "--library-id="); ok {
+			libraryID = id
+			attributes = append(attributes, tracing.AttributeLibraryID.String(libraryID))
+		}
+	}
+	_, phase := tracing.StartPhase(ctx, "container "+string(command), attributes...)
+	defer func() { phase.End(err) }()
+
 	if config.Image == "" {
 		return fmt.Errorf("image cannot be empty")
 	}
 
 	mounts = maybeRelocateMounts(mounts)
 
+	removeStaleContainers(ctx, config)
+	// Name the container so that it can be killed if ctx is done. (Killing the docker
+	// client process doesn't stop the container itself.) It's also labeled so that it can
+	// be removed by a later run if this process exits without killing it.
+	containerName := newContainerName(libraryID)
 	args := []string{
 		"run",
 		"--rm", // Automatically delete the container after completion
+		"--name=" + containerName,
 	}
+	args = append(args, ownerLabelArgs()...)
 	// Run as the current user in the container - primarily so that any
 	// files we create end up being owned by the current user (and easily deletable).
 	currentUser, err := user.Current()
 	if err != nil {
 		return err
 	}
-	args = append(args, fmt.Sprintf("--user=%s:%s", currentUser.Uid, currentUser.Gid))
+	runtime := config.runtime()
+	args = append(args, runtime.UserArgs(currentUser.Uid, currentUser.Gid)...)
 
 	for _, mount := range mounts {
 		args = append(args, "-v", mount)
 	}
 	if config.envProvider != nil {
-		if err := writeEnvironmentFile(config.envProvider, string(command)); err != nil {
+		envFile, err := writeEnvironmentFile(config.envProvider, string(command))
+		if err != nil {
 			return err
 		}
 		args = append(args, "--env-file")
-		args = append(args, config.envProvider.tmpFile)
-		defer deleteEnvironmentFile(config.envProvider)
+		args = append(args, envFile)
+		defer deleteEnvironmentFile(envFile)
 	}
-	if !slices.Contains(networkEnabledContainerCommands, command) {
+	if slices.Contains(customEnvironmentContainerCommands, command) {
+		for _, variable := range config.Environment {
+			args = append(args, "-e", variable)
+		}
+	}
+	if offline.Enabled() {
+		// The image must already be present, and the container can't use the network either.
+		args = append(args, "--pull=never", "--network=none")
+	} else if !slices.Contains(networkEnabledContainerCommands, command) {
 		args = append(args, "--network=none")
 	}
+	args = append(args, extraArgs...)
 	args = append(args, config.Image)
-	args = append(args, string(command))
-	args = append(args, commandArgs...)
-	return runCommand("docker", args...)
+	args = append(args, containerArgs...)
+	// The image is pulled by "run" if it's not already present, which may require authentication.
+	env, err := config.runtimeEnvironment()
+	if err != nil {
+		return err
+	}
+	stdout, stderr, closeOutput, err := openContainerOutput(config, containerLogID(command, containerArgs))
+	if err != nil {
+		return err
+	}
+	defer closeOutput()
+	err = runCommand(ctx, containerName, env, stdout, stderr, runtime.Binary(), args...)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return &ExitError{Command: command, ExitCode: exitErr.ExitCode(), err: err}
+	}
+	return err
 }
 
 func maybeRelocateMounts(mounts []string) []string {
@@ -279,14 +677,27 @@ func maybeRelocateMounts(mounts []string) []string {
 	return relocatedMounts
 }
 
-func runCommand(c string, args ...string) error {
-	cmd := exec.Command(c, args...)
-	cmd.Stderr = os.Stderr
-	cmd.Stdout = os.Stdout
+// Runs the container runtime. If env is nil, the current environment is used.
+func runCommand(ctx context.Context, containerName string, env []string, stdout, stderr io.Writer, c string, args ...string) error {
+	cmd := exec.CommandContext(ctx, c, args...)
+	cmd.Env = env
+	cmd.Stderr = stderr
+	cmd.Stdout = stdout
+	cmd.Cancel = func() error {
+		slog.Warn(fmt.Sprintf("Killing container %s: %s", containerName, context.Cause(ctx)))
+		if err := exec.Command(c, "kill", containerName).Run(); err != nil {
+			slog.Warn(fmt.Sprintf("Failed to kill container %s: %s", containerName, err))
+		}
+		return cmd.Process.Kill()
+	}
 	slog.Info(fmt.Sprintf("=== Docker start %s", strings.Repeat("=", 63)))
-	slog.Info(cmd.String())
+	slog.Info(fmt.Sprintf("Running container %s", containerName))
+	slog.Debug(cmd.String())
 	slog.Info(strings.Repeat("-", 80))
 	err := cmd.Run()
 	slog.Info(fmt.Sprintf("=== Docker end %s", strings.Repeat("=", 65)))
+	if err != nil && ctx.Err() != nil {
+		return fmt.Errorf("container %s was killed: %w", containerName, ctx.Err())
+	}
 	return err
 }
@@ -16,70 +16,59 @@ package container
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"log/slog"
 	"os"
-	"path/filepath"
 	"strings"
 
-	secretmanager "cloud.google.com/go/secretmanager/apiv1"
-	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
-	"github.com/googleapis/gax-go/v2/apierror"
+	"github.com/googleapis/librarian/internal/secrets"
 	"github.com/googleapis/librarian/internal/statepb"
-	"github.com/googleapis/librarian/internal/utils"
-	"google.golang.org/grpc/codes"
 )
 
 // EnvironmentProvider represents configuration for environment
 // variables for container invocations.
 type EnvironmentProvider struct {
-	// The context used for SecretManager requests
+	// The context used for secret requests
 	ctx context.Context
-	// The file used to store the environment variables for the duration of a docker run.
-	tmpFile string
-	// The client used to fetch secrets from Secret Manager, if any.
-	secretManagerClient *secretmanager.Client
-	// The project in which to look up secrets
-	secretsProject string
-	// A cache of secrets we've already fetched.
-	secretCache map[string]string
+	// The directory in which to store the environment variables for the duration of each
+	// docker run. A separate file is written for each run, as containers may be run concurrently.
+	tmpDir string
+	// The provider from which secrets are fetched, if any. Providers cache secrets themselves.
+	secretProvider secrets.SecretProvider
 	// The pipeline configuration, specifying which environment variables to obtain
 	// for each command.
 	pipelineConfig *statepb.PipelineConfig
 }
 
-func newEnvironmentProvider(ctx context.Context, workRoot, secretsProject string, pipelineConfig *statepb.PipelineConfig) (*EnvironmentProvider, error) {
+func newEnvironmentProvider(ctx context.Context, workRoot string, secretProvider secrets.SecretProvider, pipelineConfig *statepb.PipelineConfig) (*EnvironmentProvider, error) {
 	if pipelineConfig == nil {
 		return nil, nil
 	}
-	var secretManagerClient *secretmanager.Client
-	if secretsProject != "" {
-		client, err := secretmanager.NewClient(ctx)
-		if err != nil {
-			return nil, err
-		}
-		secretManagerClient = client
-	} else {
-		secretManagerClient = nil
-	}
-	tmpFile := filepath.Join(workRoot, "docker-env.txt")
 	return &EnvironmentProvider{
-		ctx:                 ctx,
-		tmpFile:             tmpFile,
-		secretManagerClient: secretManagerClient,
-		secretsProject:      secretsProject,
-		secretCache:         make(map[string]string),
-		pipelineConfig:      pipelineConfig,
+		ctx:            ctx,
+		tmpDir:         workRoot,
+		secretProvider: secretProvider,
+		pipelineConfig: pipelineConfig,
 	}, nil
 }
 
-func writeEnvironmentFile(containerEnv *EnvironmentProvider, commandName string) error {
+// Writes the environment variables for the given command to a new file, returning
+// the path to the file. The file should be deleted with deleteEnvironmentFile after use.
+func writeEnvironmentFile(containerEnv *EnvironmentProvider, commandName string) (string, error) {
 	content, err := constructEnvironmentFileContent(containerEnv, commandName)
 	if err != nil {
-		return err
+		return "", err
+	}
+	file, err := os.CreateTemp(containerEnv.tmpDir, "docker-env-*.txt")
+	if err != nil {
+		return "", err
+	}
+	if _, err := file.WriteString(content); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return "", err
 	}
-	return utils.CreateAndWriteToFile(containerEnv.tmpFile, content)
+	return file.Name(), file.Close()
 }
 
 func constructEnvironmentFileContent(containerEnv *EnvironmentProvider, commandName string) (string, error) {
@@ -93,13 +82,15 @@ func constructEnvironmentFileContent(containerEnv *EnvironmentProvider, commandN
 		var err error
 		// First source: environment variables
 		value, present := os.LookupEnv(variable.Name)
-		// Second source: Secret Manager
+		// Second source: the secret provider
 		if !present {
-			source = "Secret Manager"
-			value, present, err = getSecretManagerValue(containerEnv, variable)
+			value, present, err = getSecretValue(containerEnv, variable)
 			if err != nil {
 				return "", err
 			}
+			if present {
+				source = containerEnv.secretProvider.String()
+			}
 		}
 		// Final fallback: default value
 		if !present && variable.DefaultValue != "" {
@@ -121,34 +112,13 @@ func constructEnvironmentFileContent(containerEnv *EnvironmentProvider, commandN
 	return builder.String(), nil
 }
 
-func getSecretManagerValue(containerEnv *EnvironmentProvider, variable *statepb.CommandEnvironmentVariable) (string, bool, error) {
-	if variable.SecretName == "" || containerEnv.secretManagerClient == nil {
+func getSecretValue(containerEnv *EnvironmentProvider, variable *statepb.CommandEnvironmentVariable) (string, bool, error) {
+	if variable.SecretName == "" || containerEnv.secretProvider == nil {
 		return "", false, nil
 	}
-	value, present := containerEnv.secretCache[variable.SecretName]
-	if present {
-		return value, true, nil
-	}
-	request := &secretmanagerpb.AccessSecretVersionRequest{
-		Name: fmt.Sprintf("projects/%s/secrets/%s/versions/latest", containerEnv.secretsProject, variable.SecretName),
-	}
-	secret, err := containerEnv.secretManagerClient.AccessSecretVersion(containerEnv.ctx, request)
-	if err != nil {
-		// If the error is that the secret wasn't found, continue to the next source.
-		// Any other error causes a real error to be returned.
-		var ae *apierror.APIError
-		if errors.As(err, &ae) && ae.GRPCStatus().Code() == codes.NotFound {
-			return "", false, nil
-		} else {
-			return "", false, err
-		}
-	}
-	// We assume the payload is valid UTF-8.
-	value = string(secret.Payload.Data[:])
-	containerEnv.secretCache[variable.SecretName] = value
-	return value, true, nil
+	return containerEnv.secretProvider.Secret(containerEnv.ctx, variable.SecretName)
 }
 
-func deleteEnvironmentFile(containerEnv *EnvironmentProvider) error {
-	return os.Remove(containerEnv.tmpFile)
+func deleteEnvironmentFile(path string) error {
+	return os.Remove(path)
 }
@@ -0,0 +1,99 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/googleapis/librarian/internal/tracing"
+)
+
+// Runs the local generator (see ContainerConfig.LocalGenerator) instead of the image, with
+// the same command and arguments as the image's entrypoint would receive. Arguments which
+// refer to directories mounted into the container are rewritten to the host directories.
+func runLocalGenerator(ctx context.Context, config *ContainerConfig, command ContainerCommand, mounts []string, commandArgs []string) (err error) {
+	_, phase := tracing.StartPhase(ctx, "local generator "+string(command))
+	defer func() { phase.End(err) }()
+
+	args := append([]string{string(command)}, rewriteMountedPaths(mounts, commandArgs)...)
+	env := os.Environ()
+	if config.envProvider != nil {
+		content, err := constructEnvironmentFileContent(config.envProvider, string(command))
+		if err != nil {
+			return err
+		}
+		for _, line := range strings.Split(content, "\n") {
+			if line != "" && !strings.HasPrefix(line, "#") {
+				env = append(env, line)
+			}
+		}
+	}
+	if slices.Contains(customEnvironmentContainerCommands, command) {
+		// Variables without values are inherited, so are already present.
+		for _, variable := range config.Environment {
+			if strings.Contains(variable, "=") {
+				env = append(env, variable)
+			}
+		}
+	}
+	stdout, stderr, closeOutput, err := openContainerOutput(config, containerLogID(command, commandArgs))
+	if err != nil {
+		return err
+	}
+	defer closeOutput()
+	cmd := exec.CommandContext(ctx, config.LocalGenerator, args...)
+	cmd.Env = env
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	slog.Info(fmt.Sprintf("Running local generator: %s", cmd.String()))
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return &ExitError{Command: command, ExitCode: exitErr.ExitCode(), err: err}
+	}
+	return err
+}
+
+// Replaces the container paths of mounts (each of the form host:container, optionally
+// followed by options such as ":ro") in the values of "--name=value" arguments with
+// the corresponding host paths.
+func rewriteMountedPaths(mounts []string, args []string) []string {
+	rewritten := []string{}
+	for _, arg := range args {
+		name, value, ok := strings.Cut(arg, "=")
+		if ok && strings.HasPrefix(name, "--") {
+			for _, mount := range mounts {
+				parts := strings.Split(mount, ":")
+				if len(parts) < 2 {
+					continue
+				}
+				host, containerPath := parts[0], parts[1]
+				if value == containerPath || strings.HasPrefix(value, containerPath+"/") {
+					arg = name + "=" + host + strings.TrimPrefix(value, containerPath)
+					break
+				}
+			}
+		}
+		rewritten = append(rewritten, arg)
+	}
+	return rewritten
+}
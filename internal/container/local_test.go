@@ -0,0 +1,85 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// A runtime whose binary is a script, so that tests can tell whether it was run.
+type fakeRuntime struct {
+	binary string
+}
+
+func (runtime fakeRuntime) Binary() string {
+	return runtime.binary
+}
+
+func (fakeRuntime) UserArgs(uid, gid string) []string {
+	return nil
+}
+
+func (fakeRuntime) RegistryConfigEnvironment(configFile string) ([]string, error) {
+	return nil, nil
+}
+
+// Writes a script which appends its name and the command it was given to logFile.
+func writeRecordingScript(t *testing.T, dir, name, logFile string) string {
+	t.Helper()
+	script := filepath.Join(dir, name)
+	content := "#!/bin/sh\nif [ \"$1\" != ps ]; then echo \"" + name + " $1\" >> " + logFile + "; fi\n"
+	if err := os.WriteFile(script, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return script
+}
+
+func TestRunDockerWithLocalGenerator(t *testing.T) {
+	tests := []struct {
+		command  ContainerCommand
+		expected string
+	}{
+		{ContainerCommandGenerateRaw, "local generate-raw"},
+		{ContainerCommandGenerateLibrary, "local generate-library"},
+		{ContainerCommandBuildRaw, "runtime run"},
+		{ContainerCommandBuildLibrary, "runtime run"},
+		{ContainerCommandConfigure, "runtime run"},
+	}
+	for _, test := range tests {
+		dir := t.TempDir()
+		logFile := filepath.Join(dir, "log")
+		config := &ContainerConfig{
+			Image:          "image",
+			Runtime:        fakeRuntime{binary: writeRecordingScript(t, dir, "runtime", logFile)},
+			LocalGenerator: writeRecordingScript(t, dir, "local", logFile),
+			Stdout:         io.Discard,
+		}
+		if err := runDocker(context.Background(), config, test.command, nil, nil); err != nil {
+			t.Fatalf("runDocker(%s) failed: %s", test.command, err)
+		}
+		content, err := os.ReadFile(logFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := strings.TrimSpace(string(content)); actual != test.expected {
+			t.Errorf("runDocker(%s) expected %q to be run, got %q", test.command, test.expected, actual)
+		}
+	}
+}
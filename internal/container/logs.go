@@ -0,0 +1,132 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Returns an identifier for the subject of a container command, used to prefix
+// its output and name its log file: the library ID if there is one, otherwise the
+// API path, otherwise the command name.
+func containerLogID(command ContainerCommand, containerArgs []string) string {
+	apiPath := ""
+	for _, arg := range containerArgs {
+		if libraryID, ok := strings.CutPrefix(arg, "--library-id="); ok && libraryID != "" {
+			return libraryID
+		}
+		if value, ok := strings.CutPrefix(arg, "--api-path="); ok {
+			apiPath = value
+		}
+	}
+	if apiPath != "" {
+		return strings.ReplaceAll(apiPath, "/", "-")
+	}
+	return string(command)
+}
+
+// Returns the writers to use for the standard output and error of a container, and a
+// function to call (after the container has finished) to flush and close them. Each
+// line of output is prefixed with logID, and if config.LogDir is set, the output is
+// also appended to {LogDir}/{logID}.log.
+func openContainerOutput(config *ContainerConfig, logID string) (io.Writer, io.Writer, func() error, error) {
+	var stdout io.Writer = os.Stdout
+	if config.Stdout != nil {
+		stdout = config.Stdout
+	}
+	var stderr io.Writer = os.Stderr
+	var logFile *os.File
+	if config.LogDir != "" {
+		if err := os.MkdirAll(config.LogDir, 0755); err != nil {
+			return nil, nil, nil, err
+		}
+		file, err := os.OpenFile(filepath.Join(config.LogDir, logID+".log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		logFile = file
+		// Standard output and error are copied concurrently, so writes to the
+		// shared log file must be synchronized.
+		log := &syncWriter{w: file}
+		stdout = io.MultiWriter(stdout, log)
+		stderr = io.MultiWriter(stderr, log)
+	}
+	prefix := "[" + logID + "] "
+	prefixedStdout := &prefixWriter{w: stdout, prefix: prefix}
+	prefixedStderr := &prefixWriter{w: stderr, prefix: prefix}
+	closeOutput := func() error {
+		err := prefixedStdout.Flush()
+		if err2 := prefixedStderr.Flush(); err == nil {
+			err = err2
+		}
+		if logFile != nil {
+			if err2 := logFile.Close(); err == nil {
+				err = err2
+			}
+		}
+		return err
+	}
+	return prefixedStdout, prefixedStderr, closeOutput, nil
+}
+
+// A prefixWriter writes each line of output to w with a fixed prefix. Partial lines
+// are buffered until they're completed, or until Flush is called.
+type prefixWriter struct {
+	w       io.Writer
+	prefix  string
+	partial []byte
+}
+
+func (p *prefixWriter) Write(data []byte) (int, error) {
+	p.partial = append(p.partial, data...)
+	for {
+		index := bytes.IndexByte(p.partial, '\n')
+		if index < 0 {
+			return len(data), nil
+		}
+		if _, err := io.WriteString(p.w, p.prefix); err != nil {
+			return 0, err
+		}
+		if _, err := p.w.Write(p.partial[:index+1]); err != nil {
+			return 0, err
+		}
+		p.partial = p.partial[index+1:]
+	}
+}
+
+// Flush writes any buffered partial line, followed by a line break.
+func (p *prefixWriter) Flush() error {
+	if len(p.partial) == 0 {
+		return nil
+	}
+	_, err := p.Write([]byte("\n"))
+	return err
+}
+
+type syncWriter struct {
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+func (s *syncWriter) Write(data []byte) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.w.Write(data)
+}
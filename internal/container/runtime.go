@@ -0,0 +1,87 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// The names of the supported container runtimes, as accepted by NewRuntime.
+const (
+	RuntimeDocker = "docker"
+	RuntimePodman = "podman"
+)
+
+// A Runtime is a container runtime (such as Docker or Podman) used to run
+// language containers. The runtimes' CLIs are largely compatible, so this only
+// abstracts the differences which matter to Librarian.
+type Runtime interface {
+	// Binary returns the name of the executable to run.
+	Binary() string
+	// UserArgs returns the arguments to "run" which make files created by the
+	// container in mounted directories owned by the user with the given IDs.
+	UserArgs(uid, gid string) []string
+	// RegistryConfigEnvironment returns the environment variables which make the
+	// runtime authenticate to registries using the given config file.
+	RegistryConfigEnvironment(configFile string) ([]string, error)
+}
+
+// Returns the runtime with the given name, which must be either "docker" or "podman".
+func NewRuntime(name string) (Runtime, error) {
+	switch name {
+	case RuntimeDocker:
+		return dockerRuntime{}, nil
+	case RuntimePodman:
+		return podmanRuntime{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported container runtime %q; must be %s or %s", name, RuntimeDocker, RuntimePodman)
+	}
+}
+
+type dockerRuntime struct{}
+
+func (dockerRuntime) Binary() string {
+	return "docker"
+}
+
+func (dockerRuntime) UserArgs(uid, gid string) []string {
+	return []string{fmt.Sprintf("--user=%s:%s", uid, gid)}
+}
+
+// Docker only accepts a directory containing the config file, so the file must be
+// named config.json.
+func (dockerRuntime) RegistryConfigEnvironment(configFile string) ([]string, error) {
+	if filepath.Base(configFile) != "config.json" {
+		return nil, fmt.Errorf("docker registry config file %s must be named config.json", configFile)
+	}
+	return []string{"DOCKER_CONFIG=" + filepath.Dir(configFile)}, nil
+}
+
+type podmanRuntime struct{}
+
+func (podmanRuntime) Binary() string {
+	return "podman"
+}
+
+// Rootless Podman maps the current user to root within the container by default;
+// keep-id maps it to the same IDs instead, which is equivalent to Docker's --user.
+func (podmanRuntime) UserArgs(uid, gid string) []string {
+	return []string{fmt.Sprintf("--userns=keep-id:uid=%s,gid=%s", uid, gid)}
+}
+
+func (podmanRuntime) RegistryConfigEnvironment(configFile string) ([]string, error) {
+	return []string{"REGISTRY_AUTH_FILE=" + configFile}, nil
+}
@@ -0,0 +1,246 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Every container started by Librarian is labeled with the ID of the run which started it,
+// and the process ID, host and PID namespace of that Librarian process, so that containers
+// left behind when that process exits abnormally (e.g. if it's killed with SIGKILL) can be
+// identified and removed.
+const (
+	runLabel          = "librarian.run"
+	pidLabel          = "librarian.pid"
+	hostLabel         = "librarian.host"
+	pidNamespaceLabel = "librarian.pidns"
+)
+
+// A random ID for this Librarian process. Unlike the process ID, this is unique across PID
+// namespaces (e.g. CI jobs in separate containers sharing a Docker daemon), so it's used to
+// keep container names from colliding between such processes.
+var runID = newRunID()
+
+func newRunID() string {
+	bytes := make([]byte, 4)
+	if _, err := rand.Read(bytes); err != nil {
+		return strconv.Itoa(os.Getpid())
+	}
+	return hex.EncodeToString(bytes)
+}
+
+// Characters which aren't valid in container names.
+var invalidContainerNameCharactersRegex = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// Returns a unique name for a new container, of the form librarian-{pid}-{run}-{counter},
+// followed by the library ID (if any) so that the container can be identified when listing
+// containers.
+func newContainerName(libraryID string) string {
+	name := fmt.Sprintf("librarian-%d-%s-%d", os.Getpid(), runID, containerCounter.Add(1))
+	if libraryID = invalidContainerNameCharactersRegex.ReplaceAllString(libraryID, "-"); libraryID != "" {
+		name += "-" + libraryID
+	}
+	return name
+}
+
+// Returns the "docker run" arguments to label a container as started by this process.
+func ownerLabelArgs() []string {
+	return []string{
+		fmt.Sprintf("--label=%s=%s", runLabel, runID),
+		fmt.Sprintf("--label=%s=%d", pidLabel, os.Getpid()),
+		fmt.Sprintf("--label=%s=%s", hostLabel, hostname()),
+		fmt.Sprintf("--label=%s=%s", pidNamespaceLabel, pidNamespace()),
+	}
+}
+
+func hostname() string {
+	host, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return host
+}
+
+// Returns an identifier for the PID namespace of this process, such as "pid:[4026531836]"
+// on Linux. Processes in different namespaces can't see each other, even on the same host,
+// so process IDs are only comparable within a namespace. Where namespaces aren't
+// supported, "none" is returned, as every process on the host can be seen.
+func pidNamespace() string {
+	namespace, err := os.Readlink("/proc/self/ns/pid")
+	if err != nil {
+		return "none"
+	}
+	return namespace
+}
+
+// A container started by Librarian, as found by FindContainers.
+type LibrarianContainer struct {
+	Name string
+	// The ID of the run, and the process ID, host and PID namespace of the Librarian
+	// process which started the container.
+	RunID        string
+	Pid          int
+	Host         string
+	PidNamespace string
+	// Whether the process which started the container has exited, so that the container
+	// is no longer needed. Containers started on other hosts or in other PID namespaces
+	// are never considered stale, as it's not possible to tell whether their processes
+	// are still running.
+	Stale bool
+}
+
+// The template with which "inspect" prints the name and labels of each container,
+// parsed by parseLibrarianContainer.
+var containerInspectFormat = fmt.Sprintf(`{{.Name}}|{{index .Config.Labels %q}}|{{index .Config.Labels %q}}|{{index .Config.Labels %q}}|{{index .Config.Labels %q}}`,
+	runLabel, pidLabel, hostLabel, pidNamespaceLabel)
+
+// Lists the containers (running or otherwise) started by any Librarian process.
+func FindContainers(ctx context.Context, config *ContainerConfig) ([]LibrarianContainer, error) {
+	binary := config.runtime().Binary()
+	var stderr bytes.Buffer
+	ps := exec.CommandContext(ctx, binary, "ps", "--all", "--quiet", "--filter=label="+pidLabel)
+	ps.Stderr = &stderr
+	output, err := ps.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	ids := strings.Fields(string(output))
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	// The labels are read with inspect rather than "ps --format", as the ps templates
+	// of docker and podman differ.
+	stderr.Reset()
+	inspect := exec.CommandContext(ctx, binary, append([]string{"inspect", "--format=" + containerInspectFormat}, ids...)...)
+	inspect.Stderr = &stderr
+	if output, err = inspect.Output(); err != nil {
+		return nil, fmt.Errorf("failed to inspect containers: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	currentHost, currentNamespace := hostname(), pidNamespace()
+	containers := []LibrarianContainer{}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		container, ok := parseLibrarianContainer(line)
+		if !ok {
+			continue
+		}
+		container.Stale = isStale(container, currentHost, currentNamespace, processExists)
+		containers = append(containers, container)
+	}
+	return containers, nil
+}
+
+// Parses a line of inspect output in containerInspectFormat, whose fields are separated by
+// "|". Containers without a valid process ID label aren't Librarian containers; labels which
+// are missing (e.g. on containers started by older versions of Librarian) are printed as
+// "<no value>".
+func parseLibrarianContainer(line string) (LibrarianContainer, bool) {
+	fields := strings.Split(strings.TrimSpace(line), "|")
+	if len(fields) != 5 {
+		return LibrarianContainer{}, false
+	}
+	pid, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return LibrarianContainer{}, false
+	}
+	return LibrarianContainer{
+		Name:         strings.TrimPrefix(fields[0], "/"),
+		RunID:        fields[1],
+		Pid:          pid,
+		Host:         fields[3],
+		PidNamespace: fields[4],
+	}, true
+}
+
+// Reports whether a container is stale: its process was on this host and in this PID
+// namespace, so that processExists can tell whether it's still running, and it isn't.
+// Containers from this run are never stale.
+func isStale(container LibrarianContainer, currentHost, currentNamespace string, processExists func(int) bool) bool {
+	if container.RunID == runID {
+		return false
+	}
+	if container.Host != currentHost || container.PidNamespace != currentNamespace {
+		return false
+	}
+	return !processExists(container.Pid)
+}
+
+// Reports whether a process with the given ID exists, on Unix-like systems.
+func processExists(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	// Signal 0 checks for the process without affecting it. EPERM means it exists,
+	// but belongs to another user.
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// Forcibly removes the named containers, killing them first if they're running.
+func RemoveContainers(ctx context.Context, config *ContainerConfig, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	var stderr bytes.Buffer
+	rm := exec.CommandContext(ctx, config.runtime().Binary(), append([]string{"rm", "--force"}, names...)...)
+	rm.Stderr = &stderr
+	if err := rm.Run(); err != nil {
+		return fmt.Errorf("failed to remove containers: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// Ensures stale containers are only looked for once per process.
+var removeStaleContainersOnce sync.Once
+
+// Removes any stale containers (see LibrarianContainer.Stale) left behind by previous
+// Librarian processes, before this process starts its first container. Failures are only
+// logged, as they don't prevent this process from running containers.
+func removeStaleContainers(ctx context.Context, config *ContainerConfig) {
+	removeStaleContainersOnce.Do(func() {
+		containers, err := FindContainers(ctx, config)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("Unable to check for stale containers: %s", err))
+			return
+		}
+		names := []string{}
+		for _, container := range containers {
+			if container.Stale {
+				names = append(names, container.Name)
+			}
+		}
+		if len(names) == 0 {
+			return
+		}
+		slog.Info(fmt.Sprintf("Removing %d stale containers left by previous runs: %s", len(names), strings.Join(names, ", ")))
+		if err := RemoveContainers(ctx, config, names); err != nil {
+			slog.Warn(err.Error())
+		}
+	})
+}
@@ -0,0 +1,70 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"testing"
+)
+
+func TestParseLibrarianContainer(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected LibrarianContainer
+		ok       bool
+	}{
+		{
+			line:     "/librarian-12-abcd-1|abcd|12|host|pid:[4026531836]",
+			expected: LibrarianContainer{Name: "librarian-12-abcd-1", RunID: "abcd", Pid: 12, Host: "host", PidNamespace: "pid:[4026531836]"},
+			ok:       true,
+		},
+		{
+			// Started by an older version, without the run and namespace labels.
+			line:     "/librarian-12-1|<no value>|12|host|<no value>",
+			expected: LibrarianContainer{Name: "librarian-12-1", RunID: "<no value>", Pid: 12, Host: "host", PidNamespace: "<no value>"},
+			ok:       true,
+		},
+		{line: "/other|abcd|notapid|host|none", ok: false},
+		{line: "/librarian-12-1|12|host", ok: false},
+		{line: "", ok: false},
+	}
+	for _, test := range tests {
+		actual, ok := parseLibrarianContainer(test.line)
+		if ok != test.ok || actual != test.expected {
+			t.Errorf("parseLibrarianContainer(%q) expected %+v, %t, got %+v, %t", test.line, test.expected, test.ok, actual, ok)
+		}
+	}
+}
+
+func TestIsStale(t *testing.T) {
+	const host, namespace = "host", "pid:[1]"
+	exists := func(pid int) bool { return pid == 1 }
+	tests := []struct {
+		name      string
+		container LibrarianContainer
+		expected  bool
+	}{
+		{"exited", LibrarianContainer{RunID: "other", Pid: 2, Host: host, PidNamespace: namespace}, true},
+		{"running", LibrarianContainer{RunID: "other", Pid: 1, Host: host, PidNamespace: namespace}, false},
+		{"other host", LibrarianContainer{RunID: "other", Pid: 2, Host: "other", PidNamespace: namespace}, false},
+		{"other PID namespace", LibrarianContainer{RunID: "other", Pid: 2, Host: host, PidNamespace: "pid:[2]"}, false},
+		{"no PID namespace label", LibrarianContainer{RunID: "<no value>", Pid: 2, Host: host, PidNamespace: "<no value>"}, false},
+		{"this run", LibrarianContainer{RunID: runID, Pid: 2, Host: host, PidNamespace: namespace}, false},
+	}
+	for _, test := range tests {
+		if actual := isStale(test.container, host, namespace, exists); actual != test.expected {
+			t.Errorf("isStale(%s) expected %t, got %t", test.name, test.expected, actual)
+		}
+	}
+}
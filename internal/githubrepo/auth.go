@@ -0,0 +1,236 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package githubrepo
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/google/go-github/v69/github"
+	"github.com/googleapis/librarian/internal/offline"
+	"github.com/googleapis/librarian/internal/secrets"
+)
+
+// A source of GitHub access tokens.
+type tokenProvider interface {
+	token(ctx context.Context) (string, error)
+}
+
+// The provider used by GetAccessToken. By default, the token is read from the
+// LIBRARIAN_GITHUB_TOKEN environment variable.
+var accessTokenProvider tokenProvider = environmentTokenProvider{}
+
+// Returns the access token to use with GitHub, from whichever source has been configured:
+// a GitHub App installation (see UseGitHubApp), a token file (see LoadAccessTokenFile), a
+// secret provider (see UseSecretProvider) or, by default, the LIBRARIAN_GITHUB_TOKEN
+// environment variable. If a token can't be
+// obtained, a warning is logged and an empty string is returned.
+func GetAccessToken() string {
+	token, err := accessTokenProvider.token(context.Background())
+	if err != nil {
+		slog.Warn(fmt.Sprintf("Unable to obtain GitHub access token: %s", err))
+		return ""
+	}
+	return token
+}
+
+type environmentTokenProvider struct{}
+
+func (environmentTokenProvider) token(ctx context.Context) (string, error) {
+	return os.Getenv(gitHubTokenEnvironmentVariable), nil
+}
+
+// The name of the secret containing the access token, when it's read from a secret provider.
+// With the env provider, this is the LIBRARIAN_GITHUB_TOKEN environment variable.
+const gitHubTokenSecretName = "librarian-github-token"
+
+// Reads the access token from the LIBRARIAN_GITHUB_TOKEN environment variable if it's set,
+// or from a secret provider otherwise.
+type secretTokenProvider struct {
+	provider secrets.SecretProvider
+}
+
+func (provider secretTokenProvider) token(ctx context.Context) (string, error) {
+	if token := os.Getenv(gitHubTokenEnvironmentVariable); token != "" {
+		return token, nil
+	}
+	token, _, err := provider.provider.Secret(ctx, gitHubTokenSecretName)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret %s from %s: %w", gitHubTokenSecretName, provider.provider, err)
+	}
+	return token, nil
+}
+
+// Configures GetAccessToken to read the access token from the librarian-github-token secret
+// of the given provider, when the LIBRARIAN_GITHUB_TOKEN environment variable isn't set.
+// A token file or GitHub App configured afterwards takes precedence.
+func UseSecretProvider(provider secrets.SecretProvider) {
+	accessTokenProvider = secretTokenProvider{provider: provider}
+}
+
+type staticTokenProvider string
+
+func (provider staticTokenProvider) token(ctx context.Context) (string, error) {
+	return string(provider), nil
+}
+
+// Reads the access token from a file, or from stdin if path is "-". The token read
+// takes precedence over the LIBRARIAN_GITHUB_TOKEN environment variable. Trailing
+// whitespace (e.g. a final newline) is removed.
+func LoadAccessTokenFile(path string) error {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read GitHub token: %w", err)
+	}
+	token := strings.TrimRightFunc(string(data), unicode.IsSpace)
+	if token == "" {
+		return fmt.Errorf("GitHub token file %s is empty", path)
+	}
+	accessTokenProvider = staticTokenProvider(token)
+	return nil
+}
+
+// Installation tokens are refreshed when they're this close to expiry, so that
+// a token is never used just as it expires.
+const installationTokenRefreshMargin = 5 * time.Minute
+
+// Mints (and caches) installation access tokens for a GitHub App.
+type appTokenProvider struct {
+	appID          int64
+	installationID int64
+	privateKey     *rsa.PrivateKey
+
+	mutex     sync.Mutex
+	cached    string
+	expiresAt time.Time
+}
+
+// Configures GetAccessToken to return installation access tokens for the given GitHub App
+// installation, taking precedence over any other source of tokens. The private key file is
+// the PEM file downloaded from the App's settings. An initial token is minted immediately,
+// so that configuration problems are reported before any other work is done. Tokens are
+// refreshed as required, as each is only valid for an hour.
+func UseGitHubApp(ctx context.Context, appID, installationID int64, privateKeyFile string) error {
+	data, err := os.ReadFile(privateKeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to read GitHub App private key: %w", err)
+	}
+	privateKey, err := parseRsaPrivateKey(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse GitHub App private key: %w", err)
+	}
+	provider := &appTokenProvider{appID: appID, installationID: installationID, privateKey: privateKey}
+	if _, err := provider.token(ctx); err != nil {
+		return err
+	}
+	accessTokenProvider = provider
+	return nil
+}
+
+func (provider *appTokenProvider) token(ctx context.Context) (string, error) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	if provider.cached != "" && time.Until(provider.expiresAt) > installationTokenRefreshMargin {
+		return provider.cached, nil
+	}
+	if err := offline.Check("create a GitHub App installation token"); err != nil {
+		return "", err
+	}
+	jwt, err := provider.createJwt(time.Now())
+	if err != nil {
+		return "", err
+	}
+	client, err := configureBaseUrl(github.NewClient(newHttpClient()).WithAuthToken(jwt))
+	if err != nil {
+		return "", err
+	}
+	installationToken, _, err := client.Apps.CreateInstallationToken(ctx, provider.installationID, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create GitHub App installation token: %w", err)
+	}
+	provider.cached = installationToken.GetToken()
+	provider.expiresAt = installationToken.GetExpiresAt().Time
+	slog.Info(fmt.Sprintf("Created GitHub App installation token, expiring at %s", provider.expiresAt.Format(time.RFC3339)))
+	return provider.cached, nil
+}
+
+// Creates the JSON Web Token used to authenticate as the App itself (rather than an
+// installation), as described at
+// https://docs.github.com/en/apps/creating-github-apps/authenticating-with-a-github-app/generating-a-json-web-token-jwt-for-a-github-app
+func (provider *appTokenProvider) createJwt(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	// The issued-at time is backdated to allow for clock drift; the maximum lifetime is 10 minutes.
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": fmt.Sprintf("%d", provider.appID),
+	})
+	if err != nil {
+		return "", err
+	}
+	encoding := base64.RawURLEncoding
+	unsigned := encoding.EncodeToString(header) + "." + encoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, provider.privateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + encoding.EncodeToString(signature), nil
+}
+
+// Parses a PEM-encoded RSA private key, in either PKCS #1 form (as GitHub provides) or PKCS #8 form.
+func parseRsaPrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not an RSA key")
+	}
+	return rsaKey, nil
+}
@@ -20,10 +20,16 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/http"
+	"net/url"
 	"os"
+	"regexp"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/google/go-github/v69/github"
+	"github.com/googleapis/librarian/internal/offline"
 )
 
 type GitHubRepo struct {
@@ -34,23 +40,55 @@ type GitHubRepo struct {
 type PullRequestMetadata struct {
 	Repo   GitHubRepo
 	Number int
+	// The pull request's head branch.
+	Branch string
+	// The URL of the pull request in the GitHub web UI.
+	URL string
+	// Whether the pull request was created as a draft.
+	Draft bool
+	// The labels successfully applied to the pull request by Librarian after creation.
+	Labels []string
 }
 
 const gitHubTokenEnvironmentVariable string = "LIBRARIAN_GITHUB_TOKEN"
 
+// The environment variable which may be set to the base URL of a GitHub Enterprise Server
+// instance, e.g. https://github.mycorp.com. If it's not set, github.com is used.
+const gitHubBaseUrlEnvironmentVariable string = "LIBRARIAN_GITHUB_BASE_URL"
+
+const defaultGitHubBaseUrl string = "https://github.com/"
+
+// The OAuth scopes (for classic personal access tokens), at least one of which is
+// required in order to push branches and create pull requests and releases.
+var sufficientTokenScopes = []string{"repo", "public_repo"}
+
 // Creates a pull request in the remote repo. At the moment this requires a single remote to be
-// configured, which must have a GitHub HTTPS URL. We assume a base branch of "main".
-func CreatePullRequest(ctx context.Context, repo GitHubRepo, remoteBranch string, title string, body string) (*PullRequestMetadata, error) {
+// configured, which must have a GitHub HTTPS URL. The base branch defaults to "main".
+// If headOwner is non-empty and differs from the repo's owner, the branch is in that owner's
+// fork of the repo, creating a cross-repository pull request.
+// If draft is true, the pull request is created as a draft.
+func CreatePullRequest(ctx context.Context, repo GitHubRepo, headOwner string, remoteBranch string, baseBranch string, title string, body string, draft bool) (*PullRequestMetadata, error) {
 	if body == "" {
 		body = "Regenerated all changed APIs. See individual commits for details."
 	}
-	gitHubClient := createClient()
+	gitHubClient, err := createClient()
+	if err != nil {
+		return nil, err
+	}
+	if baseBranch == "" {
+		baseBranch = "main"
+	}
+	head := remoteBranch
+	if headOwner != "" && headOwner != repo.Owner {
+		head = headOwner + ":" + remoteBranch
+	}
 	newPR := &github.NewPullRequest{
 		Title:               &title,
-		Head:                &remoteBranch,
-		Base:                github.Ptr("main"),
+		Head:                &head,
+		Base:                github.Ptr(baseBranch),
 		Body:                github.Ptr(body),
 		MaintainerCanModify: github.Ptr(true),
+		Draft:               github.Ptr(draft),
 	}
 	pr, _, err := gitHubClient.PullRequests.Create(ctx, repo.Owner, repo.Name, newPR)
 	if err != nil {
@@ -58,12 +96,15 @@ func CreatePullRequest(ctx context.Context, repo GitHubRepo, remoteBranch string
 	}
 
 	fmt.Printf("PR created: %s\n", pr.GetHTMLURL())
-	pullRequestMetadata := &PullRequestMetadata{Repo: repo, Number: pr.GetNumber()}
+	pullRequestMetadata := &PullRequestMetadata{Repo: repo, Number: pr.GetNumber(), Branch: remoteBranch, URL: pr.GetHTMLURL(), Draft: pr.GetDraft()}
 	return pullRequestMetadata, nil
 }
 
 func CreateRelease(ctx context.Context, repo GitHubRepo, tag, commit, title, description string, prerelease bool) (*github.RepositoryRelease, error) {
-	gitHubClient := createClient()
+	gitHubClient, err := createClient()
+	if err != nil {
+		return nil, err
+	}
 
 	release := &github.RepositoryRelease{
 		TagName:         &tag,
@@ -76,16 +117,19 @@ func CreateRelease(ctx context.Context, repo GitHubRepo, tag, commit, title, des
 		// TODO: Check whether this is what we want
 		GenerateReleaseNotes: github.Ptr(false),
 	}
-	release, _, err := gitHubClient.Repositories.CreateRelease(ctx, repo.Owner, repo.Name, release)
+	release, _, err = gitHubClient.Repositories.CreateRelease(ctx, repo.Owner, repo.Name, release)
 	return release, err
 }
 
 func AddLabelToPullRequest(ctx context.Context, prMetadata PullRequestMetadata, label string) error {
-	gitHubClient := createClient()
+	gitHubClient, err := createClient()
+	if err != nil {
+		return err
+	}
 
 	labels := []string{label}
 
-	_, _, err := gitHubClient.Issues.AddLabelsToIssue(ctx, prMetadata.Repo.Owner, prMetadata.Repo.Name, prMetadata.Number, labels)
+	_, _, err = gitHubClient.Issues.AddLabelsToIssue(ctx, prMetadata.Repo.Owner, prMetadata.Repo.Name, prMetadata.Number, labels)
 	if err != nil {
 		return fmt.Errorf("failed to add label: %w", err)
 	}
@@ -93,48 +137,321 @@ func AddLabelToPullRequest(ctx context.Context, prMetadata PullRequestMetadata,
 }
 
 func RemoveLabelFromPullRequest(ctx context.Context, repo GitHubRepo, prNumber int, label string) error {
-	gitHubClient := createClient()
+	gitHubClient, err := createClient()
+	if err != nil {
+		return err
+	}
 
-	_, err := gitHubClient.Issues.RemoveLabelForIssue(ctx, repo.Owner, repo.Name, prNumber, label)
+	_, err = gitHubClient.Issues.RemoveLabelForIssue(ctx, repo.Owner, repo.Name, prNumber, label)
 	if err != nil {
 		return fmt.Errorf("failed to remove label: %w", err)
 	}
 	return nil
 }
 
+// Requests reviews of a pull request. Each reviewer is either a username, or a team
+// in the form "org/team".
+func RequestReviewers(ctx context.Context, prMetadata PullRequestMetadata, reviewers []string) error {
+	gitHubClient, err := createClient()
+	if err != nil {
+		return err
+	}
+	request := github.ReviewersRequest{}
+	for _, reviewer := range reviewers {
+		if _, team, isTeam := strings.Cut(reviewer, "/"); isTeam {
+			request.TeamReviewers = append(request.TeamReviewers, team)
+		} else {
+			request.Reviewers = append(request.Reviewers, reviewer)
+		}
+	}
+	_, _, err = gitHubClient.PullRequests.RequestReviewers(ctx, prMetadata.Repo.Owner, prMetadata.Repo.Name, prMetadata.Number, request)
+	if err != nil {
+		return fmt.Errorf("failed to request reviewers: %w", err)
+	}
+	return nil
+}
+
+// Adds assignees (usernames) to a pull request.
+func AddAssignees(ctx context.Context, prMetadata PullRequestMetadata, assignees []string) error {
+	gitHubClient, err := createClient()
+	if err != nil {
+		return err
+	}
+	_, _, err = gitHubClient.Issues.AddAssignees(ctx, prMetadata.Repo.Owner, prMetadata.Repo.Name, prMetadata.Number, assignees)
+	if err != nil {
+		return fmt.Errorf("failed to add assignees: %w", err)
+	}
+	return nil
+}
+
 func AddCommentToPullRequest(ctx context.Context, repo GitHubRepo, prNumber int, comment string) error {
-	gitHubClient := createClient()
+	gitHubClient, err := createClient()
+	if err != nil {
+		return err
+	}
 	issueComment := &github.IssueComment{
 		Body: &comment,
 	}
-	_, _, err := gitHubClient.Issues.CreateComment(ctx, repo.Owner, repo.Name, prNumber, issueComment)
+	_, _, err = gitHubClient.Issues.CreateComment(ctx, repo.Owner, repo.Name, prNumber, issueComment)
 	if err != nil {
 		return fmt.Errorf("failed to add comment: %w", err)
 	}
 	return nil
 }
 
-func MergePullRequest(ctx context.Context, repo GitHubRepo, prNumber int, method github.MergeMethod) (*github.PullRequestMergeResult, error) {
-	gitHubClient := createClient()
+// Merges a pull request with the given method. The commit title and message are only
+// used for squash (and merge commit) merges; if they're empty, GitHub's defaults are used.
+func MergePullRequest(ctx context.Context, repo GitHubRepo, prNumber int, method github.MergeMethod, commitTitle, commitMessage string) (*github.PullRequestMergeResult, error) {
+	gitHubClient, err := createClient()
+	if err != nil {
+		return nil, err
+	}
 
 	options := &github.PullRequestOptions{
 		MergeMethod: string(method),
+		CommitTitle: commitTitle,
 	}
-	result, _, err := gitHubClient.PullRequests.Merge(ctx, repo.Owner, repo.Name, prNumber, "", options)
+	result, _, err := gitHubClient.PullRequests.Merge(ctx, repo.Owner, repo.Name, prNumber, commitMessage, options)
 	if err != nil {
 		return nil, fmt.Errorf("failed to merge pull request: %w", err)
 	}
 	return result, nil
 }
 
+// Enables auto-merge for a pull request, so that it's merged with the given method
+// ("MERGE", "SQUASH" or "REBASE") once all requirements (e.g. checks) are met.
+// This is only available via the GraphQL API. An error is returned if auto-merge
+// can't be enabled, for example because it's disabled for the repository.
+func EnablePullRequestAutoMerge(ctx context.Context, prMetadata PullRequestMetadata, method string) error {
+	gitHubClient, err := createClient()
+	if err != nil {
+		return err
+	}
+	pr, _, err := gitHubClient.PullRequests.Get(ctx, prMetadata.Repo.Owner, prMetadata.Repo.Name, prMetadata.Number)
+	if err != nil {
+		return fmt.Errorf("failed to get pull request: %w", err)
+	}
+	query := map[string]interface{}{
+		"query": `mutation($id: ID!, $method: PullRequestMergeMethod!) {
+  enablePullRequestAutoMerge(input: {pullRequestId: $id, mergeMethod: $method}) {
+    clientMutationId
+  }
+}`,
+		"variables": map[string]interface{}{
+			"id":     pr.GetNodeID(),
+			"method": method,
+		},
+	}
+	request, err := gitHubClient.NewRequest(http.MethodPost, graphqlUrl(), query)
+	if err != nil {
+		return err
+	}
+	var response struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if _, err := gitHubClient.Do(ctx, request, &response); err != nil {
+		return fmt.Errorf("failed to enable auto-merge: %w", err)
+	}
+	if len(response.Errors) > 0 {
+		messages := []string{}
+		for _, e := range response.Errors {
+			messages = append(messages, e.Message)
+		}
+		return fmt.Errorf("failed to enable auto-merge: %s", strings.Join(messages, "; "))
+	}
+	return nil
+}
+
 func GetPullRequest(ctx context.Context, repo GitHubRepo, prNumber int) (*github.PullRequest, error) {
-	gitHubClient := createClient()
+	gitHubClient, err := createClient()
+	if err != nil {
+		return nil, err
+	}
 	pr, _, err := gitHubClient.PullRequests.Get(ctx, repo.Owner, repo.Name, prNumber)
 	return pr, err
 }
 
+// The criteria for FindPullRequests. Empty fields match any pull request.
+type PullRequestFilter struct {
+	// A label which the pull request must have.
+	Label string
+	// The login of the user (e.g. a bot account) who must have created the pull request.
+	Author string
+	// A prefix which the pull request's head branch must have.
+	BranchPrefix string
+	// If non-zero, the pull request must have been created before this time.
+	CreatedBefore time.Time
+	// The repo containing the pull request's head branch, e.g. a fork. If empty, the
+	// head branch must be in the repo itself.
+	HeadRepo GitHubRepo
+	// The pull request's base branch. Defaults to main.
+	BaseBranch string
+}
+
+// Reports whether the pull request matches all the filter's criteria.
+func (filter PullRequestFilter) matches(pr *github.PullRequest) bool {
+	if filter.Author != "" && !strings.EqualFold(pr.GetUser().GetLogin(), filter.Author) {
+		return false
+	}
+	if !strings.HasPrefix(pr.GetHead().GetRef(), filter.BranchPrefix) {
+		return false
+	}
+	if !filter.CreatedBefore.IsZero() && !pr.GetCreatedAt().Before(filter.CreatedBefore) {
+		return false
+	}
+	if filter.Label == "" {
+		return true
+	}
+	for _, label := range pr.Labels {
+		if label.GetName() == filter.Label {
+			return true
+		}
+	}
+	return false
+}
+
+// Finds the open pull requests in the repo (with main as their base branch and their head
+// branches in the same repo, unless the filter specifies otherwise) which match the filter,
+// most recently created first.
+func FindPullRequests(ctx context.Context, repo GitHubRepo, filter PullRequestFilter) ([]*PullRequestMetadata, error) {
+	gitHubClient, err := createClient()
+	if err != nil {
+		return nil, err
+	}
+	baseBranch := filter.BaseBranch
+	if baseBranch == "" {
+		baseBranch = "main"
+	}
+	options := &github.PullRequestListOptions{
+		State:       "open",
+		Base:        baseBranch,
+		Sort:        "created",
+		Direction:   "desc",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	headRepo := filter.HeadRepo
+	if headRepo == (GitHubRepo{}) {
+		headRepo = repo
+	}
+	matches := []*PullRequestMetadata{}
+	for {
+		prs, response, err := gitHubClient.PullRequests.List(ctx, repo.Owner, repo.Name, options)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pull requests: %w", err)
+		}
+		for _, pr := range prs {
+			prHeadRepo := pr.GetHead().GetRepo()
+			if prHeadRepo.GetOwner().GetLogin() == headRepo.Owner && prHeadRepo.GetName() == headRepo.Name && filter.matches(pr) {
+				matches = append(matches, &PullRequestMetadata{Repo: repo, Number: pr.GetNumber(), Branch: pr.GetHead().GetRef(), URL: pr.GetHTMLURL(), Draft: pr.GetDraft()})
+			}
+		}
+		if response.NextPage == 0 {
+			return matches, nil
+		}
+		options.Page = response.NextPage
+	}
+}
+
+// Finds the authenticated user's fork of the repo, which is expected to have the same name
+// as the repo. This requires a token for a user rather than for a GitHub App.
+func FindFork(ctx context.Context, repo GitHubRepo) (GitHubRepo, error) {
+	gitHubClient, err := createClient()
+	if err != nil {
+		return GitHubRepo{}, err
+	}
+	user, _, err := gitHubClient.Users.Get(ctx, "")
+	if err != nil {
+		return GitHubRepo{}, fmt.Errorf("failed to determine authenticated user: %w", err)
+	}
+	login := user.GetLogin()
+	fork, _, err := gitHubClient.Repositories.Get(ctx, login, repo.Name)
+	if err != nil {
+		return GitHubRepo{}, fmt.Errorf("failed to find fork of %s/%s for %s: %w", repo.Owner, repo.Name, login, err)
+	}
+	parent := fork.GetParent()
+	if !fork.GetFork() || parent.GetOwner().GetLogin() != repo.Owner || parent.GetName() != repo.Name {
+		return GitHubRepo{}, fmt.Errorf("%s/%s is not a fork of %s/%s", login, repo.Name, repo.Owner, repo.Name)
+	}
+	return CreateGitHubRepoFromRepository(fork), nil
+}
+
+// Closes a pull request without merging it.
+func ClosePullRequest(ctx context.Context, prMetadata PullRequestMetadata) error {
+	gitHubClient, err := createClient()
+	if err != nil {
+		return err
+	}
+	update := &github.PullRequest{State: github.Ptr("closed")}
+	if _, _, err := gitHubClient.PullRequests.Edit(ctx, prMetadata.Repo.Owner, prMetadata.Repo.Name, prMetadata.Number, update); err != nil {
+		return fmt.Errorf("failed to close pull request %d: %w", prMetadata.Number, err)
+	}
+	return nil
+}
+
+// Reports whether the branch exists in the repo.
+func BranchExists(ctx context.Context, repo GitHubRepo, branch string) (bool, error) {
+	gitHubClient, err := createClient()
+	if err != nil {
+		return false, err
+	}
+	_, response, err := gitHubClient.Repositories.GetBranch(ctx, repo.Owner, repo.Name, branch, 0)
+	if response != nil && response.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get branch %s: %w", branch, err)
+	}
+	return true, nil
+}
+
+// Returns the name of the repo's default branch.
+func GetDefaultBranch(ctx context.Context, repo GitHubRepo) (string, error) {
+	gitHubClient, err := createClient()
+	if err != nil {
+		return "", err
+	}
+	repository, _, err := gitHubClient.Repositories.Get(ctx, repo.Owner, repo.Name)
+	if err != nil {
+		return "", fmt.Errorf("failed to get repo %s/%s: %w", repo.Owner, repo.Name, err)
+	}
+	return repository.GetDefaultBranch(), nil
+}
+
+// Deletes a branch in the repo, without requiring a local clone.
+func DeleteBranch(ctx context.Context, repo GitHubRepo, branch string) error {
+	gitHubClient, err := createClient()
+	if err != nil {
+		return err
+	}
+	if _, err := gitHubClient.Git.DeleteRef(ctx, repo.Owner, repo.Name, "heads/"+branch); err != nil {
+		return fmt.Errorf("failed to delete branch %s: %w", branch, err)
+	}
+	return nil
+}
+
+// Updates the title and body of a pull request.
+func UpdatePullRequest(ctx context.Context, prMetadata PullRequestMetadata, title, body string) error {
+	gitHubClient, err := createClient()
+	if err != nil {
+		return err
+	}
+	update := &github.PullRequest{
+		Title: &title,
+		Body:  &body,
+	}
+	if _, _, err := gitHubClient.PullRequests.Edit(ctx, prMetadata.Repo.Owner, prMetadata.Repo.Name, prMetadata.Number, update); err != nil {
+		return fmt.Errorf("failed to update pull request: %w", err)
+	}
+	return nil
+}
+
 func GetPullRequestCheckRuns(ctx context.Context, pullRequest *github.PullRequest) ([]*github.CheckRun, error) {
-	gitHubClient := createClient()
+	gitHubClient, err := createClient()
+	if err != nil {
+		return nil, err
+	}
 	prHead := pullRequest.Head
 	options := &github.ListCheckRunsOptions{}
 	checkRuns, _, err := gitHubClient.Checks.ListCheckRunsForRef(ctx, *prHead.User.Login, *prHead.Repo.Name, *prHead.Ref, options)
@@ -145,33 +462,88 @@ func GetPullRequestCheckRuns(ctx context.Context, pullRequest *github.PullReques
 }
 
 func GetPullRequestReviews(ctx context.Context, prMetadata PullRequestMetadata) ([]*github.PullRequestReview, error) {
-	gitHubClient := createClient()
+	gitHubClient, err := createClient()
+	if err != nil {
+		return nil, err
+	}
 	// TODO: Implement pagination or use go-github-paginate
 	listOptions := &github.ListOptions{PerPage: 100}
 	reviews, _, err := gitHubClient.PullRequests.ListReviews(ctx, prMetadata.Repo.Owner, prMetadata.Repo.Name, prMetadata.Number, listOptions)
 	return reviews, err
 }
 
+// Returns the base URL of the GitHub web UI (and git remotes), always ending in a slash.
+// This is https://github.com/ unless a GitHub Enterprise Server base URL is configured
+// via the LIBRARIAN_GITHUB_BASE_URL environment variable.
+func BaseUrl() string {
+	baseUrl := os.Getenv(gitHubBaseUrlEnvironmentVariable)
+	if baseUrl == "" {
+		return defaultGitHubBaseUrl
+	}
+	return strings.TrimSuffix(baseUrl, "/") + "/"
+}
+
 // Parses a GitHub URL (anything to do with a repository) to determine
-// the GitHub repo details (owner and name)
+// the GitHub repo details (owner and name). As well as HTTPS URLs, SSH remote
+// URLs of the form git@github.com:owner/repo.git and ssh://git@github.com/owner/repo.git
+// are supported.
 func ParseUrl(remoteUrl string) (GitHubRepo, error) {
-	if !strings.HasPrefix(remoteUrl, "https://github.com/") {
+	baseUrl := BaseUrl()
+	host := baseUrlHost()
+	var remotePath string
+	if strings.HasPrefix(remoteUrl, baseUrl) {
+		remotePath = remoteUrl[len(baseUrl):]
+	} else if match := scpLikeUrlRegex.FindStringSubmatch(remoteUrl); match != nil && match[1] == host {
+		remotePath = match[2]
+	} else if sshPrefix := "ssh://git@" + host + "/"; strings.HasPrefix(remoteUrl, sshPrefix) {
+		remotePath = remoteUrl[len(sshPrefix):]
+	} else {
 		return GitHubRepo{}, fmt.Errorf("remote '%s' is not a GitHub remote", remoteUrl)
 	}
-	remotePath := remoteUrl[len("https://github.com/"):]
 	pathParts := strings.Split(remotePath, "/")
+	if len(pathParts) < 2 {
+		return GitHubRepo{}, fmt.Errorf("remote '%s' does not specify a GitHub repository", remoteUrl)
+	}
 	organization := pathParts[0]
 	repoName := pathParts[1]
 	repoName = strings.TrimSuffix(repoName, ".git")
 	return GitHubRepo{Owner: organization, Name: repoName}, nil
 }
 
+// Returns the HTTPS remote URL for the repo, in the form https://github.com/owner/repo.git.
+func HTTPSUrl(repo GitHubRepo) string {
+	return fmt.Sprintf("%s%s/%s.git", BaseUrl(), repo.Owner, repo.Name)
+}
+
+// Returns the SSH remote URL for the repo, in the form git@github.com:owner/repo.git.
+func SSHUrl(repo GitHubRepo) string {
+	return fmt.Sprintf("git@%s:%s/%s.git", baseUrlHost(), repo.Owner, repo.Name)
+}
+
+// Matches scp-like SSH URLs (user@host:path), capturing the host and path.
+var scpLikeUrlRegex = regexp.MustCompile(`^[A-Za-z0-9._-]+@([A-Za-z0-9.-]+):(.+)$`)
+
+// Returns the host name of the GitHub base URL.
+func baseUrlHost() string {
+	parsed, err := url.Parse(BaseUrl())
+	if err != nil {
+		return ""
+	}
+	return parsed.Host
+}
+
 func CreateGitHubRepoFromRepository(repo *github.Repository) GitHubRepo {
 	return GitHubRepo{Owner: *repo.Owner.Login, Name: *repo.Name}
 }
 
 func GetRawContent(ctx context.Context, repo GitHubRepo, path, ref string) ([]byte, error) {
-	gitHubClient := github.NewClient(nil)
+	if err := offline.Check(fmt.Sprintf("fetch %s from GitHub repo %s/%s", path, repo.Owner, repo.Name)); err != nil {
+		return nil, err
+	}
+	gitHubClient, err := configureBaseUrl(github.NewClient(newHttpClient()))
+	if err != nil {
+		return nil, err
+	}
 	options := &github.RepositoryContentGetOptions{
 		Ref: ref,
 	}
@@ -184,7 +556,10 @@ func GetRawContent(ctx context.Context, repo GitHubRepo, path, ref string) ([]by
 }
 
 func GetDiffCommits(ctx context.Context, repo GitHubRepo, source, target string) ([]*github.RepositoryCommit, error) {
-	gitHubClient := createClient()
+	gitHubClient, err := createClient()
+	if err != nil {
+		return nil, err
+	}
 	// TODO: Implement pagination or use go-github-paginate
 	listOptions := &github.ListOptions{PerPage: 100}
 	commitsComparison, _, err := gitHubClient.Repositories.CompareCommits(ctx, repo.Owner, repo.Name, source, target, listOptions)
@@ -192,18 +567,83 @@ func GetDiffCommits(ctx context.Context, repo GitHubRepo, source, target string)
 }
 
 func GetCommit(ctx context.Context, repo GitHubRepo, sha string) (*github.RepositoryCommit, error) {
-	gitHubClient := createClient()
+	gitHubClient, err := createClient()
+	if err != nil {
+		return nil, err
+	}
 	// TODO: Implement pagination or use go-github-paginate (if necessary)
 	listOptions := &github.ListOptions{PerPage: 100}
 	commit, _, err := gitHubClient.Repositories.GetCommit(ctx, repo.Owner, repo.Name, sha, listOptions)
 	return commit, err
 }
 
-func createClient() *github.Client {
+// Checks that the access token is accepted by GitHub and, where GitHub reports the
+// scopes of the token (as it does for classic personal access tokens via the
+// X-OAuth-Scopes header), that it has a scope permitting pushing and creating pull
+// requests. Other kinds of token (e.g. fine-grained tokens) don't report scopes, so
+// only their validity can be checked before they're used.
+func CheckAccessTokenScopes(ctx context.Context) error {
+	gitHubClient, err := createClient()
+	if err != nil {
+		return err
+	}
+	_, response, err := gitHubClient.RateLimit.Get(ctx)
+	if err != nil {
+		if response != nil && response.StatusCode == http.StatusUnauthorized {
+			return fmt.Errorf("GitHub access token was rejected; check that %s is valid and unexpired", gitHubTokenEnvironmentVariable)
+		}
+		return fmt.Errorf("failed to verify GitHub access token: %w", err)
+	}
+	scopesHeader, reported := response.Header[http.CanonicalHeaderKey("X-OAuth-Scopes")]
+	if !reported {
+		return nil
+	}
+	var scopes []string
+	for _, scope := range strings.Split(strings.Join(scopesHeader, ","), ",") {
+		scopes = append(scopes, strings.TrimSpace(scope))
+	}
+	for _, scope := range sufficientTokenScopes {
+		if slices.Contains(scopes, scope) {
+			return nil
+		}
+	}
+	return fmt.Errorf("GitHub access token has insufficient scopes to push and create pull requests; require one of [%s], token has [%s]",
+		strings.Join(sufficientTokenScopes, ", "), strings.Join(scopes, ", "))
+}
+
+func createClient() (*github.Client, error) {
+	if err := offline.Check("access the GitHub API"); err != nil {
+		return nil, err
+	}
 	accessToken := GetAccessToken()
-	return github.NewClient(nil).WithAuthToken(accessToken)
+	return configureBaseUrl(github.NewClient(newHttpClient()).WithAuthToken(accessToken))
+}
+
+// Creates an HTTP client which retries requests failing due to rate limits or server errors.
+func newHttpClient() *http.Client {
+	return &http.Client{Transport: &retryTransport{base: http.DefaultTransport}}
 }
 
-func GetAccessToken() string {
-	return os.Getenv(gitHubTokenEnvironmentVariable)
+// Points the client at the API endpoints of the GitHub Enterprise Server instance, if one is configured.
+func configureBaseUrl(client *github.Client) (*github.Client, error) {
+	baseUrl := BaseUrl()
+	if baseUrl == defaultGitHubBaseUrl {
+		return client, nil
+	}
+	// go-github adds the api/v3/ and api/uploads/ suffixes as required.
+	client, err := client.WithEnterpriseURLs(baseUrl, baseUrl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", gitHubBaseUrlEnvironmentVariable, err)
+	}
+	return client, nil
+}
+
+// Returns the URL for GraphQL requests, relative to the client's base URL where possible.
+// GitHub Enterprise Server serves GraphQL at api/graphql rather than under the REST API's api/v3/.
+func graphqlUrl() string {
+	baseUrl := BaseUrl()
+	if baseUrl == defaultGitHubBaseUrl {
+		return "graphql"
+	}
+	return baseUrl + "api/graphql"
 }
@@ -0,0 +1,148 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package githubrepo
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultMaxRetries   = 3
+	initialRetryBackoff = time.Second
+	maxRetryBackoff     = 30 * time.Second
+	// Rate limits which won't reset within this long aren't waited for.
+	maxRateLimitWait = 5 * time.Minute
+)
+
+// The maximum number of times a GitHub API request is retried.
+var maxRetries = defaultMaxRetries
+
+// Sets the maximum number of times each GitHub API request is retried after a rate-limit
+// or server error response. Zero disables retries.
+func SetMaxRetries(retries int) {
+	maxRetries = max(retries, 0)
+}
+
+// An http.RoundTripper which retries requests which fail due to rate limiting
+// (403 or 429 responses indicating a primary or secondary rate limit), waiting as
+// indicated by the Retry-After or X-RateLimit-Reset headers, and requests which
+// fail with server errors (5xx responses), using exponential backoff. Requests which
+// aren't idempotent (e.g. the POST creating a pull request) may have been applied even
+// if a server error is returned, so they're only retried after a rate limit response
+// with a Retry-After header, which GitHub only returns for requests it rejected.
+type retryTransport struct {
+	base http.RoundTripper
+}
+
+func (transport *retryTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	attempts := maxRetries + 1
+	backoff := initialRetryBackoff
+	for attempt := 1; ; attempt++ {
+		attemptRequest := request
+		if attempt > 1 && request.Body != nil {
+			// The body has been consumed by the previous attempt.
+			if request.GetBody == nil {
+				return nil, fmt.Errorf("cannot retry %s %s: request body can't be replayed", request.Method, request.URL)
+			}
+			body, err := request.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptRequest = request.Clone(request.Context())
+			attemptRequest.Body = body
+		}
+		response, err := transport.base.RoundTrip(attemptRequest)
+		if err != nil || attempt >= attempts {
+			return response, err
+		}
+		wait, retry := retryDelay(request.Method, response, backoff)
+		if !retry {
+			return response, nil
+		}
+		slog.Warn(fmt.Sprintf("GitHub request %s %s failed with status %d on attempt %d of %d; retrying in %s",
+			request.Method, request.URL.Path, response.StatusCode, attempt, attempts, wait))
+		// Drain and close the body so that the connection can be reused.
+		io.Copy(io.Discard, response.Body)
+		response.Body.Close()
+		select {
+		case <-request.Context().Done():
+			return nil, request.Context().Err()
+		case <-time.After(wait):
+		}
+		backoff = min(backoff*2, maxRetryBackoff)
+	}
+}
+
+// Reports whether requests with the given method can safely be repeated.
+func isIdempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
+
+// Determines whether a response to a request with the given method should be retried and,
+// if so, how long to wait first. The backoff is used for server errors, and for rate limits
+// which don't specify a delay.
+func retryDelay(method string, response *http.Response, backoff time.Duration) (time.Duration, bool) {
+	if !isIdempotentMethod(method) {
+		if response.StatusCode != http.StatusForbidden && response.StatusCode != http.StatusTooManyRequests {
+			return 0, false
+		}
+		seconds, err := strconv.Atoi(response.Header.Get("Retry-After"))
+		if err != nil {
+			return 0, false
+		}
+		return capRateLimitWait(time.Duration(seconds) * time.Second)
+	}
+	switch {
+	case response.StatusCode >= 500:
+		return backoff, true
+	case response.StatusCode == http.StatusForbidden || response.StatusCode == http.StatusTooManyRequests:
+		if retryAfter := response.Header.Get("Retry-After"); retryAfter != "" {
+			if seconds, err := strconv.Atoi(retryAfter); err == nil {
+				return capRateLimitWait(time.Duration(seconds) * time.Second)
+			}
+		}
+		if response.Header.Get("X-RateLimit-Remaining") == "0" {
+			if reset, err := strconv.ParseInt(response.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+				return capRateLimitWait(time.Until(time.Unix(reset, 0)) + time.Second)
+			}
+			return backoff, true
+		}
+		// A 429 is always a rate limit, but a 403 without rate limit headers is a genuine
+		// permission error.
+		if response.StatusCode == http.StatusTooManyRequests {
+			return backoff, true
+		}
+		return 0, false
+	default:
+		return 0, false
+	}
+}
+
+func capRateLimitWait(wait time.Duration) (time.Duration, bool) {
+	if wait > maxRateLimitWait {
+		return 0, false
+	}
+	return max(wait, 0), true
+}
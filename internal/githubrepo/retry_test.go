@@ -0,0 +1,162 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package githubrepo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	const backoff = 2 * time.Second
+	tests := []struct {
+		name      string
+		method    string
+		status    int
+		headers   map[string]string
+		wantRetry bool
+		wantWait  time.Duration
+	}{
+		{
+			name:      "GET server error",
+			method:    http.MethodGet,
+			status:    http.StatusBadGateway,
+			wantRetry: true,
+			wantWait:  backoff,
+		},
+		{
+			name:      "PUT server error",
+			method:    http.MethodPut,
+			status:    http.StatusInternalServerError,
+			wantRetry: true,
+			wantWait:  backoff,
+		},
+		{
+			name:      "POST server error",
+			method:    http.MethodPost,
+			status:    http.StatusBadGateway,
+			wantRetry: false,
+		},
+		{
+			name:      "PATCH server error",
+			method:    http.MethodPatch,
+			status:    http.StatusServiceUnavailable,
+			wantRetry: false,
+		},
+		{
+			name:      "GET secondary rate limit",
+			method:    http.MethodGet,
+			status:    http.StatusForbidden,
+			headers:   map[string]string{"Retry-After": "7"},
+			wantRetry: true,
+			wantWait:  7 * time.Second,
+		},
+		{
+			name:      "POST secondary rate limit",
+			method:    http.MethodPost,
+			status:    http.StatusForbidden,
+			headers:   map[string]string{"Retry-After": "7"},
+			wantRetry: true,
+			wantWait:  7 * time.Second,
+		},
+		{
+			name:      "POST 429 without Retry-After",
+			method:    http.MethodPost,
+			status:    http.StatusTooManyRequests,
+			wantRetry: false,
+		},
+		{
+			name:      "POST primary rate limit without Retry-After",
+			method:    http.MethodPost,
+			status:    http.StatusForbidden,
+			headers:   map[string]string{"X-RateLimit-Remaining": "0"},
+			wantRetry: false,
+		},
+		{
+			name:      "GET primary rate limit without reset",
+			method:    http.MethodGet,
+			status:    http.StatusForbidden,
+			headers:   map[string]string{"X-RateLimit-Remaining": "0"},
+			wantRetry: true,
+			wantWait:  backoff,
+		},
+		{
+			name:      "GET 429",
+			method:    http.MethodGet,
+			status:    http.StatusTooManyRequests,
+			wantRetry: true,
+			wantWait:  backoff,
+		},
+		{
+			name:      "GET permission error",
+			method:    http.MethodGet,
+			status:    http.StatusForbidden,
+			wantRetry: false,
+		},
+		{
+			name:      "GET rate limit too far in the future",
+			method:    http.MethodGet,
+			status:    http.StatusTooManyRequests,
+			headers:   map[string]string{"Retry-After": "3600"},
+			wantRetry: false,
+		},
+		{
+			name:      "GET success",
+			method:    http.MethodGet,
+			status:    http.StatusOK,
+			wantRetry: false,
+		},
+	}
+	for _, test := range tests {
+		response := &http.Response{StatusCode: test.status, Header: http.Header{}}
+		for name, value := range test.headers {
+			response.Header.Set(name, value)
+		}
+		wait, retry := retryDelay(test.method, response, backoff)
+		if retry != test.wantRetry {
+			t.Errorf("%s: retryDelay() retry = %t, want %t", test.name, retry, test.wantRetry)
+			continue
+		}
+		if retry && wait != test.wantWait {
+			t.Errorf("%s: retryDelay() wait = %s, want %s", test.name, wait, test.wantWait)
+		}
+	}
+}
+
+func TestRetryTransportDoesNotRetryPostOnServerError(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &retryTransport{base: http.DefaultTransport}}
+	response, err := client.Post(server.URL+"/repos/o/r/pulls", "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusBadGateway {
+		t.Errorf("got status %d, want %d", response.StatusCode, http.StatusBadGateway)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("server received %d requests, want 1", got)
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"regexp"
 	"slices"
 	"strings"
 	"time"
@@ -28,10 +29,53 @@ import (
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
 	"github.com/googleapis/librarian/internal/githubrepo"
+	"github.com/googleapis/librarian/internal/offline"
 )
 
+// Credentials used to authenticate with remotes. HTTPS remotes use the access token;
+// SSH remotes use the private key in SSHKeyFile if it's specified, or the SSH agent otherwise.
+type Credentials struct {
+	AccessToken string
+	SSHKeyFile  string
+}
+
+// Matches scp-like SSH URLs (user@host:path).
+var scpLikeUrlRegex = regexp.MustCompile(`^[A-Za-z0-9._-]+@[A-Za-z0-9.-]+:`)
+
+// Reports whether the URL refers to a remote accessed over SSH, either as an
+// ssh:// URL or in the scp-like form git@github.com:owner/repo.git.
+func IsSSHUrl(remoteURL string) bool {
+	return strings.HasPrefix(remoteURL, "ssh://") || scpLikeUrlRegex.MatchString(remoteURL)
+}
+
+// Returns the authentication method to use with the given remote URL.
+func (credentials Credentials) authFor(remoteURL string) (transport.AuthMethod, error) {
+	if !IsSSHUrl(remoteURL) {
+		return &http.BasicAuth{
+			// GitHub ignores the username for personal access tokens, but requires
+			// this username for GitHub App installation tokens.
+			Username: "x-access-token",
+			Password: credentials.AccessToken,
+		}, nil
+	}
+	if credentials.SSHKeyFile != "" {
+		auth, err := ssh.NewPublicKeysFromFile("git", credentials.SSHKeyFile, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to load SSH key %s: %w", credentials.SSHKeyFile, err)
+		}
+		return auth, nil
+	}
+	auth, err := ssh.NewSSHAgentAuth("git")
+	if err != nil {
+		return nil, fmt.Errorf("no SSH key specified and SSH agent unavailable: %w", err)
+	}
+	return auth, nil
+}
+
 // Repo represents a git repository.
 type Repo struct {
 	Dir  string
@@ -44,8 +88,8 @@ type Repo struct {
 // it opens and provides access to that repository.
 //
 // Otherwise, it clones the repository from the given URL (repoURL) and saves it
-// to the specified directory path (dirpath).
-func CloneOrOpen(dirpath, repoURL string) (*Repo, error) {
+// to the specified directory path (dirpath). See Clone for the meaning of branch.
+func CloneOrOpen(dirpath, repoURL, branch string, depth int, credentials Credentials) (*Repo, error) {
 	slog.Info(fmt.Sprintf("Cloning %q to %q", repoURL, dirpath))
 
 	_, err := os.Stat(dirpath)
@@ -53,35 +97,98 @@ func CloneOrOpen(dirpath, repoURL string) (*Repo, error) {
 		return Open(dirpath)
 	}
 	if os.IsNotExist(err) {
-		return Clone(dirpath, repoURL)
+		return Clone(dirpath, repoURL, branch, depth, credentials)
 	}
 	return nil, err
 }
 
 // Clone downloads a copy of a Git repository from repoURL and saves it to the
-// specified directory at dirpath.
-func Clone(dirpath, repoURL string) (*Repo, error) {
+// specified directory at dirpath. Only the given branch is cloned and checked out,
+// or the remote's default branch if branch is empty. If depth is positive, a shallow
+// clone with that many commits of history is performed; otherwise the full history
+// is cloned. Credentials are only used for SSH URLs; HTTPS clones are unauthenticated.
+func Clone(dirpath, repoURL, branch string, depth int, credentials Credentials) (*Repo, error) {
+	if err := offline.Check(fmt.Sprintf("clone %s", repoURL)); err != nil {
+		return nil, err
+	}
+	referenceName := plumbing.HEAD
+	if branch != "" {
+		referenceName = plumbing.NewBranchReferenceName(branch)
+	}
 	options := &git.CloneOptions{
 		URL:           repoURL,
-		ReferenceName: plumbing.HEAD,
+		ReferenceName: referenceName,
 		SingleBranch:  true,
 		Tags:          git.AllTags,
+		Depth:         max(depth, 0),
 		// .NET uses submodules for conformance tests.
 		// (There may be other examples too.)
 		RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
 	}
 	if ci := os.Getenv("CI"); ci == "" {
-		options.Progress = os.Stdout // When not a CI build, output progress.
+		// When not a CI build, output progress. This goes to stderr, so that stdout
+		// can be used for generated output (see generate -stream-output).
+		options.Progress = os.Stderr
 	}
-
-	repo, err := git.PlainClone(dirpath, false, options)
-	if err != nil {
-		return nil, err
+	if IsSSHUrl(repoURL) {
+		auth, err := credentials.authFor(repoURL)
+		if err != nil {
+			return nil, err
+		}
+		options.Auth = auth
+	}
+
+	// Failed clones are cleaned up by go-git, so each attempt starts from scratch.
+	backoff := initialCloneRetryBackoff
+	for attempt := 1; ; attempt++ {
+		repo, err := git.PlainClone(dirpath, false, options)
+		if err == nil {
+			return &Repo{
+				Dir:  dirpath,
+				repo: repo,
+			}, nil
+		}
+		if branch != "" && errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return nil, fmt.Errorf("branch %s not found in %s: %w", branch, repoURL, err)
+		}
+		if attempt > maxCloneRetries || !isRetryableCloneError(err) {
+			if attempt > 1 {
+				return nil, fmt.Errorf("failed to clone %s after %d attempts: %w", repoURL, attempt, err)
+			}
+			return nil, err
+		}
+		slog.Warn(fmt.Sprintf("Failed to clone %s on attempt %d: %s; retrying in %s", repoURL, attempt, err, backoff))
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxCloneRetryBackoff)
 	}
-	return &Repo{
-		Dir:  dirpath,
-		repo: repo,
-	}, nil
+}
+
+// The backoff between clone attempts starts at initialCloneRetryBackoff, and doubles up
+// to maxCloneRetryBackoff.
+const (
+	defaultMaxCloneRetries   = 3
+	initialCloneRetryBackoff = 2 * time.Second
+	maxCloneRetryBackoff     = 30 * time.Second
+)
+
+// The maximum number of times a failed clone is retried.
+var maxCloneRetries = defaultMaxCloneRetries
+
+// Sets the maximum number of times a clone which fails (other than due to authentication
+// or the repo not existing) is retried. Zero disables retries.
+func SetMaxCloneRetries(retries int) {
+	maxCloneRetries = max(retries, 0)
+}
+
+// Reports whether a clone error may be transient (e.g. a network failure), rather than a
+// problem which retrying won't fix.
+func isRetryableCloneError(err error) bool {
+	return !errors.Is(err, transport.ErrAuthenticationRequired) &&
+		!errors.Is(err, transport.ErrAuthorizationFailed) &&
+		!errors.Is(err, transport.ErrRepositoryNotFound) &&
+		!errors.Is(err, transport.ErrEmptyRemoteRepository) &&
+		!errors.Is(err, git.ErrRepositoryAlreadyExists) &&
+		!errors.Is(err, plumbing.ErrReferenceNotFound)
 }
 
 // Open provides access to a Git repository that exists at dirpath.
@@ -109,7 +216,7 @@ func AddAll(repo *Repo) (git.Status, error) {
 }
 
 // returns an error if there is nothing to commit
-func Commit(repo *Repo, msg string, userName, userEmail string) error {
+func Commit(repo *Repo, msg string, userName, userEmail string, signer Signer) error {
 	worktree, err := repo.repo.Worktree()
 	if err != nil {
 		return err
@@ -128,13 +235,22 @@ func Commit(repo *Repo, msg string, userName, userEmail string) error {
 	if userEmail == "" {
 		userEmail = "noreply-cloudsdk@google.com"
 	}
-	hash, err := worktree.Commit(msg, &git.CommitOptions{
-		Author: &object.Signature{
-			Name:  userName,
-			Email: userEmail,
-			When:  time.Now(),
-		},
-	})
+	// The same identity is used for both author and committer, so that commits are
+	// clearly attributable regardless of the environment's git configuration.
+	signature := &object.Signature{
+		Name:  userName,
+		Email: userEmail,
+		When:  time.Now(),
+	}
+	options := &git.CommitOptions{
+		Author:    signature,
+		Committer: signature,
+	}
+	// If there's no signer, the commit is unsigned.
+	if signer != nil {
+		options.Signer = signer
+	}
+	hash, err := worktree.Commit(msg, options)
 	if err != nil {
 		return err
 	}
@@ -298,6 +414,43 @@ func getHashForPathOrEmpty(commit *object.Commit, path string) (string, error) {
 	return treeEntry.Hash.String(), nil
 }
 
+// Returns those of the given paths whose content (including any subdirectories) differs
+// between the given ref (a branch, tag or commit) and HEAD. A path which doesn't exist at
+// one of the commits is considered changed if it exists at the other.
+func GetPathsChangedSinceRef(repo *Repo, ref string, paths []string) ([]string, error) {
+	sinceHash, err := resolveRef(repo, ref)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve %s: %w", ref, err)
+	}
+	sinceCommit, err := repo.repo.CommitObject(sinceHash)
+	if err != nil {
+		return nil, err
+	}
+	headRef, err := repo.repo.Head()
+	if err != nil {
+		return nil, err
+	}
+	headCommit, err := repo.repo.CommitObject(headRef.Hash())
+	if err != nil {
+		return nil, err
+	}
+	changed := []string{}
+	for _, path := range paths {
+		sinceHash, err := getHashForPathOrEmpty(sinceCommit, path)
+		if err != nil {
+			return nil, err
+		}
+		headHash, err := getHashForPathOrEmpty(headCommit, path)
+		if err != nil {
+			return nil, err
+		}
+		if sinceHash != headHash {
+			changed = append(changed, path)
+		}
+	}
+	return changed, nil
+}
+
 // Returns all commits since tagName that contains files in path.
 // If tagName is empty, all commits for the given paths are returned.
 func GetCommitsForPathsSinceTag(repo *Repo, paths []string, tagName string) ([]object.Commit, error) {
@@ -370,27 +523,119 @@ func GetCommitsForReleaseID(repo *Repo, releaseID string) ([]object.Commit, erro
 }
 
 // Creates a branch with the given name in the default remote.
-func PushBranch(repo *Repo, remoteBranch string, accessToken string) error {
+func PushBranch(repo *Repo, remoteBranch string, credentials Credentials) error {
+	remoteURL, err := pushUrl(repo, credentials)
+	if err != nil {
+		return err
+	}
+	return pushBranch(repo, remoteURL, remoteBranch, credentials, false)
+}
+
+// Pushes HEAD to the given remote branch, replacing whatever the branch previously contained.
+func ForcePushBranch(repo *Repo, remoteBranch string, credentials Credentials) error {
+	remoteURL, err := pushUrl(repo, credentials)
+	if err != nil {
+		return err
+	}
+	return pushBranch(repo, remoteURL, remoteBranch, credentials, true)
+}
+
+// Pushes HEAD to the given branch in a GitHub repo other than the default remote (typically
+// a fork), optionally replacing whatever the branch previously contained. The repo is
+// accessed over SSH if only an SSH key is available, and over HTTPS otherwise.
+func PushBranchToGitHubRepo(repo *Repo, target githubrepo.GitHubRepo, remoteBranch string, credentials Credentials, force bool) error {
+	remoteURL := githubrepo.HTTPSUrl(target)
+	if credentials.AccessToken == "" && credentials.SSHKeyFile != "" {
+		remoteURL = githubrepo.SSHUrl(target)
+	}
+	return pushBranch(repo, remoteURL, remoteBranch, credentials, force)
+}
+
+func pushBranch(repo *Repo, remoteURL, remoteBranch string, credentials Credentials, force bool) error {
+	if err := offline.Check(fmt.Sprintf("push branch %s", remoteBranch)); err != nil {
+		return err
+	}
 	headRef, err := repo.repo.Head()
 	if err != nil {
 		return err
 	}
-	auth := http.BasicAuth{
-		Username: "Ignored",
-		Password: accessToken,
+	auth, err := credentials.authFor(remoteURL)
+	if err != nil {
+		return err
 	}
 	refFrom := headRef.Name().String()
 	refTo := fmt.Sprintf("refs/heads/%s", remoteBranch)
 	refSpec := config.RefSpec(fmt.Sprintf("%s:%s", refFrom, refTo))
+	if force {
+		refSpec = "+" + refSpec
+	}
 	pushOptions := git.PushOptions{
-		RefSpecs: []config.RefSpec{refSpec},
-		Auth:     &auth,
+		RefSpecs:  []config.RefSpec{refSpec},
+		Auth:      auth,
+		Force:     force,
+		RemoteURL: remoteURL,
 	}
 
 	slog.Info(fmt.Sprintf("Pushing to branch %s", remoteBranch))
+	err = repo.repo.Push(&pushOptions)
+	if err != nil && isShallow(repo) {
+		// go-git can't deepen a shallow clone, so the best we can do is explain the likely cause.
+		return fmt.Errorf("failed to push from shallow clone of %s; the push may need history beyond the shallow boundary, "+
+			"so retry with a larger -clone-depth or without -clone-depth: %w", repo.Dir, err)
+	}
+	return err
+}
+
+// Deletes the given branch from the default remote.
+func DeleteRemoteBranch(repo *Repo, remoteBranch string, credentials Credentials) error {
+	if err := offline.Check(fmt.Sprintf("delete remote branch %s", remoteBranch)); err != nil {
+		return err
+	}
+	remoteURL, err := pushUrl(repo, credentials)
+	if err != nil {
+		return err
+	}
+	auth, err := credentials.authFor(remoteURL)
+	if err != nil {
+		return err
+	}
+	refSpec := config.RefSpec(fmt.Sprintf(":refs/heads/%s", remoteBranch))
+	pushOptions := git.PushOptions{
+		RefSpecs:  []config.RefSpec{refSpec},
+		Auth:      auth,
+		RemoteURL: remoteURL,
+	}
+	slog.Info(fmt.Sprintf("Deleting remote branch %s", remoteBranch))
 	return repo.repo.Push(&pushOptions)
 }
 
+// Returns the URL to push to: the URL of the default remote, except that if
+// only an SSH key is available (and no access token), a GitHub HTTPS URL is
+// converted to the equivalent SSH URL.
+func pushUrl(repo *Repo, credentials Credentials) (string, error) {
+	remote, err := repo.repo.Remote(git.DefaultRemoteName)
+	if err != nil {
+		return "", err
+	}
+	urls := remote.Config().URLs
+	if len(urls) == 0 {
+		return "", fmt.Errorf("remote %s has no URLs", git.DefaultRemoteName)
+	}
+	remoteURL := urls[0]
+	if credentials.AccessToken == "" && credentials.SSHKeyFile != "" && !IsSSHUrl(remoteURL) {
+		if gitHubRepo, err := githubrepo.ParseUrl(remoteURL); err == nil {
+			return githubrepo.SSHUrl(gitHubRepo), nil
+		}
+	}
+	return remoteURL, nil
+}
+
+// Reports whether the repository is a shallow clone.
+func isShallow(repo *Repo) bool {
+	shallows, err := repo.repo.Storer.Shallow()
+	return err == nil && len(shallows) > 0
+}
+
 // CleanWorkingTree Drops any local changes NOT committed, but keeps any local commits
 func CleanWorkingTree(repo *Repo) error {
 	worktree, err := repo.repo.Worktree()
@@ -403,6 +648,16 @@ func CleanWorkingTree(repo *Repo) error {
 	return worktree.Clean(&git.CleanOptions{Dir: true})
 }
 
+// Restores all tracked files to their state in the head commit, without
+// removing untracked files.
+func RestoreTrackedFiles(repo *Repo) error {
+	worktree, err := repo.repo.Worktree()
+	if err != nil {
+		return err
+	}
+	return worktree.Reset(&git.ResetOptions{Mode: git.HardReset})
+}
+
 // Drop any local changes, and also reset to the parent of the current head commit.
 // This is a special case of CleanAndRevertCommits where the count is 1.
 func CleanAndRevertHeadCommit(repo *Repo) error {
@@ -441,6 +696,93 @@ func CleanAndRevertCommits(repo *Repo, count int) error {
 	return worktree.Clean(&git.CleanOptions{Dir: true})
 }
 
+// Undoes the last count commits while keeping their changes staged (as with "git reset --soft"),
+// so that they can be committed again as a single commit. The messages of the undone commits
+// are returned, oldest first.
+func SoftResetCommits(repo *Repo, count int) ([]string, error) {
+	headRef, err := repo.repo.Head()
+	if err != nil {
+		return nil, err
+	}
+	targetCommit, err := repo.repo.CommitObject(headRef.Hash())
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]string, count)
+	for i := range count {
+		if targetCommit.NumParents() != 1 {
+			return nil, fmt.Errorf("commit %s has multiple parents", targetCommit.Hash.String())
+		}
+		messages[count-1-i] = targetCommit.Message
+		if targetCommit, err = targetCommit.Parent(0); err != nil {
+			return nil, err
+		}
+	}
+	worktree, err := repo.repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	if err := worktree.Reset(&git.ResetOptions{Mode: git.SoftReset, Commit: targetCommit.Hash}); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// Checks out the given branch, tag or commit hash (detaching HEAD). If the ref isn't known
+// locally (for example, a branch other than the default, which isn't included in a
+// single-branch clone), all branches are fetched from the origin remote first.
+func CheckoutRef(repo *Repo, ref string, credentials Credentials) error {
+	hash, err := resolveRef(repo, ref)
+	if err != nil {
+		if err := offline.Check(fmt.Sprintf("fetch ref %s", ref)); err != nil {
+			return err
+		}
+		slog.Info(fmt.Sprintf("Ref %s not found locally; fetching branches from %s", ref, git.DefaultRemoteName))
+		remote, err := repo.repo.Remote(git.DefaultRemoteName)
+		if err != nil {
+			return err
+		}
+		urls := remote.Config().URLs
+		if len(urls) == 0 {
+			return fmt.Errorf("remote %s has no URLs", git.DefaultRemoteName)
+		}
+		auth, err := credentials.authFor(urls[0])
+		if err != nil {
+			return err
+		}
+		err = repo.repo.Fetch(&git.FetchOptions{
+			RemoteName: git.DefaultRemoteName,
+			RefSpecs:   []config.RefSpec{"+refs/heads/*:refs/remotes/origin/*"},
+			Auth:       auth,
+			Tags:       git.AllTags,
+		})
+		if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return fmt.Errorf("failed to fetch ref %s: %w", ref, err)
+		}
+		if hash, err = resolveRef(repo, ref); err != nil {
+			return fmt.Errorf("ref %s not found in %s: %w", ref, urls[0], err)
+		}
+	}
+	slog.Info(fmt.Sprintf("Checking out %s (%s)", ref, hash))
+	worktree, err := repo.repo.Worktree()
+	if err != nil {
+		return err
+	}
+	return worktree.Checkout(&git.CheckoutOptions{Hash: hash})
+}
+
+// Resolves a branch (local or on the origin remote), tag or commit hash.
+func resolveRef(repo *Repo, ref string) (plumbing.Hash, error) {
+	var err error
+	for _, revision := range []string{ref, git.DefaultRemoteName + "/" + ref} {
+		var hash *plumbing.Hash
+		if hash, err = repo.repo.ResolveRevision(plumbing.Revision(revision)); err == nil {
+			return *hash, nil
+		}
+	}
+	return plumbing.ZeroHash, err
+}
+
 func Checkout(repo *Repo, commit string) error {
 	worktree, err := repo.repo.Worktree()
 	if err != nil {
@@ -456,7 +798,8 @@ func Checkout(repo *Repo, commit string) error {
 // Parses the GitHub repo name from the remote for this repository.
 // There must only be a single remote with a GitHub URL (as the first URL), in order to provide an
 // unambiguous result.
-// Remotes without any URLs, or where the first URL does not start with https://github.com/ are ignored.
+// Remotes without any URLs, or where the first URL is not a GitHub HTTPS or SSH URL
+// (for github.com unless GitHub Enterprise Server is configured) are ignored.
 func GetGitHubRepoFromRemote(repo *Repo) (githubrepo.GitHubRepo, error) {
 	remotes, err := repo.repo.Remotes()
 	if err != nil {
@@ -466,7 +809,10 @@ func GetGitHubRepoFromRemote(repo *Repo) (githubrepo.GitHubRepo, error) {
 	gitHubUrl := ""
 	for _, remote := range remotes {
 		urls := remote.Config().URLs
-		if len(urls) > 0 && strings.HasPrefix(urls[0], "https://github.com/") {
+		if len(urls) == 0 {
+			continue
+		}
+		if _, err := githubrepo.ParseUrl(urls[0]); err == nil {
 			gitHubRemoteNames = append(gitHubRemoteNames, remote.Config().Name)
 			gitHubUrl = urls[0]
 		}
@@ -0,0 +1,124 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitrepo
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// The files (or directories) within .git which indicate that an operation is in
+// progress, with a description of each operation.
+var inProgressOperationFiles = []struct {
+	name      string
+	operation string
+}{
+	{"rebase-merge", "rebase"},
+	{"rebase-apply", "rebase (or am)"},
+	{"MERGE_HEAD", "merge"},
+	{"CHERRY_PICK_HEAD", "cherry-pick"},
+	{"REVERT_HEAD", "revert"},
+	{"BISECT_LOG", "bisect"},
+}
+
+// Returns a description of the operation (e.g. "rebase") which is in progress in the repo,
+// e.g. because a previous command was interrupted or hit a conflict, or an empty string if
+// there's none.
+func InProgressOperation(repo *Repo) string {
+	for _, file := range inProgressOperationFiles {
+		if _, err := os.Stat(filepath.Join(repo.Dir, git.GitDirName, file.name)); err == nil {
+			return file.operation
+		}
+	}
+	return ""
+}
+
+// Returns the files with uncommitted changes (including untracked files), each preceded by
+// its status as in "git status --short", sorted by path.
+func DirtyFiles(repo *Repo) ([]string, error) {
+	worktree, err := repo.repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	status, err := worktree.Status()
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{}
+	for path, fileStatus := range status {
+		if fileStatus.Staging != git.Unmodified || fileStatus.Worktree != git.Unmodified {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	files := []string{}
+	for _, path := range paths {
+		fileStatus := status[path]
+		files = append(files, fmt.Sprintf("%c%c %s", fileStatus.Staging, fileStatus.Worktree, path))
+	}
+	return files, nil
+}
+
+// Discards any in-progress operation (as with "git rebase --abort" or "git merge --abort"),
+// all uncommitted changes and all untracked files, so that the repo is in a known state.
+// An interrupted rebase leaves the original branch checked out at its original commit.
+func ResetHard(repo *Repo) error {
+	if err := abortRebase(repo); err != nil {
+		return err
+	}
+	gitDir := filepath.Join(repo.Dir, git.GitDirName)
+	for _, name := range []string{"rebase-merge", "rebase-apply", "MERGE_HEAD", "MERGE_MSG", "MERGE_MODE", "CHERRY_PICK_HEAD", "REVERT_HEAD", "BISECT_LOG", "BISECT_START", "BISECT_NAMES", "BISECT_TERMS", "BISECT_EXPECTED_REV"} {
+		if err := os.RemoveAll(filepath.Join(gitDir, name)); err != nil {
+			return err
+		}
+	}
+	slog.Info(fmt.Sprintf("Resetting %s to HEAD and removing untracked files", repo.Dir))
+	return CleanWorkingTree(repo)
+}
+
+// If a rebase is in progress, restores HEAD to the branch being rebased, at the commit
+// it was at before the rebase started.
+func abortRebase(repo *Repo) error {
+	for _, dir := range []string{"rebase-merge", "rebase-apply"} {
+		stateDir := filepath.Join(repo.Dir, git.GitDirName, dir)
+		headName, err := os.ReadFile(filepath.Join(stateDir, "head-name"))
+		if err != nil {
+			continue
+		}
+		origHead, err := os.ReadFile(filepath.Join(stateDir, "orig-head"))
+		if err != nil {
+			continue
+		}
+		branch := plumbing.ReferenceName(strings.TrimSpace(string(headName)))
+		hash := plumbing.NewHash(strings.TrimSpace(string(origHead)))
+		slog.Info(fmt.Sprintf("Aborting rebase of %s", branch.Short()))
+		if !branch.IsBranch() {
+			// A detached HEAD was being rebased.
+			return repo.repo.Storer.SetReference(plumbing.NewHashReference(plumbing.HEAD, hash))
+		}
+		if err := repo.repo.Storer.SetReference(plumbing.NewHashReference(branch, hash)); err != nil {
+			return err
+		}
+		return repo.repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, branch))
+	}
+	return nil
+}
@@ -21,6 +21,7 @@ import (
 	"log/slog"
 
 	"github.com/googleapis/librarian/internal/command"
+	"github.com/googleapis/librarian/internal/tracing"
 )
 
 func Run(ctx context.Context, arg ...string) error {
@@ -31,7 +32,20 @@ func Run(ctx context.Context, arg ...string) error {
 	if err := cmd.Parse(arg[1:]); err != nil {
 		return err
 	}
+	if err := command.ConfigureLogging(); err != nil {
+		return err
+	}
 	slog.Info("librarian", "arguments", arg)
+	shutdownTracing, err := tracing.Init(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Traces are still flushed if the command was cancelled.
+		if err := shutdownTracing(context.WithoutCancel(ctx)); err != nil {
+			slog.Warn(fmt.Sprintf("Failed to flush traces: %s", err))
+		}
+	}()
 	return command.RunCommand(cmd, ctx)
 }
 
@@ -0,0 +1,41 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package offline supports running Librarian without network access (e.g. in an
+// air-gapped sandbox). Each operation requiring the network calls Check before it
+// starts, so that it's refused with a clear error when offline mode is enabled.
+package offline
+
+import "fmt"
+
+var enabled bool
+
+// Enable enables offline mode for the rest of the process.
+func Enable() {
+	enabled = true
+}
+
+// Enabled reports whether offline mode is enabled.
+func Enabled() bool {
+	return enabled
+}
+
+// Check returns an error if offline mode is enabled. The operation describes what
+// would have required network access, e.g. "clone https://github.com/...".
+func Check(operation string) error {
+	if enabled {
+		return fmt.Errorf("cannot %s: network access is disabled by -offline", operation)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/googleapis/gax-go/v2/apierror"
+	"github.com/googleapis/librarian/internal/offline"
+	"google.golang.org/grpc/codes"
+)
+
+// Reads the latest version of each secret from Secret Manager in a Google Cloud project.
+type gcpProvider struct {
+	client  *secretmanager.Client
+	project string
+}
+
+func newGcpProvider(ctx context.Context, project string) (*gcpProvider, error) {
+	if err := offline.Check("access Secret Manager"); err != nil {
+		return nil, err
+	}
+	client, err := secretmanager.NewClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &gcpProvider{client: client, project: project}, nil
+}
+
+func (provider *gcpProvider) Secret(ctx context.Context, name string) (string, bool, error) {
+	request := &secretmanagerpb.AccessSecretVersionRequest{
+		Name: fmt.Sprintf("projects/%s/secrets/%s/versions/latest", provider.project, name),
+	}
+	secret, err := provider.client.AccessSecretVersion(ctx, request)
+	if err != nil {
+		// A secret which isn't found doesn't exist; any other error is a real error.
+		var ae *apierror.APIError
+		if errors.As(err, &ae) && ae.GRPCStatus().Code() == codes.NotFound {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	// We assume the payload is valid UTF-8.
+	return string(secret.Payload.Data[:]), true, nil
+}
+
+func (provider *gcpProvider) String() string {
+	return "Secret Manager"
+}
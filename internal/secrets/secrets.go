@@ -0,0 +1,161 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package secrets provides access to secrets (such as the GitHub access token, and values
+// of environment variables for containers) from a configurable backend: Google Cloud Secret
+// Manager, environment variables, files, or HashiCorp Vault.
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// A source of secrets, identified by name.
+type SecretProvider interface {
+	// Returns the value of the named secret, and whether it exists. An error is only
+	// returned if the provider itself fails, e.g. because it's unreachable.
+	Secret(ctx context.Context, name string) (string, bool, error)
+	// Describes the provider, for logging.
+	String() string
+}
+
+// The names of the supported providers, as specified by -secret-provider.
+const (
+	ProviderGcp   = "gcp"
+	ProviderEnv   = "env"
+	ProviderFile  = "file"
+	ProviderVault = "vault"
+)
+
+// All the supported providers.
+var Providers = []string{ProviderGcp, ProviderEnv, ProviderFile, ProviderVault}
+
+// The settings required by some providers.
+type ProviderOptions struct {
+	// The Google Cloud project containing Secret Manager secrets, for the gcp provider.
+	Project string
+	// The directory containing a file for each secret, for the file provider.
+	Dir string
+	// The mount path of the KV (version 2) secrets engine, for the vault provider.
+	VaultMount string
+}
+
+// Creates the named provider. Each secret is read at most once, and then cached. For
+// compatibility with the behavior before other providers were supported, the gcp provider
+// is only created if a project is specified; otherwise nil is returned, meaning there
+// are no secrets.
+func NewProvider(ctx context.Context, name string, options ProviderOptions) (SecretProvider, error) {
+	var provider SecretProvider
+	var err error
+	switch name {
+	case ProviderGcp:
+		if options.Project == "" {
+			return nil, nil
+		}
+		provider, err = newGcpProvider(ctx, options.Project)
+	case ProviderEnv:
+		provider = envProvider{}
+	case ProviderFile:
+		if options.Dir == "" {
+			return nil, errors.New("the file secret provider requires a secrets directory")
+		}
+		provider = fileProvider{dir: options.Dir}
+	case ProviderVault:
+		provider, err = newVaultProvider(options.VaultMount)
+	default:
+		return nil, fmt.Errorf("unknown secret provider %q; must be one of %s", name, strings.Join(Providers, ", "))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cachingProvider{provider: provider, cache: map[string]string{}}, nil
+}
+
+// Caches the secrets found by another provider.
+type cachingProvider struct {
+	provider SecretProvider
+	mutex    sync.Mutex
+	cache    map[string]string
+}
+
+func (caching *cachingProvider) Secret(ctx context.Context, name string) (string, bool, error) {
+	caching.mutex.Lock()
+	defer caching.mutex.Unlock()
+	if value, ok := caching.cache[name]; ok {
+		return value, true, nil
+	}
+	value, ok, err := caching.provider.Secret(ctx, name)
+	if ok && err == nil {
+		caching.cache[name] = value
+	}
+	return value, ok, err
+}
+
+func (caching *cachingProvider) String() string {
+	return caching.provider.String()
+}
+
+// Characters which aren't valid in environment variable names.
+var invalidEnvironmentVariableCharactersRegex = regexp.MustCompile(`[^A-Z0-9_]`)
+
+// Reads each secret from the environment variable with the same name, upper-cased and with
+// other characters which aren't valid in names replaced by underscores, so that the secret
+// github-token is read from GITHUB_TOKEN.
+type envProvider struct{}
+
+func (envProvider) Secret(ctx context.Context, name string) (string, bool, error) {
+	value, ok := os.LookupEnv(environmentVariableForSecret(name))
+	return value, ok, nil
+}
+
+func (envProvider) String() string {
+	return "environment variables"
+}
+
+func environmentVariableForSecret(name string) string {
+	return invalidEnvironmentVariableCharactersRegex.ReplaceAllString(strings.ToUpper(name), "_")
+}
+
+// Reads each secret from the file with the same name in a directory (e.g. one mounted by a
+// Kubernetes secret volume), without trailing whitespace.
+type fileProvider struct {
+	dir string
+}
+
+func (provider fileProvider) Secret(ctx context.Context, name string) (string, bool, error) {
+	if !filepath.IsLocal(name) {
+		return "", false, fmt.Errorf("invalid secret name %q", name)
+	}
+	data, err := os.ReadFile(filepath.Join(provider.dir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return strings.TrimRightFunc(string(data), unicode.IsSpace), true, nil
+}
+
+func (provider fileProvider) String() string {
+	return fmt.Sprintf("files in %s", provider.dir)
+}
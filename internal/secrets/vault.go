@@ -0,0 +1,109 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/googleapis/librarian/internal/offline"
+)
+
+// The environment variables specifying the Vault server and the token to authenticate with,
+// as used by the Vault CLI.
+const (
+	vaultAddressEnvironmentVariable = "VAULT_ADDR"
+	vaultTokenEnvironmentVariable   = "VAULT_TOKEN"
+)
+
+// The mount path of the KV secrets engine used if none is specified, as in a Vault dev server.
+const defaultVaultMount = "secret"
+
+// The key within each Vault secret which holds its value.
+const vaultValueKey = "value"
+
+// Reads each secret from the KV (version 2) secrets engine of a Vault server, where each
+// secret is stored at {mount}/{name}, with its value under the key "value".
+type vaultProvider struct {
+	address string
+	token   string
+	mount   string
+	client  *http.Client
+}
+
+func newVaultProvider(mount string) (*vaultProvider, error) {
+	address := os.Getenv(vaultAddressEnvironmentVariable)
+	if address == "" {
+		return nil, fmt.Errorf("the vault secret provider requires the %s environment variable", vaultAddressEnvironmentVariable)
+	}
+	token := os.Getenv(vaultTokenEnvironmentVariable)
+	if token == "" {
+		return nil, fmt.Errorf("the vault secret provider requires the %s environment variable", vaultTokenEnvironmentVariable)
+	}
+	if mount == "" {
+		mount = defaultVaultMount
+	}
+	return &vaultProvider{address: strings.TrimSuffix(address, "/"), token: token, mount: strings.Trim(mount, "/"), client: http.DefaultClient}, nil
+}
+
+func (provider *vaultProvider) Secret(ctx context.Context, name string) (string, bool, error) {
+	if err := offline.Check("access Vault"); err != nil {
+		return "", false, err
+	}
+	secretUrl := fmt.Sprintf("%s/v1/%s/data/%s", provider.address, provider.mount, url.PathEscape(name))
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, secretUrl, nil)
+	if err != nil {
+		return "", false, err
+	}
+	request.Header.Set("X-Vault-Token", provider.token)
+	response, err := provider.client.Do(request)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read secret %s from Vault: %w", name, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return "", false, nil
+	}
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return "", false, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return "", false, fmt.Errorf("failed to read secret %s from Vault: %s: %s", name, response.Status, strings.TrimSpace(string(body)))
+	}
+	var secret struct {
+		Data struct {
+			Data map[string]any `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return "", false, fmt.Errorf("failed to parse secret %s from Vault: %w", name, err)
+	}
+	value, ok := secret.Data.Data[vaultValueKey].(string)
+	if !ok {
+		return "", false, fmt.Errorf("secret %s in Vault has no string %q key", name, vaultValueKey)
+	}
+	return value, true, nil
+}
+
+func (provider *vaultProvider) String() string {
+	return fmt.Sprintf("Vault (%s)", provider.address)
+}
@@ -0,0 +1,109 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statepb
+
+import (
+	"encoding/json"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Returns a JSON Schema (draft-07) describing the JSON format of PipelineState, as read and
+// written with protojson, for editors and other tools to validate pipeline-state.json (or
+// the equivalent YAML). Properties use the JSON (lowerCamelCase) field names, as written by
+// Librarian; protojson also accepts the original proto field names, but the schema doesn't.
+func JsonSchema() ([]byte, error) {
+	descriptor := (&PipelineState{}).ProtoReflect().Descriptor()
+	definitions := map[string]any{}
+	schema := messageSchema(descriptor, definitions)
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["title"] = string(descriptor.Name())
+	if len(definitions) > 0 {
+		schema["definitions"] = definitions
+	}
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// Returns the schema of a message, adding the schemas of any messages it refers to (other
+// than well-known types, which have special JSON representations) to definitions.
+func messageSchema(descriptor protoreflect.MessageDescriptor, definitions map[string]any) map[string]any {
+	properties := map[string]any{}
+	fields := descriptor.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		switch {
+		case field.IsMap():
+			properties[field.JSONName()] = map[string]any{
+				"type":                 "object",
+				"additionalProperties": singularFieldSchema(field.MapValue(), definitions),
+			}
+		case field.IsList():
+			properties[field.JSONName()] = map[string]any{
+				"type":  "array",
+				"items": singularFieldSchema(field, definitions),
+			}
+		default:
+			properties[field.JSONName()] = singularFieldSchema(field, definitions)
+		}
+	}
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+// Returns the schema of a single value of the field (i.e. an element, if it's repeated).
+func singularFieldSchema(field protoreflect.FieldDescriptor, definitions map[string]any) map[string]any {
+	switch field.Kind() {
+	case protoreflect.BoolKind:
+		return map[string]any{"type": "boolean"}
+	case protoreflect.StringKind:
+		return map[string]any{"type": "string"}
+	case protoreflect.BytesKind:
+		return map[string]any{"type": "string", "contentEncoding": "base64"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind, protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return map[string]any{"type": "integer"}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		// protojson writes 64-bit integers as strings, but accepts either.
+		return map[string]any{"type": []string{"integer", "string"}}
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return map[string]any{"type": "number"}
+	case protoreflect.EnumKind:
+		names := []string{}
+		values := field.Enum().Values()
+		for i := 0; i < values.Len(); i++ {
+			names = append(names, string(values.Get(i).Name()))
+		}
+		return map[string]any{"type": "string", "enum": names}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		message := field.Message()
+		switch message.FullName() {
+		case "google.protobuf.Timestamp":
+			return map[string]any{"type": "string", "format": "date-time"}
+		case "google.protobuf.Duration":
+			return map[string]any{"type": "string", "pattern": `^-?[0-9]+(\.[0-9]+)?s$`}
+		}
+		name := string(message.Name())
+		if _, ok := definitions[name]; !ok {
+			// Reserve the name first, in case the message refers to itself.
+			definitions[name] = nil
+			definitions[name] = messageSchema(message, definitions)
+		}
+		return map[string]any{"$ref": "#/definitions/" + name}
+	default:
+		return map[string]any{}
+	}
+}
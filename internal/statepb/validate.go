@@ -0,0 +1,59 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statepb
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Validates a pipeline state, checking that every library has an ID, that library IDs
+// are unique, and that each API path is non-empty and belongs to at most one library.
+// All problems are reported in the returned error, rather than just the first.
+func Validate(state *PipelineState) error {
+	var problems []error
+	libraryIndexes := map[string]int{}
+	apiPathOwners := map[string]string{}
+	for i, library := range state.Libraries {
+		id := library.Id
+		if id == "" {
+			problems = append(problems, fmt.Errorf("library at index %d has an empty id", i))
+			id = fmt.Sprintf("(index %d)", i)
+		} else if previous, ok := libraryIndexes[id]; ok {
+			problems = append(problems, fmt.Errorf("library id '%s' is used at both index %d and index %d", id, previous, i))
+		} else {
+			libraryIndexes[id] = i
+		}
+		for _, apiPath := range library.ApiPaths {
+			if apiPath == "" {
+				problems = append(problems, fmt.Errorf("library %s has an empty API path", id))
+				continue
+			}
+			if owner, ok := apiPathOwners[apiPath]; ok {
+				if owner == id {
+					problems = append(problems, fmt.Errorf("library %s lists API path %s more than once", id, apiPath))
+				} else {
+					problems = append(problems, fmt.Errorf("API path %s is mapped to both library %s and library %s", apiPath, owner, id))
+				}
+				continue
+			}
+			apiPathOwners[apiPath] = id
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid pipeline state: %w", errors.Join(problems...))
+	}
+	return nil
+}
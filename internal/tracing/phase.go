@@ -0,0 +1,86 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// A Phase is a span covering a major phase of a run (such as cloning, or a container
+// invocation), whose duration is logged and recorded even when tracing isn't enabled.
+type Phase struct {
+	span       trace.Span
+	name       string
+	attributes []attribute.KeyValue
+	start      time.Time
+}
+
+// PhaseTiming is the recorded duration of a completed phase.
+type PhaseTiming struct {
+	// Name is the name of the phase, including its attributes (e.g. the library ID), if any.
+	Name     string
+	Duration time.Duration
+	// Failed indicates whether the phase ended with an error.
+	Failed bool
+}
+
+var (
+	timingsMutex sync.Mutex
+	timings      []PhaseTiming
+)
+
+// StartPhase starts a phase with the given name and attributes, as for Start.
+func StartPhase(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, *Phase) {
+	ctx, span := Start(ctx, name, attributes...)
+	return ctx, &Phase{span: span, name: name, attributes: attributes, start: time.Now()}
+}
+
+// End ends the phase's span as for End, then logs and records its duration.
+// Phases may be ended concurrently.
+func (p *Phase) End(err error) {
+	End(p.span, err)
+	timing := PhaseTiming{Name: p.description(), Duration: time.Since(p.start), Failed: err != nil}
+	slog.Info(fmt.Sprintf("Phase %s took %s", timing.Name, timing.Duration.Round(time.Millisecond)))
+	timingsMutex.Lock()
+	defer timingsMutex.Unlock()
+	timings = append(timings, timing)
+}
+
+func (p *Phase) description() string {
+	if len(p.attributes) == 0 {
+		return p.name
+	}
+	details := []string{}
+	for _, kv := range p.attributes {
+		details = append(details, fmt.Sprintf("%s=%s", kv.Key, kv.Value.Emit()))
+	}
+	return fmt.Sprintf("%s (%s)", p.name, strings.Join(details, ", "))
+}
+
+// PhaseTimings returns the timings of all the phases which have ended so far, in the
+// order in which they ended.
+func PhaseTimings() []PhaseTiming {
+	timingsMutex.Lock()
+	defer timingsMutex.Unlock()
+	return append([]PhaseTiming(nil), timings...)
+}
@@ -0,0 +1,94 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tracing provides optional OpenTelemetry tracing for Librarian runs.
+// Spans are only exported when an OTLP endpoint is configured via the standard
+// OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT environment
+// variables; otherwise all tracing is a no-op.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/googleapis/librarian/internal/offline"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/googleapis/librarian"
+
+// Attribute keys used for spans.
+const (
+	AttributeLibraryID = attribute.Key("librarian.library_id")
+	AttributeImage     = attribute.Key("librarian.image")
+	AttributeCommand   = attribute.Key("librarian.command")
+	AttributeApiPath   = attribute.Key("librarian.api_path")
+)
+
+// Init configures OpenTelemetry to export spans via OTLP (over HTTP), if an
+// endpoint is configured in the environment. The returned function flushes any
+// pending spans and shuts down the exporter; it must be called before the
+// process exits, and is safe to call even if tracing isn't configured.
+func Init(ctx context.Context) (func(context.Context) error, error) {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	if offline.Enabled() {
+		slog.Warn("Not exporting traces, as network access is disabled by -offline")
+		return func(context.Context) error { return nil }, nil
+	}
+	// The exporter reads the remaining OTEL_EXPORTER_OTLP_* variables (headers, timeouts etc).
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+	// Later options take precedence, so OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES
+	// can override the default service name.
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", "librarian")),
+		resource.WithFromEnv())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OpenTelemetry resource: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	slog.Info("OpenTelemetry tracing enabled")
+	return provider.Shutdown, nil
+}
+
+// Start starts a span with the given name and attributes, as a child of any span in ctx.
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// End ends the given span, recording err (if non-nil) as the span's status.
+// This is designed to be used with a named error result, e.g.
+// "defer func() { tracing.End(span, err) }()".
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}